@@ -20,6 +20,10 @@ import (
 	"github.com/exoscale/exoscale-cloud-controller-manager/exoscale"
 )
 
+// healthzBindAddress is the address the Exoscale-specific health checks are
+// served on by every replica, empty to disable.
+var healthzBindAddress string
+
 func main() {
 	rand.New(rand.NewSource(time.Now().UnixNano()))
 
@@ -29,7 +33,12 @@ func main() {
 	}
 
 	fss := cliflag.NamedFlagSets{}
+	fss.FlagSet("exoscale").StringVar(&healthzBindAddress, "exoscale-healthz-bind-address",
+		exoscale.DefaultHealthzBindAddress,
+		"The address the Exoscale health checks are served on (under /healthz and /readyz) by every replica, "+
+			"regardless of the leader election. Empty to disable.")
 	command := app.NewCloudControllerManagerCommand(ccmOptions, cloudInitializer, app.DefaultInitFuncConstructors, names.CCMControllerAliases(), fss, wait.NeverStop)
+	command.AddCommand(newValidateConfigCommand())
 
 	// From https://github.com/kubernetes/cloud-provider/blob/master/sample/basic_main.go:
 	// TODO: once we switch everything over to Cobra commands, we can go back to calling
@@ -77,5 +86,18 @@ func cloudInitializer(config *config.CompletedConfig) cloudprovider.Interface {
 				"This check can be bypassed by setting the allow-untagged-cloud option")
 		}
 	}
+
+	// The provider is started on every replica, while the cloud controllers
+	// (calling Initialize) only run on the leader.
+	if err := exoscale.Start(cloud, config.ClientBuilder, wait.NeverStop); err != nil {
+		klog.Fatalf("unable to start the Exoscale cloud provider: %v", err)
+	}
+
+	if healthzBindAddress != "" {
+		if err := exoscale.ServeHealthz(cloud, healthzBindAddress, wait.NeverStop); err != nil {
+			klog.Fatalf("unable to serve the Exoscale health checks: %v", err)
+		}
+	}
+
 	return cloud
 }
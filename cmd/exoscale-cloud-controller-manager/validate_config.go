@@ -0,0 +1,54 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/exoscale/exoscale-cloud-controller-manager/exoscale"
+)
+
+// newValidateConfigCommand returns the command validating a cloud-config file
+// offline, and printing the effective configuration.
+func newValidateConfigCommand() *cobra.Command {
+	var cloudConfigFile string
+
+	cmd := &cobra.Command{
+		Use:   "validate-config",
+		Short: "Validate a cloud-config file and print the effective configuration",
+		Long: `Validate a cloud-config file (merged with the EXOSCALE_* environment
+variables, as done by the cloud controller manager) without reaching the
+Exoscale API, and print the effective configuration with its secrets redacted.`,
+		Args: cobra.NoArgs,
+		Run: func(cmd *cobra.Command, _ []string) {
+			effective, err := validateConfig(cloudConfigFile)
+			if err != nil {
+				// Exit without the stack traces klog.Fatal would dump.
+				fmt.Fprintln(cmd.ErrOrStderr(), err)
+				os.Exit(1)
+			}
+
+			fmt.Fprint(cmd.OutOrStdout(), string(effective))
+		},
+	}
+
+	cmd.Flags().StringVar(&cloudConfigFile, "cloud-config", "", "The path to the cloud provider configuration file.")
+
+	return cmd
+}
+
+func validateConfig(cloudConfigFile string) ([]byte, error) {
+	var config io.Reader
+	if cloudConfigFile != "" {
+		data, err := os.ReadFile(cloudConfigFile)
+		if err != nil {
+			return nil, err
+		}
+		config = bytes.NewReader(data)
+	}
+
+	return exoscale.ValidateConfig(config)
+}
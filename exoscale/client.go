@@ -2,17 +2,24 @@ package exoscale
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
 	"fmt"
-	"os"
+	"math"
+	"net/http"
 	"path/filepath"
 	"sync"
+	"time"
 
 	v3 "github.com/exoscale/egoscale/v3"
 	"github.com/exoscale/egoscale/v3/credentials"
 
 	"gopkg.in/fsnotify.v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
 )
 
 type exoscaleClient interface {
@@ -20,9 +27,13 @@ type exoscaleClient interface {
 	AddServiceToLoadBalancer(ctx context.Context, id v3.UUID, req v3.AddServiceToLoadBalancerRequest) (*v3.Operation, error)
 	DeleteLoadBalancer(ctx context.Context, id v3.UUID) (*v3.Operation, error)
 	DeleteLoadBalancerService(ctx context.Context, id v3.UUID, serviceID v3.UUID) (*v3.Operation, error)
+	GetElasticIP(ctx context.Context, id v3.UUID) (*v3.ElasticIP, error)
 	GetInstance(ctx context.Context, id v3.UUID) (*v3.Instance, error)
+	GetInstancePool(ctx context.Context, id v3.UUID) (*v3.InstancePool, error)
 	GetInstanceType(ctx context.Context, id v3.UUID) (*v3.InstanceType, error)
 	GetLoadBalancer(ctx context.Context, id v3.UUID) (*v3.LoadBalancer, error)
+	GetOperation(ctx context.Context, id v3.UUID) (*v3.Operation, error)
+	GetPrivateNetwork(ctx context.Context, id v3.UUID) (*v3.PrivateNetwork, error)
 	ListInstances(ctx context.Context, opts ...v3.ListInstancesOpt) (*v3.ListInstancesResponse, error)
 	ListLoadBalancers(ctx context.Context) (*v3.ListLoadBalancersResponse, error)
 	ListSKSClusters(ctx context.Context) (*v3.ListSKSClustersResponse, error)
@@ -38,16 +49,82 @@ type exoscaleAPICredentials struct {
 }
 
 type refreshableExoscaleClient struct {
-	exo            exoscaleClient
-	apiCredentials exoscaleAPICredentials
-	apiEndpoint    v3.Endpoint
+	exo                  exoscaleClient
+	apiCredentials       exoscaleAPICredentials
+	apiCredentialsSource string
+	apiEndpoint          v3.Endpoint
+	apiClientConfig      apiClientConfig
+	httpClient           *http.Client
+
+	credentialsChain credentialsChain
+	zone             v3.ZoneName
+	zoneCallback     switchZone
+	apiEnvironment   apiEnvironment
+
+	apiRoleID            v3.UUID
+	apiRoleTTL           time.Duration
+	apiCredentialsExpiry time.Time
+	assumeRoleCallback   assumeRole
+
+	// apiCredentialsErr reports the failure of the latest credentials
+	// refresh attempt, if any.
+	apiCredentialsErr   error
+	refreshCh           chan struct{}
+	refreshBackoff      wait.Backoff
+	validateCredentials validateCredentials
 
 	*sync.RWMutex
 }
 
-type switchZone func(ctx context.Context, client *v3.Client, zone v3.ZoneName) (*v3.Client, error)
+const (
+	// credentialsSecretSyncTimeout is the maximum time to wait for the
+	// credentials Secret watcher initial listing.
+	credentialsSecretSyncTimeout = time.Minute
 
-var switchZoneCallback switchZone = func(ctx context.Context, client *v3.Client, zone v3.ZoneName) (*v3.Client, error) {
+	// credentialsFileWatchRetryInterval is the delay between attempts to
+	// watch the credentials file directory (e.g. not mounted yet).
+	credentialsFileWatchRetryInterval = 10 * time.Second
+)
+
+// errCredentialsUnavailable is returned by the Exoscale client when no valid
+// API credentials could be retrieved yet.
+var errCredentialsUnavailable = errors.New("no valid Exoscale API credentials available")
+
+// credentialsRefreshBackoff is the backoff applied between failed API
+// credentials refresh attempts.
+var credentialsRefreshBackoff = wait.Backoff{
+	Duration: time.Second,
+	Factor:   2,
+	Jitter:   0.1,
+	Steps:    math.MaxInt32,
+	Cap:      5 * time.Minute,
+}
+
+type validateCredentials func(ctx context.Context, client *v3.Client) error
+
+// validateCredentialsCallback probes API credentials with a cheap read-only
+// call: listing the Compute instances matching an address from a reserved
+// documentation range (RFC 5737) always returns an empty list, but requires
+// valid credentials allowed to list instances, which the CCM needs anyway.
+var validateCredentialsCallback validateCredentials = func(ctx context.Context, client *v3.Client) error {
+	_, err := client.ListInstances(ctx, v3.ListInstancesWithIPAddress("192.0.2.1"))
+
+	return err
+}
+
+type switchZone func(ctx context.Context, client *v3.Client, env apiEnvironment, zone v3.ZoneName) (*v3.Client, error)
+
+// switchZoneCallback switches the client to the API endpoint of the zone
+// specified. The zones reported by the API carry their production endpoint,
+// which is only used as is in the default environment: in other ones, the
+// endpoint is derived from the environment template once the zone has been
+// found.
+var switchZoneCallback switchZone = func(
+	ctx context.Context,
+	client *v3.Client,
+	env apiEnvironment,
+	zone v3.ZoneName,
+) (*v3.Client, error) {
 	if zone == "" {
 		return client, nil
 	}
@@ -57,89 +134,241 @@ var switchZoneCallback switchZone = func(ctx context.Context, client *v3.Client,
 		return nil, err
 	}
 
+	if !env.isDefault() {
+		zoneEndpoint = env.endpoint(zone)
+	}
+
 	return client.WithEndpoint(zoneEndpoint), nil
 }
 
-func newRefreshableExoscaleClient(ctx context.Context, config *globalConfig, zone v3.ZoneName, zoneCallback switchZone) (*refreshableExoscaleClient, error) {
+func newRefreshableExoscaleClient(
+	ctx context.Context,
+	config *globalConfig,
+	kclient kubernetes.Interface,
+	zone v3.ZoneName,
+	zoneCallback switchZone,
+) (*refreshableExoscaleClient, error) {
+	apiClientConfig := config.APIClient.withDefaults()
+
+	transport, err := newHTTPTransport(config.HTTP)
+	if err != nil {
+		return nil, fmt.Errorf("invalid HTTP configuration: %w", err)
+	}
+
+	environment, err := newAPIEnvironment(config.APIEnvironment, config.APIEndpointTemplate)
+	if err != nil {
+		return nil, err
+	}
+
 	c := &refreshableExoscaleClient{
-		RWMutex: &sync.RWMutex{},
+		exo:              unavailableExoscaleClient{},
+		credentialsChain: newCredentialsChain(config, kclient),
+		zone:             zone,
+		zoneCallback:     zoneCallback,
+		apiEnvironment:   environment,
+		apiClientConfig:  apiClientConfig,
+		httpClient: newHTTPClient(
+			transport,
+			apiClientConfig,
+			newAPIRateLimiter(apiClientConfig.RateLimit),
+			newCircuitBreaker(apiClientConfig.CircuitBreaker),
+		),
+		refreshCh:           make(chan struct{}, 1),
+		refreshBackoff:      credentialsRefreshBackoff,
+		validateCredentials: validateCredentialsCallback,
+		RWMutex:             &sync.RWMutex{},
 	}
 
 	if config.APIEndpoint != "" {
 		c.apiEndpoint = v3.Endpoint(config.APIEndpoint)
 	}
 
-	if config.APIKey != "" && config.APISecret != "" { //nolint:gocritic
-		infof("using Exoscale actual API credentials (key + secret)")
-
-		c.apiCredentials = exoscaleAPICredentials{
-			APIKey:    config.APIKey,
-			APISecret: config.APISecret,
+	if config.APIRoleID != "" {
+		roleID, err := v3.ParseUUID(config.APIRoleID)
+		if err != nil {
+			return nil, fmt.Errorf("invalid IAM role ID %q: %w", config.APIRoleID, err)
 		}
 
-		var opts []v3.ClientOpt
-		if c.apiEndpoint != "" {
-			opts = append(opts, v3.ClientOptWithEndpoint(c.apiEndpoint))
+		c.apiRoleID = roleID
+		c.apiRoleTTL = config.APIRoleTTL
+		if c.apiRoleTTL == 0 {
+			c.apiRoleTTL = defaultAPIRoleTTL
 		}
+		c.assumeRoleCallback = assumeRoleCallback
+	}
 
-		opts = append(opts, v3.ClientOptWithUserAgent(
-			fmt.Sprintf("Exoscale-K8s-Cloud-Controller/%s", versionString),
-		))
-
-		//TODO add chain credentials with env...etc
-		creds := credentials.NewStaticCredentials(
-			c.apiCredentials.APIKey,
-			c.apiCredentials.APISecret,
-		)
-		exo, err := v3.NewClient(creds, opts...)
-		if err != nil {
+	// The Secret is watched before the credentials are first retrieved, so
+	// that no change happening in between can be missed.
+	if config.APICredentialsSecret != "" && kclient != nil {
+		infof("watching Exoscale API credentials Secret %s", config.APICredentialsSecret)
+		if err := c.watchCredentialsSecret(ctx, config.APICredentialsSecret, kclient); err != nil {
 			return nil, err
 		}
+	}
 
-		exo, err = zoneCallback(ctx, exo, zone)
-		if err != nil {
+	// Only the absence of any credentials source is fatal: other failures
+	// (e.g. a credentials file not written yet) are retried in the background.
+	if err := c.refreshCredentials(ctx); err != nil {
+		if errors.Is(err, errMissingCredentials) {
 			return nil, err
 		}
+		errorf("failed to initialize API credentials, retrying in the background: %v", err)
+	}
 
-		c.exo = exo
-	} else if config.APICredentialsFile != "" {
-		infof("reading (watching) Exoscale API credentials from file %q", config.APICredentialsFile)
+	if c.apiRoleID != "" {
+		infof("assuming IAM role %s, refreshing credentials before expiry", c.apiRoleID)
+	}
 
-		c.refreshCredentialsFromFile(ctx, config.APICredentialsFile, zone, zoneCallback)
-		go c.watchCredentialsFile(ctx, config.APICredentialsFile, zone, zoneCallback)
-	} else {
-		return nil, errors.New("incomplete or missing Exoscale API credentials")
+	if config.APICredentialsFile != "" {
+		infof("watching Exoscale API credentials file %q", config.APICredentialsFile)
+		go c.watchCredentialsFile(ctx, config.APICredentialsFile)
 	}
 
+	go c.runCredentialsRefresher(ctx)
+
 	return c, nil
 }
 
-func (c *refreshableExoscaleClient) Wait(ctx context.Context, op *v3.Operation, states ...v3.OperationState) (*v3.Operation, error) {
+// client returns the Exoscale client currently in use. The lock is only held
+// while taking this snapshot, so that API calls (and the waiting for the
+// resulting operations) never delay the swapping of refreshed credentials.
+func (c *refreshableExoscaleClient) client() exoscaleClient {
 	c.RLock()
 	defer c.RUnlock()
 
-	return c.exo.Wait(
+	return c.exo
+}
+
+func (c *refreshableExoscaleClient) GetOperation(ctx context.Context, id v3.UUID) (*v3.Operation, error) {
+	ctx, cancel := c.withTimeout(ctx, "GetOperation")
+	defer cancel()
+
+	return c.client().GetOperation(
 		ctx,
-		op,
-		states...,
+		id,
 	)
 }
 
-func (c *refreshableExoscaleClient) watchCredentialsFile(
-	ctx context.Context,
-	path string,
-	zone v3.ZoneName,
-	zoneCallback switchZone,
-) {
+// Wait waits for the async operation specified to complete, with the same
+// semantics as the egoscale client. The operation is polled with the client
+// current at each attempt, so that a credentials refresh happening while
+// waiting is honored.
+func (c *refreshableExoscaleClient) Wait(ctx context.Context, op *v3.Operation, states ...v3.OperationState) (*v3.Operation, error) {
+	const abortErrorsCount = 5
+
+	ctx, cancel := c.withTimeout(ctx, "Wait")
+	defer cancel()
+
+	if op == nil {
+		return nil, errors.New("operation is nil")
+	}
+
+	if op.State != v3.OperationStatePending {
+		return op, nil
+	}
+
+	var (
+		startTime        = time.Now()
+		subsequentErrors int
+		operation        *v3.Operation
+	)
+
+	timer := time.NewTimer(waitPollInterval(0))
+	defer timer.Stop()
+
+polling:
+	for {
+		select {
+		case <-timer.C:
+			timer.Reset(waitPollInterval(time.Since(startTime)))
+
+			o, err := c.GetOperation(ctx, op.ID)
+			if err != nil {
+				subsequentErrors++
+				if subsequentErrors >= abortErrorsCount {
+					return nil, err
+				}
+				continue
+			}
+			subsequentErrors = 0
+
+			if o.State == v3.OperationStatePending {
+				continue
+			}
+
+			operation = o
+			break polling
+
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	if len(states) == 0 {
+		return operation, nil
+	}
+
+	for _, st := range states {
+		if operation.State == st {
+			return operation, nil
+		}
+	}
+
+	var ref v3.OperationReference
+	if operation.Reference != nil {
+		ref = *operation.Reference
+	}
+
+	return nil,
+		fmt.Errorf("operation: %q %v, state: %s, reason: %q, message: %q",
+			operation.ID,
+			ref,
+			operation.State,
+			operation.Reason,
+			operation.Message,
+		)
+}
+
+// waitPollInterval returns the delay before the next operation poll, growing
+// linearly from 3s (after 30s) to 60s (after 15min) like the egoscale client.
+var waitPollInterval = func(runTime time.Duration) time.Duration {
+	const (
+		a       = 57.0 / 870.0
+		b       = 3.0 - 30.0*a
+		minWait = 3.0
+		maxWait = 60.0
+	)
+
+	interval := a*runTime.Seconds() + b
+	interval = math.Max(minWait, interval)
+	interval = math.Min(maxWait, interval)
+
+	return time.Duration(interval) * time.Second
+}
+
+func (c *refreshableExoscaleClient) watchCredentialsFile(ctx context.Context, path string) {
 	watcher, err := fsnotify.NewWatcher()
 	if err != nil {
-		fatalf("failed to watch credentials file %q: %v", path, err)
+		errorf("failed to watch credentials file %q: %v", path, err)
+		return
 	}
+	defer watcher.Close()
 
 	// We watch the folder because the file might get deleted and recreated.
-	err = watcher.Add(filepath.Dir(path))
-	if err != nil {
-		fatalf("failed to watch credentials file %q: %v", path, err)
+	for {
+		if err = watcher.Add(filepath.Dir(path)); err == nil {
+			break
+		}
+		errorf("failed to watch credentials file %q, retrying in %s: %v", path, credentialsFileWatchRetryInterval, err)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(credentialsFileWatchRetryInterval):
+		}
+
+		// The file might have been created in the meantime.
+		c.requestCredentialsRefresh()
 	}
 
 	for {
@@ -153,7 +382,7 @@ func (c *refreshableExoscaleClient) watchCredentialsFile(
 			if event.Name == path &&
 				(event.Op&fsnotify.Write == fsnotify.Write || event.Op&fsnotify.Create == fsnotify.Create) {
 				infof("refreshing API credentials from file %q", path)
-				c.refreshCredentialsFromFile(ctx, path, zone, zoneCallback)
+				c.requestCredentialsRefresh()
 			}
 
 		case err, ok := <-watcher.Errors:
@@ -165,61 +394,297 @@ func (c *refreshableExoscaleClient) watchCredentialsFile(
 
 		case <-ctx.Done():
 			infof("closing credentials file watcher")
-			_ = watcher.Close()
 			return
 		}
 	}
 }
 
-func (c *refreshableExoscaleClient) refreshCredentialsFromFile(
+// watchCredentialsSecret refreshes the API credentials every time the
+// Kubernetes Secret specified (as "<namespace>/<name>") changes.
+func (c *refreshableExoscaleClient) watchCredentialsSecret(
 	ctx context.Context,
-	path string,
-	zone v3.ZoneName,
-	zoneCallback switchZone,
-) {
-	f, err := os.Open(path)
+	secret string,
+	kclient kubernetes.Interface,
+) error {
+	namespace, name, err := parseCredentialsSecret(secret)
 	if err != nil {
-		fatalf("failed to read credentials file %q: %v", path, err)
+		return err
 	}
-	defer f.Close()
 
-	var apiCredentials exoscaleAPICredentials
-	if err = json.NewDecoder(f).Decode(&apiCredentials); err != nil {
-		infof("failed to decode credentials file %q: %v", path, err)
-		return
+	informerFactory := informers.NewSharedInformerFactoryWithOptions(
+		kclient,
+		0,
+		informers.WithNamespace(namespace),
+		informers.WithTweakListOptions(func(opts *metav1.ListOptions) {
+			opts.FieldSelector = fields.OneTermEqualSelector("metadata.name", name).String()
+		}),
+	)
+
+	refresh := func(reason string) {
+		infof("refreshing API credentials from Secret %s (%s)", secret, reason)
+		c.requestCredentialsRefresh()
 	}
 
-	var opts []v3.ClientOpt
-	if c.apiEndpoint != "" {
-		opts = append(opts, v3.ClientOptWithEndpoint(c.apiEndpoint))
+	_, err = informerFactory.Core().V1().Secrets().Informer().AddEventHandler(cache.ResourceEventHandlerDetailedFuncs{
+		AddFunc: func(_ interface{}, isInInitialList bool) {
+			// The credentials are retrieved once the initial listing is
+			// done, only later additions (i.e. re-creation of the Secret)
+			// are relevant.
+			if !isInInitialList {
+				refresh("created")
+			}
+		},
+		UpdateFunc: func(_, _ interface{}) {
+			refresh("updated")
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to watch credentials Secret %s: %w", secret, err)
 	}
 
-	opts = append(opts, v3.ClientOptWithUserAgent(
-		fmt.Sprintf("Exoscale-K8s-Cloud-Controller/%s", versionString),
-	))
+	informerFactory.Start(ctx.Done())
 
-	//TODO add chain credentials with env...etc
-	creds := credentials.NewStaticCredentials(apiCredentials.APIKey, apiCredentials.APISecret)
-	client, err := v3.NewClient(creds, opts...)
+	syncCtx, cancel := context.WithTimeout(ctx, credentialsSecretSyncTimeout)
+	defer cancel()
+	for _, synced := range informerFactory.WaitForCacheSync(syncCtx.Done()) {
+		if !synced {
+			errorf("timed out waiting for the credentials Secret %s watcher to sync", secret)
+		}
+	}
+
+	return nil
+}
+
+// requestCredentialsRefresh notifies the credentials refresher that the API
+// credentials should be refreshed.
+func (c *refreshableExoscaleClient) requestCredentialsRefresh() {
+	select {
+	case c.refreshCh <- struct{}{}:
+	default: // A refresh is already pending.
+	}
+}
+
+// runCredentialsRefresher refreshes the API credentials upon request, before
+// the IAM role credentials expiry, and retries failed refreshes with an
+// exponential backoff until ctx is done.
+func (c *refreshableExoscaleClient) runCredentialsRefresher(ctx context.Context) {
+	var (
+		backoff = c.refreshBackoff
+		retry   <-chan time.Time
+	)
+
+	for {
+		var renew <-chan time.Time
+		c.RLock()
+		if !c.apiCredentialsExpiry.IsZero() && c.apiCredentialsErr == nil {
+			renew = time.After(time.Until(c.apiCredentialsExpiry.Add(-c.apiRoleTTL / 4)))
+		}
+		if c.apiCredentialsErr != nil && retry == nil {
+			retry = time.After(backoff.Step())
+		}
+		c.RUnlock()
+
+		select {
+		case <-ctx.Done():
+			infof("stopping API credentials refresher")
+			return
+
+		case <-c.refreshCh:
+			backoff = c.refreshBackoff
+
+		case <-retry:
+
+		case <-renew:
+			infof("renewing IAM role %s credentials", c.apiRoleID)
+		}
+
+		if err := c.refreshCredentials(ctx); err != nil {
+			delay := backoff.Step()
+			errorf("failed to refresh API credentials, retrying in %s: %v", delay.Round(time.Second), err)
+			retry = time.After(delay)
+			continue
+		}
+
+		backoff = c.refreshBackoff
+		retry = nil
+	}
+}
+
+// refreshCredentials retrieves the Exoscale API credentials from the
+// credentials chain, and swaps the client in use for one using them once
+// validated. On failure, the client in use is kept.
+func (c *refreshableExoscaleClient) refreshCredentials(ctx context.Context) error {
+	err := c.tryRefreshCredentials(withAPICaller(ctx, apiCallerCredentials))
+
+	c.Lock()
+	c.apiCredentialsErr = err
+	c.Unlock()
+
+	setAPICredentialsHealth(err == nil)
+
+	return err
+}
+
+func (c *refreshableExoscaleClient) tryRefreshCredentials(ctx context.Context) error {
+	apiCredentials, source, err := c.credentialsChain.retrieve(ctx)
 	if err != nil {
-		infof("failed to initialize Exoscale client: %v", err)
-		return
+		return err
 	}
 
-	client, err = zoneCallback(ctx, client, zone)
+	client, err := c.newClient(ctx, apiCredentials)
 	if err != nil {
-		errorf("failed to switch client zone: %v", err)
-		return
+		return err
+	}
+
+	var expiry time.Time
+	if c.apiRoleID != "" {
+		if apiCredentials, err = c.assumeRoleCallback(ctx, client, c.apiRoleID, c.apiRoleTTL); err != nil {
+			return fmt.Errorf("failed to assume IAM role %s: %w", c.apiRoleID, err)
+		}
+		expiry = time.Now().Add(c.apiRoleTTL)
+
+		if client, err = c.newClient(ctx, apiCredentials); err != nil {
+			return err
+		}
+	}
+
+	if err = c.validateCredentials(ctx, client); err != nil {
+		return fmt.Errorf("API credentials %s (%s) from %s failed validation: %w",
+			apiCredentials.Name, apiCredentials.APIKey, source, err)
 	}
 
 	c.Lock()
 	c.exo = client
 	c.apiCredentials = apiCredentials
+	c.apiCredentialsSource = source
+	c.apiCredentialsExpiry = expiry
 	c.Unlock()
 
+	setAPICredentialsSource(source)
+
 	infof(
-		"Exoscale API credentials refreshed, now using %s (%s)",
-		c.apiCredentials.Name,
-		c.apiCredentials.APIKey,
+		"Exoscale API credentials refreshed, now using %s (%s) from %s",
+		apiCredentials.Name,
+		apiCredentials.APIKey,
+		source,
 	)
+
+	return nil
+}
+
+// credentialsHealth returns the failure of the latest API credentials
+// refresh attempt, if any.
+func (c *refreshableExoscaleClient) credentialsHealth() error {
+	c.RLock()
+	defer c.RUnlock()
+
+	return c.apiCredentialsErr
+}
+
+// newClient returns an Exoscale API client using the credentials specified,
+// switched to the client zone.
+func (c *refreshableExoscaleClient) newClient(ctx context.Context, apiCredentials exoscaleAPICredentials) (*v3.Client, error) {
+	var opts []v3.ClientOpt
+	if c.apiEndpoint != "" {
+		opts = append(opts, v3.ClientOptWithEndpoint(c.apiEndpoint))
+	}
+
+	opts = append(opts,
+		v3.ClientOptWithUserAgent(fmt.Sprintf("Exoscale-K8s-Cloud-Controller/%s", versionString)),
+		v3.ClientOptWithHTTPClient(c.httpClient),
+	)
+
+	creds := credentials.NewStaticCredentials(apiCredentials.APIKey, apiCredentials.APISecret)
+	client, err := v3.NewClient(creds, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize Exoscale client: %w", err)
+	}
+
+	client, err = c.zoneCallback(ctx, client, c.apiEnvironment, c.zone)
+	if err != nil {
+		return nil, fmt.Errorf("failed to switch client zone: %w", err)
+	}
+
+	return client, nil
+}
+
+// unavailableExoscaleClient is the Exoscale client in use until valid API
+// credentials have been retrieved.
+type unavailableExoscaleClient struct{}
+
+func (unavailableExoscaleClient) CreateLoadBalancer(context.Context, v3.CreateLoadBalancerRequest) (*v3.Operation, error) {
+	return nil, errCredentialsUnavailable
+}
+
+func (unavailableExoscaleClient) AddServiceToLoadBalancer(
+	context.Context,
+	v3.UUID,
+	v3.AddServiceToLoadBalancerRequest,
+) (*v3.Operation, error) {
+	return nil, errCredentialsUnavailable
+}
+
+func (unavailableExoscaleClient) DeleteLoadBalancer(context.Context, v3.UUID) (*v3.Operation, error) {
+	return nil, errCredentialsUnavailable
+}
+
+func (unavailableExoscaleClient) DeleteLoadBalancerService(context.Context, v3.UUID, v3.UUID) (*v3.Operation, error) {
+	return nil, errCredentialsUnavailable
+}
+
+func (unavailableExoscaleClient) GetElasticIP(context.Context, v3.UUID) (*v3.ElasticIP, error) {
+	return nil, errCredentialsUnavailable
+}
+
+func (unavailableExoscaleClient) GetInstance(context.Context, v3.UUID) (*v3.Instance, error) {
+	return nil, errCredentialsUnavailable
+}
+
+func (unavailableExoscaleClient) GetInstancePool(context.Context, v3.UUID) (*v3.InstancePool, error) {
+	return nil, errCredentialsUnavailable
+}
+
+func (unavailableExoscaleClient) GetInstanceType(context.Context, v3.UUID) (*v3.InstanceType, error) {
+	return nil, errCredentialsUnavailable
+}
+
+func (unavailableExoscaleClient) GetLoadBalancer(context.Context, v3.UUID) (*v3.LoadBalancer, error) {
+	return nil, errCredentialsUnavailable
+}
+
+func (unavailableExoscaleClient) GetOperation(context.Context, v3.UUID) (*v3.Operation, error) {
+	return nil, errCredentialsUnavailable
+}
+
+func (unavailableExoscaleClient) GetPrivateNetwork(context.Context, v3.UUID) (*v3.PrivateNetwork, error) {
+	return nil, errCredentialsUnavailable
+}
+
+func (unavailableExoscaleClient) ListInstances(context.Context, ...v3.ListInstancesOpt) (*v3.ListInstancesResponse, error) {
+	return nil, errCredentialsUnavailable
+}
+
+func (unavailableExoscaleClient) ListLoadBalancers(context.Context) (*v3.ListLoadBalancersResponse, error) {
+	return nil, errCredentialsUnavailable
+}
+
+func (unavailableExoscaleClient) ListSKSClusters(context.Context) (*v3.ListSKSClustersResponse, error) {
+	return nil, errCredentialsUnavailable
+}
+
+func (unavailableExoscaleClient) UpdateLoadBalancer(context.Context, v3.UUID, v3.UpdateLoadBalancerRequest) (*v3.Operation, error) {
+	return nil, errCredentialsUnavailable
+}
+
+func (unavailableExoscaleClient) UpdateLoadBalancerService(
+	context.Context,
+	v3.UUID,
+	v3.UUID,
+	v3.UpdateLoadBalancerServiceRequest,
+) (*v3.Operation, error) {
+	return nil, errCredentialsUnavailable
+}
+
+func (unavailableExoscaleClient) Wait(context.Context, *v3.Operation, ...v3.OperationState) (*v3.Operation, error) {
+	return nil, errCredentialsUnavailable
 }
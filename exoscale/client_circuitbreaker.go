@@ -0,0 +1,166 @@
+package exoscale
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	defaultAPICircuitBreakerFailureThreshold = 5
+	defaultAPICircuitBreakerOpenDuration     = 30 * time.Second
+)
+
+// errAPICircuitOpen is returned for non-essential API calls skipped while the
+// Exoscale API is failing.
+var errAPICircuitOpen = errors.New("circuit breaker open because of Exoscale API failures, skipping non-essential call")
+
+// apiCircuitBreakerConfig represents the Exoscale API client circuit breaker
+// configuration.
+type apiCircuitBreakerConfig struct {
+	Disabled bool `yaml:"disabled"`
+	// FailureThreshold is the number of consecutive failed API requests
+	// opening the circuit.
+	FailureThreshold int `yaml:"failureThreshold"`
+	// OpenDuration is the time after which non-essential calls are allowed
+	// again to probe whether the API has recovered.
+	OpenDuration time.Duration `yaml:"openDuration"`
+}
+
+type circuitState int
+
+const (
+	circuitClosed circuitState = iota
+	circuitHalfOpen
+	circuitOpen
+)
+
+func (s circuitState) String() string {
+	switch s {
+	case circuitClosed:
+		return "closed"
+	case circuitHalfOpen:
+		return "half-open"
+	case circuitOpen:
+		return "open"
+	}
+
+	return "unknown"
+}
+
+// circuitBreaker tracks the Exoscale API failures: once the API has failed
+// too many times in a row, the circuit opens and non-essential calls are
+// rejected until a request succeeds again. Essential calls are never
+// rejected, the controllers performing them having their own backoff.
+type circuitBreaker struct {
+	failureThreshold int
+	openDuration     time.Duration
+	now              func() time.Time
+
+	mu       sync.Mutex
+	state    circuitState
+	failures int
+	openedAt time.Time
+}
+
+// newCircuitBreaker returns a new API circuit breaker, or nil if it is
+// disabled.
+func newCircuitBreaker(config apiCircuitBreakerConfig) *circuitBreaker {
+	if config.Disabled {
+		return nil
+	}
+
+	metricAPICircuitBreakerState.Set(float64(circuitClosed))
+
+	return &circuitBreaker{
+		failureThreshold: config.FailureThreshold,
+		openDuration:     config.OpenDuration,
+		now:              time.Now,
+	}
+}
+
+// allow returns whether an API call is allowed.
+func (b *circuitBreaker) allow(caller apiCaller) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == circuitOpen && b.now().Sub(b.openedAt) >= b.openDuration {
+		b.setState(circuitHalfOpen)
+	}
+
+	return b.state != circuitOpen || !caller.nonEssential
+}
+
+// record records the outcome of an API call.
+func (b *circuitBreaker) record(success bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if success {
+		b.failures = 0
+		if b.state != circuitClosed {
+			b.setState(circuitClosed)
+		}
+		return
+	}
+
+	b.failures++
+	if b.state == circuitOpen {
+		b.openedAt = b.now()
+		return
+	}
+
+	if b.state == circuitHalfOpen || b.failures >= b.failureThreshold {
+		b.openedAt = b.now()
+		b.setState(circuitOpen)
+	}
+}
+
+func (b *circuitBreaker) setState(state circuitState) {
+	if state == circuitOpen {
+		errorf("Exoscale API circuit breaker %s after %d consecutive failures, "+
+			"skipping non-essential calls", state, b.failures)
+	} else {
+		infof("Exoscale API circuit breaker %s", state)
+	}
+
+	b.state = state
+	metricAPICircuitBreakerState.Set(float64(state))
+}
+
+// circuitBreakerTransport is an HTTP transport rejecting non-essential
+// requests while the circuit breaker is open, and recording the outcome of
+// the other ones.
+type circuitBreakerTransport struct {
+	next    http.RoundTripper
+	breaker *circuitBreaker
+}
+
+func (t *circuitBreakerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	caller := apiCallerFromContext(req.Context())
+	if !t.breaker.allow(caller) {
+		metricAPIRequestsRejected.WithLabelValues(caller.name).Inc()
+		return nil, fmt.Errorf("%w: %s %s", errAPICircuitOpen, req.Method, req.URL.Path)
+	}
+
+	res, err := t.next.RoundTrip(req)
+	switch {
+	case err != nil:
+		// Calls cancelled by the caller or delayed by the client-side rate
+		// limit don't tell anything about the API health.
+		if !errors.Is(err, context.Canceled) && !errors.Is(err, errAPIRateLimitExceeded) {
+			t.breaker.record(false)
+		}
+
+	case res.StatusCode == http.StatusTooManyRequests || res.StatusCode >= http.StatusInternalServerError:
+		t.breaker.record(false)
+
+	default:
+		t.breaker.record(true)
+	}
+
+	return res, err
+}
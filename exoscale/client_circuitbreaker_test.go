@@ -0,0 +1,79 @@
+package exoscale
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"time"
+)
+
+func (ts *exoscaleCCMTestSuite) Test_circuitBreaker() {
+	var (
+		now        = time.Now()
+		essential  = apiCaller{name: apiCallerService}
+		background = apiCaller{name: apiCallerSKSAgent, nonEssential: true}
+	)
+
+	breaker := newCircuitBreaker(apiCircuitBreakerConfig{FailureThreshold: 3, OpenDuration: time.Minute})
+	breaker.now = func() time.Time { return now }
+
+	for range 2 {
+		breaker.record(false)
+	}
+	ts.Require().Equal(circuitClosed, breaker.state)
+	ts.Require().True(breaker.allow(background))
+
+	breaker.record(false)
+	ts.Require().Equal(circuitOpen, breaker.state)
+	ts.Require().False(breaker.allow(background))
+	ts.Require().True(breaker.allow(essential))
+
+	// A probe is allowed once the open duration has elapsed, but the
+	// circuit opens again right away if it fails.
+	now = now.Add(time.Minute)
+	ts.Require().True(breaker.allow(background))
+	ts.Require().Equal(circuitHalfOpen, breaker.state)
+	breaker.record(false)
+	ts.Require().Equal(circuitOpen, breaker.state)
+	ts.Require().False(breaker.allow(background))
+
+	// Any successful call closes the circuit.
+	breaker.record(true)
+	ts.Require().Equal(circuitClosed, breaker.state)
+	ts.Require().True(breaker.allow(background))
+}
+
+func (ts *exoscaleCCMTestSuite) Test_circuitBreakerTransport() {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		requests.Add(1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	maxRetries := 0
+	client := newHTTPClient(
+		http.DefaultTransport,
+		apiClientConfig{MaxRetries: &maxRetries}.withDefaults(),
+		nil,
+		newCircuitBreaker(apiCircuitBreakerConfig{FailureThreshold: 1, OpenDuration: time.Hour}),
+	)
+
+	do := func(ctx context.Context) error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, server.URL, nil)
+		ts.Require().NoError(err)
+
+		res, err := client.Do(req)
+		if err == nil {
+			res.Body.Close()
+		}
+
+		return err
+	}
+
+	ts.Require().NoError(do(context.Background()))
+	ts.Require().ErrorIs(do(withNonEssentialAPICalls(context.Background())), errAPICircuitOpen)
+	ts.Require().NoError(do(context.Background()))
+	ts.Require().Equal(int32(2), requests.Load())
+}
@@ -0,0 +1,345 @@
+package exoscale
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	v3 "github.com/exoscale/egoscale/v3"
+)
+
+// newTestRetryServer returns a test HTTP server failing with the status
+// specified the first failures requests, and succeeding afterwards.
+func newTestRetryServer(status, failures int, header http.Header, attempts *atomic.Int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if attempts.Add(1) <= int32(failures) {
+			for k, v := range header {
+				w.Header()[k] = v
+			}
+			w.WriteHeader(status)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(v3.Operation{State: v3.OperationStateSuccess})
+	}))
+}
+
+func newTestRetryHTTPClient(maxRetries int) *http.Client {
+	return newHTTPClient(http.DefaultTransport, apiClientConfig{
+		MaxRetries:      &maxRetries,
+		RetryMinBackoff: time.Millisecond,
+		RetryMaxBackoff: 10 * time.Millisecond,
+	}.withDefaults(), nil, nil)
+}
+
+func (ts *exoscaleCCMTestSuite) Test_retryTransport_idempotent() {
+	var attempts atomic.Int32
+	server := newTestRetryServer(http.StatusServiceUnavailable, 2, nil, &attempts)
+	defer server.Close()
+
+	res, err := newTestRetryHTTPClient(4).Get(server.URL)
+	ts.Require().NoError(err)
+	defer res.Body.Close()
+	ts.Require().Equal(http.StatusOK, res.StatusCode)
+	ts.Require().Equal(int32(3), attempts.Load())
+}
+
+func (ts *exoscaleCCMTestSuite) Test_retryTransport_max_retries() {
+	var attempts atomic.Int32
+	server := newTestRetryServer(http.StatusServiceUnavailable, 10, nil, &attempts)
+	defer server.Close()
+
+	res, err := newTestRetryHTTPClient(2).Get(server.URL)
+	ts.Require().NoError(err)
+	defer res.Body.Close()
+	ts.Require().Equal(http.StatusServiceUnavailable, res.StatusCode)
+	ts.Require().Equal(int32(3), attempts.Load())
+}
+
+func (ts *exoscaleCCMTestSuite) Test_retryTransport_non_idempotent() {
+	var attempts atomic.Int32
+	server := newTestRetryServer(http.StatusServiceUnavailable, 1, nil, &attempts)
+	defer server.Close()
+
+	res, err := newTestRetryHTTPClient(4).Post(server.URL, "application/json", strings.NewReader("{}"))
+	ts.Require().NoError(err)
+	defer res.Body.Close()
+	ts.Require().Equal(http.StatusServiceUnavailable, res.StatusCode)
+	ts.Require().Equal(int32(1), attempts.Load())
+}
+
+func (ts *exoscaleCCMTestSuite) Test_retryTransport_rate_limited() {
+	var attempts atomic.Int32
+	server := newTestRetryServer(
+		http.StatusTooManyRequests,
+		1,
+		http.Header{"Retry-After": []string{"1"}},
+		&attempts,
+	)
+	defer server.Close()
+
+	start := time.Now()
+	res, err := newTestRetryHTTPClient(4).Post(server.URL, "application/json", strings.NewReader("{}"))
+	ts.Require().NoError(err)
+	defer res.Body.Close()
+	ts.Require().Equal(http.StatusOK, res.StatusCode)
+	ts.Require().Equal(int32(2), attempts.Load())
+
+	// The delay requested by the server is capped to the maximum backoff.
+	ts.Require().Less(time.Since(start), time.Second)
+}
+
+func (ts *exoscaleCCMTestSuite) Test_retryTransport_rate_limited_deadline() {
+	var attempts atomic.Int32
+	server := newTestRetryServer(
+		http.StatusTooManyRequests,
+		1,
+		http.Header{"Retry-After": []string{"30"}},
+		&attempts,
+	)
+	defer server.Close()
+
+	maxRetries := 4
+	client := newHTTPClient(http.DefaultTransport, apiClientConfig{
+		MaxRetries:      &maxRetries,
+		RetryMaxBackoff: time.Minute,
+	}.withDefaults(), nil, nil)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, server.URL, nil)
+	ts.Require().NoError(err)
+
+	// The request fails fast instead of waiting for its deadline.
+	start := time.Now()
+	res, err := client.Do(req)
+	ts.Require().NoError(err)
+	defer res.Body.Close()
+	ts.Require().Equal(http.StatusTooManyRequests, res.StatusCode)
+	ts.Require().Equal(int32(1), attempts.Load())
+	ts.Require().Less(time.Since(start), time.Second)
+}
+
+func (ts *exoscaleCCMTestSuite) Test_parseRetryAfter() {
+	d, ok := parseRetryAfter("3")
+	ts.Require().True(ok)
+	ts.Require().Equal(3*time.Second, d)
+
+	d, ok = parseRetryAfter(time.Now().Add(-time.Minute).UTC().Format(http.TimeFormat))
+	ts.Require().True(ok)
+	ts.Require().Equal(time.Duration(0), d)
+
+	_, ok = parseRetryAfter("soon")
+	ts.Require().False(ok)
+}
+
+func (ts *exoscaleCCMTestSuite) Test_refreshableExoscaleClient_method_timeout() {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer server.Close()
+	defer close(release)
+
+	client := newTestRefreshableExoscaleClient(&globalConfig{
+		APIKey:    testAPIKey,
+		APISecret: testAPISecret,
+		APIClient: apiClientConfig{
+			MethodTimeouts: map[string]time.Duration{"GetInstance": 50 * time.Millisecond},
+		},
+	}, nil)
+	client.apiEndpoint = v3.Endpoint(server.URL)
+	ts.Require().NoError(client.refreshCredentials(context.Background()))
+
+	_, err := client.GetInstance(context.Background(), v3.UUID(ts.randomID()))
+	ts.Require().ErrorIs(err, context.DeadlineExceeded)
+}
+
+func (ts *exoscaleCCMTestSuite) Test_apiClientConfig_timeout() {
+	config := apiClientConfig{
+		MethodTimeouts: map[string]time.Duration{"ListInstances": 2 * time.Minute},
+	}.withDefaults()
+
+	ts.Require().Equal(defaultAPITimeout, config.timeout("GetInstance"))
+	ts.Require().Equal(2*time.Minute, config.timeout("ListInstances"))
+	ts.Require().Equal(defaultAPIWaitTimeout, config.timeout("Wait"))
+	ts.Require().Equal(defaultAPIMaxRetries, *config.MaxRetries)
+}
+
+func (ts *exoscaleCCMTestSuite) Test_newHTTPTransport_proxy() {
+	var proxied atomic.Int32
+	proxy := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		if r.URL.Host == "api.exoscale.example" {
+			proxied.Add(1)
+		}
+	}))
+	defer proxy.Close()
+
+	transport, err := newHTTPTransport(httpConfig{HTTPProxy: proxy.URL})
+	ts.Require().NoError(err)
+
+	res, err := (&http.Client{Transport: transport}).Get("http://api.exoscale.example/")
+	ts.Require().NoError(err)
+	defer res.Body.Close()
+	ts.Require().Equal(int32(1), proxied.Load())
+
+	_, err = newHTTPTransport(httpConfig{HTTPSProxy: "http://proxy:port"})
+	ts.Require().Error(err)
+}
+
+func (ts *exoscaleCCMTestSuite) Test_newHTTPTransport_proxy_environment() {
+	ts.T().Setenv("HTTPS_PROXY", "http://env-proxy:3128")
+	ts.T().Setenv("NO_PROXY", "api.internal.example")
+
+	// The environment variables not overridden by the configuration apply.
+	transport, err := newHTTPTransport(httpConfig{HTTPProxy: "http://proxy:3128"})
+	ts.Require().NoError(err)
+
+	for target, expected := range map[string]string{
+		"http://api.exoscale.example/":  "http://proxy:3128",
+		"https://api.exoscale.example/": "http://env-proxy:3128",
+		"https://api.internal.example/": "",
+	} {
+		proxy, err := transport.Proxy(httptest.NewRequest(http.MethodGet, target, nil))
+		ts.Require().NoError(err)
+		if expected == "" {
+			ts.Require().Nil(proxy, target)
+		} else {
+			ts.Require().Equal(expected, proxy.String(), target)
+		}
+	}
+}
+
+func (ts *exoscaleCCMTestSuite) Test_newMetadataHTTPClient() {
+	tmpdir, err := os.MkdirTemp(os.TempDir(), "exoscale-ccm")
+	ts.Require().NoError(err)
+	defer os.RemoveAll(tmpdir)
+
+	certFile, keyFile := path.Join(tmpdir, "client.pem"), path.Join(tmpdir, "client-key.pem")
+	ts.writeTestClientCertificate(certFile, keyFile)
+
+	// The link-local metadata server must never be reached through a proxy,
+	// the other HTTP settings apply.
+	client, err := newMetadataHTTPClient(httpConfig{
+		HTTPProxy: "http://proxy:3128",
+		CertFile:  certFile,
+		KeyFile:   keyFile,
+	})
+	ts.Require().NoError(err)
+	transport, ok := client.Transport.(*http.Transport)
+	ts.Require().True(ok)
+	ts.Require().Nil(transport.Proxy)
+	ts.Require().NotNil(transport.TLSClientConfig.GetClientCertificate)
+
+	_, err = newMetadataHTTPClient(httpConfig{CAFiles: []string{path.Join(tmpdir, "missing.pem")}})
+	ts.Require().Error(err)
+}
+
+func (ts *exoscaleCCMTestSuite) Test_httpConfig_validate() {
+	tmpdir, err := os.MkdirTemp(os.TempDir(), "exoscale-ccm")
+	ts.Require().NoError(err)
+	defer os.RemoveAll(tmpdir)
+
+	certFile, keyFile := path.Join(tmpdir, "client.pem"), path.Join(tmpdir, "client-key.pem")
+	ts.writeTestClientCertificate(certFile, keyFile)
+
+	ts.Require().NoError(httpConfig{}.validate())
+	ts.Require().NoError(httpConfig{CertFile: certFile, KeyFile: keyFile}.validate())
+	ts.Require().ErrorContains(httpConfig{CertFile: certFile}.validate(), "specified together")
+	ts.Require().ErrorContains(httpConfig{CertFile: keyFile, KeyFile: certFile}.validate(), "client certificate")
+	ts.Require().ErrorContains(httpConfig{CAFiles: []string{path.Join(tmpdir, "missing.pem")}}.validate(), "CA file")
+}
+
+func (ts *exoscaleCCMTestSuite) Test_newHTTPTransport_tls() {
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if len(r.TLS.PeerCertificates) == 0 {
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	server.TLS = &tls.Config{ClientAuth: tls.RequestClientCert}
+	server.StartTLS()
+	defer server.Close()
+
+	tmpdir, err := os.MkdirTemp(os.TempDir(), "exoscale-ccm")
+	ts.Require().NoError(err)
+	defer os.RemoveAll(tmpdir)
+
+	caFile := path.Join(tmpdir, "ca.pem")
+	ts.Require().NoError(os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{
+		Type:  "CERTIFICATE",
+		Bytes: server.Certificate().Raw,
+	}), 0o600))
+
+	certFile, keyFile := path.Join(tmpdir, "client.pem"), path.Join(tmpdir, "client-key.pem")
+	ts.writeTestClientCertificate(certFile, keyFile)
+
+	get := func(config httpConfig) (int, error) {
+		transport, err := newHTTPTransport(config)
+		ts.Require().NoError(err)
+
+		res, err := (&http.Client{Transport: transport}).Get(server.URL)
+		if err != nil {
+			return 0, err
+		}
+		defer res.Body.Close()
+
+		return res.StatusCode, nil
+	}
+
+	// The server certificate isn't trusted without the extra CA bundle.
+	_, err = get(httpConfig{})
+	ts.Require().Error(err)
+
+	status, err := get(httpConfig{CAFiles: []string{caFile}})
+	ts.Require().NoError(err)
+	ts.Require().Equal(http.StatusUnauthorized, status)
+
+	status, err = get(httpConfig{CAFiles: []string{caFile}, CertFile: certFile, KeyFile: keyFile})
+	ts.Require().NoError(err)
+	ts.Require().Equal(http.StatusOK, status)
+
+	_, err = newHTTPTransport(httpConfig{CertFile: certFile})
+	ts.Require().Error(err)
+
+	_, err = newHTTPTransport(httpConfig{CAFiles: []string{keyFile}})
+	ts.Require().ErrorContains(err, "no PEM CA certificate")
+}
+
+func (ts *exoscaleCCMTestSuite) writeTestClientCertificate(certFile, keyFile string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	ts.Require().NoError(err)
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "exoscale-ccm"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	cert, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	ts.Require().NoError(err)
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	ts.Require().NoError(err)
+
+	ts.Require().NoError(os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert}), 0o600))
+	ts.Require().NoError(os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600))
+}
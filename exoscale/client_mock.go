@@ -46,11 +46,21 @@ func (m *exoscaleClientMock) DeleteLoadBalancerService(
 	return args.Get(0).(*v3.Operation), args.Error(1)
 }
 
+func (m *exoscaleClientMock) GetElasticIP(ctx context.Context, id v3.UUID) (*v3.ElasticIP, error) {
+	args := m.Called(ctx, id)
+	return args.Get(0).(*v3.ElasticIP), args.Error(1)
+}
+
 func (m *exoscaleClientMock) GetInstance(ctx context.Context, id v3.UUID) (*v3.Instance, error) {
 	args := m.Called(ctx, id)
 	return args.Get(0).(*v3.Instance), args.Error(1)
 }
 
+func (m *exoscaleClientMock) GetInstancePool(ctx context.Context, id v3.UUID) (*v3.InstancePool, error) {
+	args := m.Called(ctx, id)
+	return args.Get(0).(*v3.InstancePool), args.Error(1)
+}
+
 func (m *exoscaleClientMock) GetInstanceType(ctx context.Context, id v3.UUID) (*v3.InstanceType, error) {
 	args := m.Called(ctx, id)
 	return args.Get(0).(*v3.InstanceType), args.Error(1)
@@ -61,6 +71,16 @@ func (m *exoscaleClientMock) GetLoadBalancer(ctx context.Context, id v3.UUID) (*
 	return args.Get(0).(*v3.LoadBalancer), args.Error(1)
 }
 
+func (m *exoscaleClientMock) GetOperation(ctx context.Context, id v3.UUID) (*v3.Operation, error) {
+	args := m.Called(ctx, id)
+	return args.Get(0).(*v3.Operation), args.Error(1)
+}
+
+func (m *exoscaleClientMock) GetPrivateNetwork(ctx context.Context, id v3.UUID) (*v3.PrivateNetwork, error) {
+	args := m.Called(ctx, id)
+	return args.Get(0).(*v3.PrivateNetwork), args.Error(1)
+}
+
 func (m *exoscaleClientMock) ListInstances(
 	ctx context.Context,
 	opts ...v3.ListInstancesOpt,
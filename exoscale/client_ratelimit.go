@@ -0,0 +1,160 @@
+package exoscale
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// Names of the components calling the Exoscale API, used to share the API
+// rate limit fairly between them.
+const (
+	apiCallerDefault     = "default"
+	apiCallerCredentials = "credentials"
+	apiCallerNode        = "node"
+	apiCallerService     = "service"
+	apiCallerSKSAgent    = "sks-agent"
+)
+
+const (
+	defaultAPIRateLimitQPS   = 10
+	defaultAPIRateLimitBurst = 20
+)
+
+// errAPIRateLimitExceeded is returned when an API call can't be performed
+// before its deadline because of the client-side rate limit.
+var errAPIRateLimitExceeded = errors.New("client-side Exoscale API rate limit exceeded")
+
+// apiRateLimitConfig represents the Exoscale API client-side rate limit
+// configuration.
+type apiRateLimitConfig struct {
+	Disabled bool `yaml:"disabled"`
+	// QPS and Burst configure the token bucket shared by all the callers.
+	QPS   float64 `yaml:"qps"`
+	Burst int     `yaml:"burst"`
+	// CallerQPS is the maximum rate of a single caller (e.g. the service
+	// controller), so that it can't starve the other ones.
+	CallerQPS float64 `yaml:"callerQPS"`
+}
+
+type apiCallerContextKey struct{}
+
+// apiCaller describes the component performing an API call.
+type apiCaller struct {
+	name string
+	// nonEssential calls are rejected while the API circuit breaker is open.
+	nonEssential bool
+}
+
+// withAPICaller returns a context tagging the API calls performed with it as
+// originating from the caller specified.
+func withAPICaller(ctx context.Context, name string) context.Context {
+	caller := apiCallerFromContext(ctx)
+	if caller.name == name {
+		return ctx
+	}
+	caller.name = name
+
+	return context.WithValue(ctx, apiCallerContextKey{}, caller)
+}
+
+// withNonEssentialAPICalls returns a context tagging the API calls performed
+// with it as non-essential, i.e. to be skipped while the API is failing.
+func withNonEssentialAPICalls(ctx context.Context) context.Context {
+	caller := apiCallerFromContext(ctx)
+	caller.nonEssential = true
+
+	return context.WithValue(ctx, apiCallerContextKey{}, caller)
+}
+
+func apiCallerFromContext(ctx context.Context) apiCaller {
+	if caller, ok := ctx.Value(apiCallerContextKey{}).(apiCaller); ok {
+		return caller
+	}
+
+	return apiCaller{name: apiCallerDefault}
+}
+
+// apiRateLimiter is a token bucket rate limiter shared by all the Exoscale
+// API callers, each of them being additionally limited to its own share.
+type apiRateLimiter struct {
+	limiter     *rate.Limiter
+	callerLimit rate.Limit
+	callerBurst int
+
+	mu      sync.Mutex
+	callers map[string]*rate.Limiter
+}
+
+// newAPIRateLimiter returns a new API rate limiter, or nil if rate limiting
+// is disabled.
+func newAPIRateLimiter(config apiRateLimitConfig) *apiRateLimiter {
+	if config.Disabled {
+		return nil
+	}
+
+	return &apiRateLimiter{
+		limiter:     rate.NewLimiter(rate.Limit(config.QPS), config.Burst),
+		callerLimit: rate.Limit(config.CallerQPS),
+		callerBurst: max(config.Burst/2, 1),
+		callers:     make(map[string]*rate.Limiter),
+	}
+}
+
+func (l *apiRateLimiter) callerLimiter(name string) *rate.Limiter {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	limiter, ok := l.callers[name]
+	if !ok {
+		limiter = rate.NewLimiter(l.callerLimit, l.callerBurst)
+		l.callers[name] = limiter
+	}
+
+	return limiter
+}
+
+// wait blocks until the caller of the context specified is allowed to
+// perform an API request.
+func (l *apiRateLimiter) wait(ctx context.Context) error {
+	var (
+		caller = apiCallerFromContext(ctx)
+		start  = time.Now()
+	)
+
+	if err := l.callerLimiter(caller.name).Wait(ctx); err != nil {
+		return fmt.Errorf("%w: %w", errAPIRateLimitExceeded, err)
+	}
+
+	if err := l.limiter.Wait(ctx); err != nil {
+		return fmt.Errorf("%w: %w", errAPIRateLimitExceeded, err)
+	}
+
+	waited := time.Since(start)
+	metricAPIRateLimiterWait.WithLabelValues(caller.name).Observe(waited.Seconds())
+	if waited > time.Second {
+		debugf("%s: Exoscale API call delayed %s by the client-side rate limit", caller.name, waited)
+	}
+
+	return nil
+}
+
+// rateLimitTransport is an HTTP transport rate limiting every request
+// attempt (retries included).
+type rateLimitTransport struct {
+	next    http.RoundTripper
+	limiter *apiRateLimiter
+}
+
+func (t *rateLimitTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if err := t.limiter.wait(req.Context()); err != nil {
+		return nil, err
+	}
+
+	return t.next.RoundTrip(req)
+}
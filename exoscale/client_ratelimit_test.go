@@ -0,0 +1,63 @@
+package exoscale
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"time"
+)
+
+func (ts *exoscaleCCMTestSuite) Test_apiCallerFromContext() {
+	ctx := context.Background()
+	ts.Require().Equal(apiCaller{name: apiCallerDefault}, apiCallerFromContext(ctx))
+
+	ctx = withNonEssentialAPICalls(withAPICaller(ctx, apiCallerSKSAgent))
+	ts.Require().Equal(apiCaller{name: apiCallerSKSAgent, nonEssential: true}, apiCallerFromContext(ctx))
+}
+
+func (ts *exoscaleCCMTestSuite) Test_apiRateLimiter_fairness() {
+	limiter := newAPIRateLimiter(apiRateLimitConfig{QPS: 10, Burst: 4, CallerQPS: 1})
+
+	// The service controller burst is limited to its own share...
+	serviceCtx, cancel := context.WithTimeout(withAPICaller(context.Background(), apiCallerService), 100*time.Millisecond)
+	defer cancel()
+	ts.Require().NoError(limiter.wait(serviceCtx))
+	ts.Require().NoError(limiter.wait(serviceCtx))
+	ts.Require().ErrorIs(limiter.wait(serviceCtx), errAPIRateLimitExceeded)
+
+	// ... leaving tokens to the other callers.
+	nodeCtx, cancel := context.WithTimeout(withAPICaller(context.Background(), apiCallerNode), 100*time.Millisecond)
+	defer cancel()
+	ts.Require().NoError(limiter.wait(nodeCtx))
+	ts.Require().NoError(limiter.wait(nodeCtx))
+}
+
+func (ts *exoscaleCCMTestSuite) Test_rateLimitTransport() {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, _ *http.Request) {
+		requests.Add(1)
+	}))
+	defer server.Close()
+
+	maxRetries := 0
+	client := newHTTPClient(
+		http.DefaultTransport,
+		apiClientConfig{MaxRetries: &maxRetries}.withDefaults(),
+		newAPIRateLimiter(apiRateLimitConfig{QPS: 1, Burst: 2, CallerQPS: 1}),
+		nil,
+	)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, server.URL, nil)
+	ts.Require().NoError(err)
+
+	res, err := client.Do(req)
+	ts.Require().NoError(err)
+	res.Body.Close()
+
+	_, err = client.Do(req) //nolint:bodyclose
+	ts.Require().ErrorIs(err, errAPIRateLimitExceeded)
+	ts.Require().Equal(int32(1), requests.Load())
+}
@@ -0,0 +1,32 @@
+package exoscale
+
+import (
+	"context"
+	"time"
+
+	v3 "github.com/exoscale/egoscale/v3"
+)
+
+// defaultAPIRoleTTL is the default lifetime of the IAM role credentials,
+// which are renewed once three quarters of it have elapsed.
+const defaultAPIRoleTTL = time.Hour
+
+type assumeRole func(ctx context.Context, client *v3.Client, roleID v3.UUID, ttl time.Duration) (exoscaleAPICredentials, error)
+
+var assumeRoleCallback assumeRole = func(
+	ctx context.Context,
+	client *v3.Client,
+	roleID v3.UUID,
+	ttl time.Duration,
+) (exoscaleAPICredentials, error) {
+	res, err := client.AssumeIAMRole(ctx, roleID, v3.AssumeIAMRoleRequest{Ttl: int64(ttl.Seconds())})
+	if err != nil {
+		return exoscaleAPICredentials{}, err
+	}
+
+	return exoscaleAPICredentials{
+		APIKey:    res.Key,
+		APISecret: res.Secret,
+		Name:      res.Name,
+	}, nil
+}
@@ -3,24 +3,61 @@ package exoscale
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	v3 "github.com/exoscale/egoscale/v3"
+	"github.com/stretchr/testify/mock"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
 )
 
-var testZoneCallback switchZone = func(ctx context.Context, client *v3.Client, zone v3.ZoneName) (*v3.Client, error) {
+var testZoneCallback switchZone = func(_ context.Context, client *v3.Client, _ apiEnvironment, _ v3.ZoneName) (*v3.Client, error) {
 	return client, nil
 }
 
+func init() {
+	// The Exoscale API isn't reachable from tests.
+	validateCredentialsCallback = func(context.Context, *v3.Client) error { return nil }
+}
+
+func newTestRefreshableExoscaleClient(config *globalConfig, kclient kubernetes.Interface) *refreshableExoscaleClient {
+	return &refreshableExoscaleClient{
+		credentialsChain: newCredentialsChain(config, kclient),
+		zone:             v3.ZoneNameCHGva2,
+		zoneCallback:     testZoneCallback,
+		apiClientConfig:  config.APIClient.withDefaults(),
+		httpClient:       newHTTPClient(http.DefaultTransport, config.APIClient.withDefaults(), nil, nil),
+		refreshCh:        make(chan struct{}, 1),
+		refreshBackoff:   credentialsRefreshBackoff,
+		validateCredentials: func(context.Context, *v3.Client) error {
+			return nil
+		},
+		RWMutex: &sync.RWMutex{},
+	}
+}
+
 func (ts *exoscaleCCMTestSuite) Test_newRefreshableExoscaleClient_no_config() {
-	_, err := newRefreshableExoscaleClient(context.Background(), &testConfig_empty.Global, v3.ZoneNameCHGva2, testZoneCallback)
+	_, err := newRefreshableExoscaleClient(context.Background(), &testConfig_empty.Global, nil, v3.ZoneNameCHGva2, testZoneCallback)
 	ts.Require().Error(err)
 }
 
 func (ts *exoscaleCCMTestSuite) Test_newRefreshableExoscaleClient_credentials() {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
 	expected := &refreshableExoscaleClient{
 		RWMutex: &sync.RWMutex{}, //nolint:staticcheck
 		apiCredentials: exoscaleAPICredentials{
@@ -29,13 +66,14 @@ func (ts *exoscaleCCMTestSuite) Test_newRefreshableExoscaleClient_credentials()
 		},
 	}
 
-	actual, err := newRefreshableExoscaleClient(context.Background(), &testConfig_typical.Global, v3.ZoneNameCHGva2, testZoneCallback)
+	actual, err := newRefreshableExoscaleClient(ctx, &testConfig_typical.Global, nil, v3.ZoneNameCHGva2, testZoneCallback)
 	ts.Require().NoError(err)
 	ts.Require().Equal(expected.apiCredentials, actual.apiCredentials)
+	ts.Require().Equal(credentialsSourceConfig, actual.apiCredentialsSource)
 	ts.Require().NotNil(actual.exo)
 }
 
-func (ts *exoscaleCCMTestSuite) Test_refreshableExoscaleClient_refreshCredentials() {
+func (ts *exoscaleCCMTestSuite) Test_refreshableExoscaleClient_refreshCredentials_file() {
 	testAPICredentials := exoscaleAPICredentials{
 		APIKey:    testAPISecret,
 		APISecret: testAPISecret,
@@ -53,8 +91,8 @@ func (ts *exoscaleCCMTestSuite) Test_refreshableExoscaleClient_refreshCredential
 
 	ts.Require().NoError(os.WriteFile(testAPICredentialsFile, jsonAPICredentials, 0o600))
 
-	client := &refreshableExoscaleClient{RWMutex: &sync.RWMutex{}}
-	client.refreshCredentialsFromFile(context.Background(), testAPICredentialsFile, v3.ZoneNameCHGva2, testZoneCallback)
+	client := newTestRefreshableExoscaleClient(&globalConfig{APICredentialsFile: testAPICredentialsFile}, nil)
+	ts.Require().NoError(client.refreshCredentials(context.Background()))
 
 	client.RLock()
 	defer client.RUnlock()
@@ -81,8 +119,9 @@ func (ts *exoscaleCCMTestSuite) Test_refreshableExoscaleClient_watchCredentialsF
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
-	client := &refreshableExoscaleClient{RWMutex: &sync.RWMutex{}}
-	go client.watchCredentialsFile(ctx, testAPICredentialsFile, v3.ZoneNameCHGva2, testZoneCallback)
+	client := newTestRefreshableExoscaleClient(&globalConfig{APICredentialsFile: testAPICredentialsFile}, nil)
+	go client.watchCredentialsFile(ctx, testAPICredentialsFile)
+	go client.runCredentialsRefresher(ctx)
 
 	time.Sleep(1 * time.Second)
 	ts.Require().NoError(os.WriteFile(testAPICredentialsFile, jsonAPICredentials, 0o600))
@@ -93,3 +132,429 @@ func (ts *exoscaleCCMTestSuite) Test_refreshableExoscaleClient_watchCredentialsF
 	ts.Require().Equal(testAPICredentials, client.apiCredentials)
 	ts.Require().NotNil(client.exo)
 }
+
+func (ts *exoscaleCCMTestSuite) Test_refreshableExoscaleClient_refreshCredentials_secret() {
+	kclient := fake.NewSimpleClientset(&v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "exoscale-credentials",
+			Namespace: metav1.NamespaceSystem,
+		},
+		Data: map[string][]byte{
+			credentialsSecretKeyAPIKey:    []byte(testAPIKey),
+			credentialsSecretKeyAPISecret: []byte(testAPISecret),
+		},
+	})
+
+	client := newTestRefreshableExoscaleClient(&globalConfig{APICredentialsSecret: "kube-system/exoscale-credentials"}, kclient)
+	ts.Require().NoError(client.refreshCredentials(context.Background()))
+
+	client.RLock()
+	defer client.RUnlock()
+	ts.Require().Equal(testAPIKey, client.apiCredentials.APIKey)
+	ts.Require().Equal(testAPISecret, client.apiCredentials.APISecret)
+	ts.Require().Equal(credentialsSourceSecret, client.apiCredentialsSource)
+	ts.Require().NotNil(client.exo)
+}
+
+func (ts *exoscaleCCMTestSuite) Test_credentialsChain_retrieve() {
+	os.Unsetenv("EXOSCALE_API_KEY")
+	os.Unsetenv("EXOSCALE_API_SECRET")
+
+	tmpdir, err := os.MkdirTemp(os.TempDir(), "exoscale-ccm")
+	ts.Require().NoError(err)
+	defer os.RemoveAll(tmpdir)
+
+	testAPICredentialsFile := path.Join(tmpdir, "credentials.json")
+	ts.Require().NoError(os.WriteFile(
+		testAPICredentialsFile,
+		[]byte(`{"api_key":"EXOfile","api_secret":"file"}`),
+		0o600,
+	))
+
+	ts.Run("config has precedence", func() {
+		apiCredentials, source, err := newCredentialsChain(&globalConfig{
+			APIKey:             testAPIKey,
+			APISecret:          testAPISecret,
+			APICredentialsFile: testAPICredentialsFile,
+		}, nil).retrieve(context.Background())
+		ts.Require().NoError(err)
+		ts.Require().Equal(credentialsSourceConfig, source)
+		ts.Require().Equal(testAPIKey, apiCredentials.APIKey)
+	})
+
+	ts.Run("environment", func() {
+		os.Setenv("EXOSCALE_API_KEY", "EXOenv")
+		os.Setenv("EXOSCALE_API_SECRET", "env")
+		defer func() {
+			os.Unsetenv("EXOSCALE_API_KEY")
+			os.Unsetenv("EXOSCALE_API_SECRET")
+		}()
+
+		// The environment variables override the cloud-config API key/secret.
+		cfg, err := readExoscaleConfig(strings.NewReader(`---
+global:
+  apiKey: "EXOconfig"
+  apiSecret: "config"
+  apiCredentialsFile: "` + testAPICredentialsFile + `"
+`))
+		ts.Require().NoError(err)
+
+		apiCredentials, source, err := newCredentialsChain(&cfg.Global, nil).retrieve(context.Background())
+		ts.Require().NoError(err)
+		ts.Require().Equal(credentialsSourceConfig, source)
+		ts.Require().Equal("EXOenv", apiCredentials.APIKey)
+	})
+
+	ts.Run("incomplete config falls back to file", func() {
+		apiCredentials, source, err := newCredentialsChain(&globalConfig{
+			APIKey:             testAPIKey,
+			APICredentialsFile: testAPICredentialsFile,
+		}, nil).retrieve(context.Background())
+		ts.Require().NoError(err)
+		ts.Require().Equal(credentialsSourceFile, source)
+		ts.Require().Equal("EXOfile", apiCredentials.APIKey)
+	})
+
+	ts.Run("errors are reported", func() {
+		_, _, err := newCredentialsChain(&globalConfig{
+			APICredentialsFile: path.Join(tmpdir, "missing.json"),
+		}, nil).retrieve(context.Background())
+		ts.Require().ErrorContains(err, credentialsSourceFile)
+	})
+
+	ts.Run("invalid file doesn't fall back", func() {
+		invalidFile := path.Join(tmpdir, "invalid.json")
+		ts.Require().NoError(os.WriteFile(invalidFile, []byte(`{"api_key":"EXOfile","api_sec`), 0o600))
+
+		kclient := fake.NewSimpleClientset(&v1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Namespace: metav1.NamespaceSystem, Name: "exoscale-credentials"},
+			Data: map[string][]byte{
+				credentialsSecretKeyAPIKey:    []byte(testAPIKey),
+				credentialsSecretKeyAPISecret: []byte(testAPISecret),
+			},
+		})
+		chain := newCredentialsChain(&globalConfig{
+			APICredentialsFile:   invalidFile,
+			APICredentialsSecret: "exoscale-credentials",
+		}, kclient)
+
+		_, _, err := chain.retrieve(context.Background())
+		ts.Require().ErrorContains(err, "failed to decode credentials file")
+
+		// The Secret is used only once the file is removed.
+		ts.Require().NoError(os.Remove(invalidFile))
+		_, source, err := chain.retrieve(context.Background())
+		ts.Require().NoError(err)
+		ts.Require().Equal(credentialsSourceSecret, source)
+	})
+}
+
+func (ts *exoscaleCCMTestSuite) Test_refreshableExoscaleClient_assumeRole() {
+	var (
+		roleID  = v3.UUID(ts.randomID())
+		assumed atomic.Int32
+	)
+
+	client := newTestRefreshableExoscaleClient(&globalConfig{APIKey: testAPIKey, APISecret: testAPISecret}, nil)
+	client.apiRoleID = roleID
+	client.apiRoleTTL = 200 * time.Millisecond
+	client.assumeRoleCallback = func(_ context.Context, _ *v3.Client, id v3.UUID, ttl time.Duration) (exoscaleAPICredentials, error) {
+		ts.Require().Equal(roleID, id)
+		ts.Require().Equal(200*time.Millisecond, ttl)
+
+		return exoscaleAPICredentials{
+			APIKey:    fmt.Sprintf("EXOrole%d", assumed.Add(1)),
+			APISecret: testAPISecret,
+		}, nil
+	}
+
+	ts.Require().NoError(client.refreshCredentials(context.Background()))
+	client.RLock()
+	ts.Require().Equal("EXOrole1", client.apiCredentials.APIKey)
+	ts.Require().WithinDuration(time.Now().Add(200*time.Millisecond), client.apiCredentialsExpiry, 100*time.Millisecond)
+	client.RUnlock()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go client.runCredentialsRefresher(ctx)
+
+	ts.Require().Eventually(func() bool {
+		client.RLock()
+		defer client.RUnlock()
+
+		return assumed.Load() >= 3 && client.apiCredentials.APIKey != "EXOrole1"
+	}, 2*time.Second, 10*time.Millisecond)
+}
+
+func (ts *exoscaleCCMTestSuite) Test_refreshableExoscaleClient_assumeRole_error() {
+	client := newTestRefreshableExoscaleClient(&globalConfig{APIKey: testAPIKey, APISecret: testAPISecret}, nil)
+	client.apiRoleID = v3.UUID(ts.randomID())
+	client.apiRoleTTL = time.Hour
+	client.assumeRoleCallback = func(_ context.Context, _ *v3.Client, _ v3.UUID, _ time.Duration) (exoscaleAPICredentials, error) {
+		return exoscaleAPICredentials{}, errors.New("forbidden")
+	}
+
+	ts.Require().Error(client.refreshCredentials(context.Background()))
+	ts.Require().Nil(client.exo)
+}
+
+func (ts *exoscaleCCMTestSuite) Test_refreshableExoscaleClient_watchCredentialsSecret() {
+	os.Unsetenv("EXOSCALE_API_KEY")
+	os.Unsetenv("EXOSCALE_API_SECRET")
+
+	secret := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "exoscale-ccm-credentials",
+			Namespace: metav1.NamespaceSystem,
+		},
+		Data: map[string][]byte{
+			credentialsSecretKeySKSAPIKey:    []byte(testAPIKey),
+			credentialsSecretKeySKSAPISecret: []byte(testAPISecret),
+			credentialsSecretKeySKSName:      []byte("ccm"),
+		},
+	}
+	kclient := fake.NewSimpleClientset(secret)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	client, err := newRefreshableExoscaleClient(
+		ctx,
+		&globalConfig{APICredentialsSecret: "kube-system/exoscale-ccm-credentials"},
+		kclient,
+		v3.ZoneNameCHGva2,
+		testZoneCallback,
+	)
+	ts.Require().NoError(err)
+
+	client.RLock()
+	ts.Require().Equal(exoscaleAPICredentials{APIKey: testAPIKey, APISecret: testAPISecret, Name: "ccm"}, client.apiCredentials)
+	client.RUnlock()
+
+	rotated := secret.DeepCopy()
+	rotated.Data[credentialsSecretKeySKSAPIKey] = []byte("EXOrotated")
+	_, err = kclient.CoreV1().Secrets(metav1.NamespaceSystem).Update(ctx, rotated, metav1.UpdateOptions{})
+	ts.Require().NoError(err)
+
+	ts.Require().Eventually(func() bool {
+		client.RLock()
+		defer client.RUnlock()
+
+		return client.apiCredentials.APIKey == "EXOrotated"
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func (ts *exoscaleCCMTestSuite) Test_refreshableExoscaleClient_refreshCredentials_invalid() {
+	client := newTestRefreshableExoscaleClient(&globalConfig{APIKey: testAPIKey, APISecret: testAPISecret}, nil)
+	ts.Require().NoError(client.refreshCredentials(context.Background()))
+	ts.Require().NoError(client.credentialsHealth())
+	previous := client.exo
+
+	client.validateCredentials = func(context.Context, *v3.Client) error { return errors.New("unauthorized") }
+	client.credentialsChain = newCredentialsChain(&globalConfig{APIKey: "EXOrevoked", APISecret: testAPISecret}, nil)
+
+	ts.Require().ErrorContains(client.refreshCredentials(context.Background()), "unauthorized")
+	ts.Require().ErrorContains(client.credentialsHealth(), "unauthorized")
+	ts.Require().Same(previous, client.exo)
+	ts.Require().Equal(testAPIKey, client.apiCredentials.APIKey)
+}
+
+func (ts *exoscaleCCMTestSuite) Test_newRefreshableExoscaleClient_missing_file() {
+	os.Unsetenv("EXOSCALE_API_KEY")
+	os.Unsetenv("EXOSCALE_API_SECRET")
+
+	defer func(backoff wait.Backoff) { credentialsRefreshBackoff = backoff }(credentialsRefreshBackoff)
+	credentialsRefreshBackoff = wait.Backoff{Duration: 10 * time.Millisecond, Factor: 1, Steps: math.MaxInt32}
+
+	tmpdir, err := os.MkdirTemp(os.TempDir(), "exoscale-ccm")
+	ts.Require().NoError(err)
+	defer os.RemoveAll(tmpdir)
+
+	testAPICredentialsFile := path.Join(tmpdir, "credentials.json")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	client, err := newRefreshableExoscaleClient(
+		ctx,
+		&globalConfig{APICredentialsFile: testAPICredentialsFile},
+		nil,
+		v3.ZoneNameCHGva2,
+		testZoneCallback,
+	)
+	ts.Require().NoError(err)
+	ts.Require().Error(client.credentialsHealth())
+
+	_, err = client.GetInstance(ctx, v3.UUID(ts.randomID()))
+	ts.Require().ErrorIs(err, errCredentialsUnavailable)
+
+	ts.Require().NoError(os.WriteFile(
+		testAPICredentialsFile,
+		[]byte(fmt.Sprintf(`{"api_key":%q,"api_secret":%q}`, testAPIKey, testAPISecret)),
+		0o600,
+	))
+
+	ts.Require().Eventually(func() bool {
+		return client.credentialsHealth() == nil
+	}, 5*time.Second, 10*time.Millisecond)
+
+	client.RLock()
+	defer client.RUnlock()
+	ts.Require().Equal(testAPIKey, client.apiCredentials.APIKey)
+	ts.Require().IsType(&v3.Client{}, client.exo)
+}
+
+// newTestAPIServer returns a fake Exoscale API server reporting the API key of
+// the operation polling requests on the channel specified (if not nil), and
+// blocking them until release is closed (if not nil).
+func newTestAPIServer(polls chan<- string, release <-chan struct{}) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		apiKey := strings.TrimPrefix(
+			strings.Split(r.Header.Get("Authorization"), ",")[0],
+			"EXO2-HMAC-SHA256 credential=",
+		)
+		id := path.Base(r.URL.Path)
+
+		w.Header().Set("Content-Type", "application/json")
+
+		switch path.Dir(r.URL.Path) {
+		case "/operation":
+			if polls != nil {
+				polls <- apiKey
+			}
+			if release != nil {
+				<-release
+			}
+			_ = json.NewEncoder(w).Encode(v3.Operation{ID: v3.UUID(id), State: v3.OperationStateSuccess})
+
+		case "/instance":
+			_ = json.NewEncoder(w).Encode(v3.Instance{ID: v3.UUID(id)})
+
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+}
+
+func (ts *exoscaleCCMTestSuite) Test_refreshableExoscaleClient_Wait_refresh() {
+	defer func(f func(time.Duration) time.Duration) { waitPollInterval = f }(waitPollInterval)
+	waitPollInterval = func(time.Duration) time.Duration { return time.Millisecond }
+
+	var (
+		polls   = make(chan string, 10)
+		release = make(chan struct{})
+		server  = newTestAPIServer(polls, release)
+	)
+	defer server.Close()
+
+	client := newTestRefreshableExoscaleClient(&globalConfig{APIKey: testAPIKey, APISecret: testAPISecret}, nil)
+	client.apiEndpoint = v3.Endpoint(server.URL)
+	ts.Require().NoError(client.refreshCredentials(context.Background()))
+
+	done := make(chan error)
+	go func() {
+		_, err := client.Wait(
+			context.Background(),
+			&v3.Operation{ID: v3.UUID(ts.randomID()), State: v3.OperationStatePending},
+			v3.OperationStateSuccess,
+		)
+		done <- err
+	}()
+
+	// The operation is being polled: a credentials refresh must not wait
+	// for it to complete.
+	ts.Require().Equal(testAPIKey, <-polls)
+
+	refreshed := make(chan error)
+	client.credentialsChain = newCredentialsChain(&globalConfig{APIKey: "EXOrefreshed", APISecret: testAPISecret}, nil)
+	go func() { refreshed <- client.refreshCredentials(context.Background()) }()
+
+	select {
+	case err := <-refreshed:
+		ts.Require().NoError(err)
+	case <-time.After(5 * time.Second):
+		ts.FailNow("credentials refresh blocked by an in-flight operation wait")
+	}
+
+	close(release)
+	ts.Require().NoError(<-done)
+}
+
+func (ts *exoscaleCCMTestSuite) Test_refreshableExoscaleClient_Wait_snapshot() {
+	defer func(f func(time.Duration) time.Duration) { waitPollInterval = f }(waitPollInterval)
+	waitPollInterval = func(time.Duration) time.Duration { return time.Millisecond }
+
+	var (
+		operationID = v3.UUID(ts.randomID())
+		pending     = &v3.Operation{ID: operationID, State: v3.OperationStatePending}
+		clientA     = new(exoscaleClientMock)
+		clientB     = new(exoscaleClientMock)
+	)
+
+	client := newTestRefreshableExoscaleClient(&globalConfig{}, nil)
+	client.exo = clientA
+
+	// Credentials are refreshed between the first and the second poll.
+	clientA.
+		On("GetOperation", mock.Anything, operationID).
+		Run(func(_ mock.Arguments) {
+			client.Lock()
+			client.exo = clientB
+			client.Unlock()
+		}).
+		Return(pending, nil).
+		Once()
+	clientB.
+		On("GetOperation", mock.Anything, operationID).
+		Return(&v3.Operation{ID: operationID, State: v3.OperationStateSuccess}, nil).
+		Once()
+
+	actual, err := client.Wait(context.Background(), pending, v3.OperationStateSuccess)
+	ts.Require().NoError(err)
+	ts.Require().Equal(v3.OperationStateSuccess, actual.State)
+	clientA.AssertExpectations(ts.T())
+	clientB.AssertExpectations(ts.T())
+}
+
+func (ts *exoscaleCCMTestSuite) Test_refreshableExoscaleClient_concurrent_refresh() {
+	defer func(f func(time.Duration) time.Duration) { waitPollInterval = f }(waitPollInterval)
+	waitPollInterval = func(time.Duration) time.Duration { return time.Millisecond }
+
+	server := newTestAPIServer(nil, nil)
+	defer server.Close()
+
+	client := newTestRefreshableExoscaleClient(&globalConfig{APIKey: testAPIKey, APISecret: testAPISecret}, nil)
+	client.apiEndpoint = v3.Endpoint(server.URL)
+	ts.Require().NoError(client.refreshCredentials(context.Background()))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(2)
+
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				_, err := client.GetInstance(context.Background(), v3.UUID(ts.randomID()))
+				ts.Assert().NoError(err)
+
+				_, err = client.Wait(
+					context.Background(),
+					&v3.Operation{ID: v3.UUID(ts.randomID()), State: v3.OperationStatePending},
+					v3.OperationStateSuccess,
+				)
+				ts.Assert().NoError(err)
+			}
+		}()
+
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				ts.Assert().NoError(client.refreshCredentials(context.Background()))
+				client.requestCredentialsRefresh()
+				ts.Assert().NoError(client.credentialsHealth())
+			}
+		}()
+	}
+
+	wg.Wait()
+}
@@ -0,0 +1,249 @@
+package exoscale
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"strings"
+
+	"github.com/exoscale/egoscale/v3/credentials"
+	"github.com/spf13/viper"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Exoscale API credentials sources, in the order they are evaluated by the
+// credentials chain.
+const (
+	credentialsSourceConfig    = "config"
+	credentialsSourceFile      = "file"
+	credentialsSourceSecret    = "secret"
+	credentialsSourceCLIConfig = "cli-config"
+)
+
+// Kubernetes Secret keys holding the API credentials.
+const (
+	credentialsSecretKeyAPIKey    = "api-key"
+	credentialsSecretKeyAPISecret = "api-secret"
+
+	// Keys used by the SKS CCM credentials rotation, matching the JSON
+	// credentials file fields.
+	credentialsSecretKeySKSAPIKey    = "api_key"
+	credentialsSecretKeySKSAPISecret = "api_secret"
+	credentialsSecretKeySKSName      = "name"
+)
+
+// errMissingCredentials is returned by the credentials chain when none of its
+// providers has been configured.
+var errMissingCredentials = errors.New("incomplete or missing Exoscale API credentials")
+
+// errCredentialsNotConfigured is returned by credentials providers which
+// haven't been configured, so that the chain can silently skip them.
+var errCredentialsNotConfigured = errors.New("not configured")
+
+// errCredentialsInvalid is returned by credentials providers whose source
+// exists but can't be used (e.g. a credentials file being written), so that
+// the chain fails instead of falling back to the next providers.
+var errCredentialsInvalid = errors.New("invalid credentials")
+
+// credentialsProvider represents a source of Exoscale API credentials.
+type credentialsProvider interface {
+	source() string
+	retrieve(ctx context.Context) (exoscaleAPICredentials, error)
+}
+
+// credentialsChain retrieves Exoscale API credentials from the first provider
+// able to supply them.
+type credentialsChain []credentialsProvider
+
+// newCredentialsChain returns the Exoscale API credentials chain, evaluated in
+// this order:
+//  1. the cloud-config file API key/secret (global.apiKey/global.apiSecret),
+//     which the EXOSCALE_API_KEY/EXOSCALE_API_SECRET environment variables
+//     override when the configuration is read
+//  2. the JSON credentials file (global.apiCredentialsFile)
+//  3. the Kubernetes Secret (global.apiCredentialsSecret)
+//  4. the Exoscale CLI configuration file
+func newCredentialsChain(config *globalConfig, kclient kubernetes.Interface) credentialsChain {
+	return credentialsChain{
+		&staticCredentialsProvider{apiKey: config.APIKey, apiSecret: config.APISecret},
+		&fileCredentialsProvider{path: config.APICredentialsFile},
+		&secretCredentialsProvider{secret: config.APICredentialsSecret, kclient: kclient},
+		&egoscaleCredentialsProvider{name: credentialsSourceCLIConfig, provider: &credentials.FileProvider{}},
+	}
+}
+
+// retrieve returns the credentials supplied by the first provider of the
+// chain able to, along with the source they have been retrieved from.
+func (c credentialsChain) retrieve(ctx context.Context) (exoscaleAPICredentials, string, error) {
+	var errs []string
+	for _, provider := range c {
+		apiCredentials, err := provider.retrieve(ctx)
+		if err == nil {
+			return apiCredentials, provider.source(), nil
+		}
+
+		if errors.Is(err, errCredentialsNotConfigured) {
+			continue
+		}
+
+		errs = append(errs, fmt.Sprintf("%s: %v", provider.source(), err))
+		if errors.Is(err, errCredentialsInvalid) {
+			break
+		}
+	}
+
+	if len(errs) > 0 {
+		return exoscaleAPICredentials{}, "", fmt.Errorf(
+			"unable to retrieve Exoscale API credentials (%s)",
+			strings.Join(errs, "; "),
+		)
+	}
+
+	return exoscaleAPICredentials{}, "", errMissingCredentials
+}
+
+// staticCredentialsProvider supplies the API credentials set in the
+// cloud-config file (possibly overridden by environment variables).
+type staticCredentialsProvider struct {
+	apiKey    string
+	apiSecret string
+}
+
+func (p *staticCredentialsProvider) source() string { return credentialsSourceConfig }
+
+func (p *staticCredentialsProvider) retrieve(_ context.Context) (exoscaleAPICredentials, error) {
+	if p.apiKey == "" || p.apiSecret == "" {
+		return exoscaleAPICredentials{}, errCredentialsNotConfigured
+	}
+
+	return exoscaleAPICredentials{APIKey: p.apiKey, APISecret: p.apiSecret}, nil
+}
+
+// fileCredentialsProvider supplies the API credentials read from a JSON file:
+// the chain falls back to the next providers only if the file doesn't exist.
+type fileCredentialsProvider struct {
+	path string
+}
+
+func (p *fileCredentialsProvider) source() string { return credentialsSourceFile }
+
+func (p *fileCredentialsProvider) retrieve(_ context.Context) (exoscaleAPICredentials, error) {
+	if p.path == "" {
+		return exoscaleAPICredentials{}, errCredentialsNotConfigured
+	}
+
+	f, err := os.Open(p.path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return exoscaleAPICredentials{}, fmt.Errorf("failed to read credentials file %q: %w", p.path, err)
+		}
+		return exoscaleAPICredentials{}, fmt.Errorf("%w: failed to read credentials file %q: %w", errCredentialsInvalid, p.path, err)
+	}
+	defer f.Close()
+
+	var apiCredentials exoscaleAPICredentials
+	if err = json.NewDecoder(f).Decode(&apiCredentials); err != nil {
+		return exoscaleAPICredentials{}, fmt.Errorf("%w: failed to decode credentials file %q: %w", errCredentialsInvalid, p.path, err)
+	}
+
+	if apiCredentials.APIKey == "" || apiCredentials.APISecret == "" {
+		return exoscaleAPICredentials{}, fmt.Errorf("%w: credentials file %q: %w", errCredentialsInvalid, p.path, credentials.ErrMissingIncomplete)
+	}
+
+	return apiCredentials, nil
+}
+
+// secretCredentialsProvider supplies the API credentials read from a
+// Kubernetes Secret, specified as "<namespace>/<name>".
+type secretCredentialsProvider struct {
+	secret  string
+	kclient kubernetes.Interface
+}
+
+func (p *secretCredentialsProvider) source() string { return credentialsSourceSecret }
+
+func (p *secretCredentialsProvider) retrieve(ctx context.Context) (exoscaleAPICredentials, error) {
+	if p.secret == "" {
+		return exoscaleAPICredentials{}, errCredentialsNotConfigured
+	}
+
+	if p.kclient == nil {
+		return exoscaleAPICredentials{}, errors.New("kubernetes client not initialized")
+	}
+
+	namespace, name, err := parseCredentialsSecret(p.secret)
+	if err != nil {
+		return exoscaleAPICredentials{}, err
+	}
+
+	secret, err := p.kclient.CoreV1().Secrets(namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return exoscaleAPICredentials{}, fmt.Errorf("failed to retrieve Secret %s: %w", p.secret, err)
+	}
+
+	apiCredentials := exoscaleAPICredentials{
+		APIKey:    string(secret.Data[credentialsSecretKeyAPIKey]),
+		APISecret: string(secret.Data[credentialsSecretKeyAPISecret]),
+		Name:      p.secret,
+	}
+	if _, ok := secret.Data[credentialsSecretKeySKSAPIKey]; ok {
+		apiCredentials = exoscaleAPICredentials{
+			APIKey:    string(secret.Data[credentialsSecretKeySKSAPIKey]),
+			APISecret: string(secret.Data[credentialsSecretKeySKSAPISecret]),
+			Name:      string(secret.Data[credentialsSecretKeySKSName]),
+		}
+	}
+	if apiCredentials.APIKey == "" || apiCredentials.APISecret == "" {
+		return exoscaleAPICredentials{}, fmt.Errorf("secret %s: %w", p.secret, credentials.ErrMissingIncomplete)
+	}
+
+	return apiCredentials, nil
+}
+
+// parseCredentialsSecret splits a "<namespace>/<name>" Secret reference,
+// defaulting to the kube-system namespace.
+func parseCredentialsSecret(secret string) (string, string, error) {
+	parts := strings.Split(secret, "/")
+	switch {
+	case len(parts) == 1 && parts[0] != "":
+		return metav1.NamespaceSystem, parts[0], nil
+	case len(parts) == 2 && parts[0] != "" && parts[1] != "":
+		return parts[0], parts[1], nil
+	default:
+		return "", "", fmt.Errorf("invalid Secret reference %q, expected <namespace>/<name>", secret)
+	}
+}
+
+// egoscaleCredentialsProvider adapts the egoscale credentials providers.
+type egoscaleCredentialsProvider struct {
+	name     string
+	provider credentials.Provider
+}
+
+func (p *egoscaleCredentialsProvider) source() string { return p.name }
+
+func (p *egoscaleCredentialsProvider) retrieve(_ context.Context) (exoscaleAPICredentials, error) {
+	v, err := p.provider.Retrieve()
+	if err != nil {
+		// Absent credentials aren't worth reporting for implicit sources.
+		if errors.Is(err, credentials.ErrMissingIncomplete) || isConfigFileNotFound(err) {
+			return exoscaleAPICredentials{}, errCredentialsNotConfigured
+		}
+
+		return exoscaleAPICredentials{}, err
+	}
+
+	return exoscaleAPICredentials{APIKey: v.APIKey, APISecret: v.APISecret}, nil
+}
+
+// isConfigFileNotFound returns true if the error reports that no Exoscale CLI
+// configuration file could be found.
+func isConfigFileNotFound(err error) bool {
+	var notFound viper.ConfigFileNotFoundError
+
+	return errors.As(err, &notFound) || errors.Is(err, fs.ErrNotExist)
+}
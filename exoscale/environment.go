@@ -0,0 +1,117 @@
+package exoscale
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+
+	v3 "github.com/exoscale/egoscale/v3"
+)
+
+const (
+	// defaultAPIEnvironment is the Exoscale production API environment.
+	defaultAPIEnvironment = "api"
+
+	// preproductionAPIEnvironment is the Exoscale preproduction API
+	// environment.
+	preproductionAPIEnvironment = "ppapi"
+
+	// defaultAPIEndpointTemplate is the template of the API endpoint of an
+	// environment zone, "{env}" and "{zone}" being replaced by the
+	// environment and zone names.
+	defaultAPIEndpointTemplate = "https://{env}-{zone}.exoscale.com/v2"
+
+	// apiEnvironmentBootstrapZone is the zone of the API endpoint used until
+	// the client is switched to the CCM zone.
+	apiEnvironmentBootstrapZone = v3.ZoneNameCHGva2
+)
+
+// knownAPIEnvironments are the API environments reachable through the default
+// endpoint template, any other one requiring an explicit template.
+var knownAPIEnvironments = []string{defaultAPIEnvironment, preproductionAPIEnvironment}
+
+var (
+	apiEnvironmentRegexp = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?$`)
+
+	apiEndpointTemplatePlaceholderRegexp = regexp.MustCompile(`\{[^}]*\}`)
+)
+
+// apiEnvironment represents an Exoscale API environment (e.g. production or
+// preproduction), the endpoint of each zone being derived from a template.
+type apiEnvironment struct {
+	name             string
+	endpointTemplate string
+}
+
+// newAPIEnvironment returns the API environment specified, defaulting to the
+// production one and to the default endpoint template. Only the known
+// environments may be used with the default endpoint template.
+func newAPIEnvironment(name, endpointTemplate string) (apiEnvironment, error) {
+	if name == "" {
+		name = defaultAPIEnvironment
+	}
+
+	if !apiEnvironmentRegexp.MatchString(name) {
+		return apiEnvironment{}, fmt.Errorf(
+			"invalid Exoscale API environment %q: expected a lowercase DNS label (e.g. %q)",
+			name,
+			defaultAPIEnvironment,
+		)
+	}
+
+	if endpointTemplate == "" {
+		if !slices.Contains(knownAPIEnvironments, name) {
+			return apiEnvironment{}, fmt.Errorf(
+				"unknown Exoscale API environment %q: expected one of %s, or an explicit endpoint template",
+				name,
+				strings.Join(knownAPIEnvironments, ", "),
+			)
+		}
+
+		endpointTemplate = defaultAPIEndpointTemplate
+	}
+
+	for _, placeholder := range apiEndpointTemplatePlaceholderRegexp.FindAllString(endpointTemplate, -1) {
+		if placeholder != "{env}" && placeholder != "{zone}" {
+			return apiEnvironment{}, fmt.Errorf(
+				"invalid Exoscale API endpoint template %q: unknown placeholder %s (expected {env} and {zone})",
+				endpointTemplate,
+				placeholder,
+			)
+		}
+	}
+	if !strings.Contains(endpointTemplate, "{zone}") {
+		return apiEnvironment{}, fmt.Errorf(
+			"invalid Exoscale API endpoint template %q: missing {zone} placeholder",
+			endpointTemplate,
+		)
+	}
+
+	env := apiEnvironment{name: name, endpointTemplate: endpointTemplate}
+
+	u, err := url.Parse(string(env.endpoint(apiEnvironmentBootstrapZone)))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return apiEnvironment{}, fmt.Errorf(
+			"invalid Exoscale API endpoint template %q: expected an HTTP(S) URL",
+			endpointTemplate,
+		)
+	}
+
+	return env, nil
+}
+
+// isDefault returns whether the environment is the production one, whose
+// zones endpoints are the ones reported by the API.
+func (e apiEnvironment) isDefault() bool {
+	return e.name == defaultAPIEnvironment && e.endpointTemplate == defaultAPIEndpointTemplate
+}
+
+// endpoint returns the API endpoint of the environment zone specified.
+func (e apiEnvironment) endpoint(zone v3.ZoneName) v3.Endpoint {
+	return v3.Endpoint(strings.NewReplacer(
+		"{env}", e.name,
+		"{zone}", string(zone),
+	).Replace(e.endpointTemplate))
+}
@@ -0,0 +1,115 @@
+package exoscale
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+
+	v3 "github.com/exoscale/egoscale/v3"
+	"github.com/exoscale/egoscale/v3/credentials"
+)
+
+func (ts *exoscaleCCMTestSuite) Test_newAPIEnvironment() {
+	env, err := newAPIEnvironment("", "")
+	ts.Require().NoError(err)
+	ts.Require().True(env.isDefault())
+	ts.Require().Equal(v3.CHGva2, env.endpoint(v3.ZoneNameCHGva2))
+
+	env, err = newAPIEnvironment("ppapi", "")
+	ts.Require().NoError(err)
+	ts.Require().False(env.isDefault())
+	ts.Require().Equal(v3.Endpoint("https://ppapi-de-fra-1.exoscale.com/v2"), env.endpoint(v3.ZoneNameDEFra1))
+
+	env, err = newAPIEnvironment("dev", "http://localhost:8080/{env}/{zone}/v2")
+	ts.Require().NoError(err)
+	ts.Require().Equal(v3.Endpoint("http://localhost:8080/dev/at-vie-1/v2"), env.endpoint(v3.ZoneNameATVie1))
+
+	for _, tt := range []struct {
+		name, template, err string
+	}{
+		{name: "PPAPI", err: "invalid Exoscale API environment"},
+		{name: "pp.api", err: "invalid Exoscale API environment"},
+		{name: "dev", err: `unknown Exoscale API environment "dev"`},
+		{name: "ppapi", template: "https://{environment}-{zone}.exoscale.com/v2", err: "unknown placeholder {environment}"},
+		{name: "ppapi", template: "https://{env}.exoscale.com/v2", err: "missing {zone} placeholder"},
+		{name: "ppapi", template: "{env}-{zone}.exoscale.com", err: "expected an HTTP(S) URL"},
+	} {
+		_, err = newAPIEnvironment(tt.name, tt.template)
+		ts.Require().ErrorContains(err, tt.err, tt.name+" "+tt.template)
+	}
+}
+
+func (ts *exoscaleCCMTestSuite) Test_readExoscaleConfig_environment() {
+	ts.setTestCredentialsEnv()
+	os.Unsetenv("EXOSCALE_API_ENDPOINT")
+	os.Setenv("EXOSCALE_API_ENVIRONMENT", "ppapi")
+	defer os.Unsetenv("EXOSCALE_API_ENVIRONMENT")
+
+	cfg, err := readExoscaleConfig(strings.NewReader(testConfigYAML_empty))
+	ts.Require().NoError(err)
+	ts.Require().Equal("https://ppapi-ch-gva-2.exoscale.com/v2", cfg.Global.APIEndpoint)
+
+	// An explicit endpoint has precedence.
+	cfg, err = readExoscaleConfig(strings.NewReader(testConfigYAML_typical))
+	ts.Require().NoError(err)
+	ts.Require().Equal(testAPIEndpoint, cfg.Global.APIEndpoint)
+
+	os.Setenv("EXOSCALE_API_ENVIRONMENT", "pre_prod")
+	_, err = readExoscaleConfig(strings.NewReader(testConfigYAML_empty))
+	ts.Require().ErrorContains(err, `invalid Exoscale API environment "pre_prod"`)
+
+	os.Setenv("EXOSCALE_API_ENVIRONMENT", "preprod")
+	_, err = readExoscaleConfig(strings.NewReader(testConfigYAML_empty))
+	ts.Require().ErrorContains(err, `unknown Exoscale API environment "preprod"`)
+}
+
+func (ts *exoscaleCCMTestSuite) Test_switchZoneCallback() {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/zone" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(v3.ListZonesResponse{Zones: []v3.Zone{{
+			Name:        v3.ZoneNameDEFra1,
+			APIEndpoint: v3.DEFra1,
+		}}})
+	}))
+	defer server.Close()
+
+	client, err := v3.NewClient(
+		credentials.NewStaticCredentials(testAPIKey, testAPISecret),
+		v3.ClientOptWithEndpoint(v3.Endpoint(server.URL)),
+	)
+	ts.Require().NoError(err)
+
+	// The client endpoint isn't exposed, check it from the requests it sends.
+	endpointOf := func(c *v3.Client) string {
+		var endpoint string
+		_, _ = c.WithRequestInterceptor(func(_ context.Context, req *http.Request) error {
+			endpoint = strings.TrimSuffix(req.URL.String(), "/zone")
+			return context.Canceled
+		}).ListZones(context.Background())
+
+		return endpoint
+	}
+
+	production, err := newAPIEnvironment("", "")
+	ts.Require().NoError(err)
+	zoneClient, err := switchZoneCallback(context.Background(), client, production, v3.ZoneNameDEFra1)
+	ts.Require().NoError(err)
+	ts.Require().Equal(string(v3.DEFra1), endpointOf(zoneClient))
+
+	preproduction, err := newAPIEnvironment("ppapi", "")
+	ts.Require().NoError(err)
+	zoneClient, err = switchZoneCallback(context.Background(), client, preproduction, v3.ZoneNameDEFra1)
+	ts.Require().NoError(err)
+	ts.Require().Equal("https://ppapi-de-fra-1.exoscale.com/v2", endpointOf(zoneClient))
+
+	_, err = switchZoneCallback(context.Background(), client, preproduction, v3.ZoneNameATVie1)
+	ts.Require().Error(err)
+}
@@ -4,13 +4,19 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"net/http"
 	"os"
-	"strings"
+	"sync"
 	"time"
 
 	v3 "github.com/exoscale/egoscale/v3"
 	"github.com/exoscale/egoscale/v3/metadata"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/tools/record"
 	cloudprovider "k8s.io/cloud-provider"
 	"k8s.io/klog/v2"
 )
@@ -28,7 +34,11 @@ const (
 )
 
 type cloudProvider struct {
+	// cfg is the cloud-config currently in effect, swapped upon reload of
+	// the cfgFile: it must be accessed through config().
 	cfg          *cloudConfig
+	cfgMu        sync.RWMutex
+	cfgFile      string
 	ctx          context.Context
 	client       exoscaleClient
 	instances    cloudprovider.Instances
@@ -36,8 +46,19 @@ type cloudProvider struct {
 	zones        cloudprovider.Zones
 	loadBalancer cloudprovider.LoadBalancer
 	kclient      kubernetes.Interface
+	recorder     record.EventRecorder
 	zone         string
 
+	// sksAgent is nil if no SKS agent runner is enabled.
+	sksAgent *sksAgent
+
+	// metadataClient is the HTTP client used to query the metadata server.
+	metadataClient *http.Client
+
+	// startOnce guards start(), which both Start (on every CCM replica) and
+	// Initialize (on the CCM leader) call.
+	startOnce sync.Once
+
 	stop func()
 }
 
@@ -53,19 +74,31 @@ func init() {
 			klog.Warningf("failed to instantiate Exoscale cloud provider: %v", err)
 			return nil, err
 		}
+
+		// The cloud-config is provided as the opened --cloud-config file,
+		// which is watched to reload the configuration upon change.
+		if f, ok := config.(*os.File); ok {
+			p.cfgFile = f.Name()
+		}
+
 		return p, nil
 	})
 }
 
-func newExoscaleCloud(config *cloudConfig) (cloudprovider.Interface, error) {
+func newExoscaleCloud(config *cloudConfig) (*cloudProvider, error) {
+	metadataClient, err := newMetadataHTTPClient(config.Global.HTTP)
+	if err != nil {
+		return nil, fmt.Errorf("invalid HTTP configuration: %w", err)
+	}
+
 	provider := &cloudProvider{
-		cfg: config,
+		cfg:            config,
+		metadataClient: metadataClient,
 	}
 
 	ctx, cancel := context.WithDeadline(context.Background(), time.Now().Add(time.Minute))
 	defer cancel()
 
-	var err error
 	zone := os.Getenv("EXOSCALE_API_ZONE")
 	if zone == "" {
 		// Support the "legacy" environment variable from before the egoscale v3 rework
@@ -75,7 +108,7 @@ func newExoscaleCloud(config *cloudConfig) (cloudprovider.Interface, error) {
 			if err != nil {
 				klog.Infof("cannot get node metadata from CD-ROM: %v", err)
 				klog.Info("fallback on server metadata")
-				zone, err = metadata.Get(ctx, metadata.AvailabilityZone)
+				zone, err = getMetadata(ctx, provider.metadataClient, metadata.AvailabilityZone)
 				if err != nil {
 					klog.Errorf("error to get exoscale node metadata from server: %v", err)
 					return nil, fmt.Errorf("get metadata: %w", err)
@@ -84,10 +117,12 @@ func newExoscaleCloud(config *cloudConfig) (cloudprovider.Interface, error) {
 		}
 	}
 
+	registerMetrics()
+
 	provider.zone = zone
-	provider.instances = newInstances(provider, &config.Instances)
-	provider.instancesV2 = newInstancesV2(provider, &config.Instances)
-	provider.loadBalancer = newLoadBalancer(provider, &config.LoadBalancer)
+	provider.instances = newInstances(provider)
+	provider.instancesV2 = newInstancesV2(provider)
+	provider.loadBalancer = newLoadBalancer(provider)
 	provider.zones = newZones(provider)
 
 	return provider, nil
@@ -97,6 +132,35 @@ func newExoscaleCloud(config *cloudConfig) (cloudprovider.Interface, error) {
 // to perform housekeeping or run custom controllers specific to the cloud provider.
 // Any tasks started here should be cleaned up when the stop channel closes.
 func (p *cloudProvider) Initialize(clientBuilder cloudprovider.ControllerClientBuilder, stop <-chan struct{}) {
+	p.start(clientBuilder, stop)
+
+	// The following tasks are only performed by the CCM leader, until it
+	// steps down.
+	ctx, cancel := context.WithCancel(p.ctx)
+	go func() {
+		defer cancel()
+		select {
+		case <-stop:
+		case <-ctx.Done():
+		}
+	}()
+
+	if !p.config().LoadBalancer.Disabled {
+		go p.loadBalancer.(*loadBalancer).watchInferredInstancePools(ctx)
+	}
+}
+
+// start initializes the Kubernetes and Exoscale API clients, and starts the
+// provider-level goroutines, only once: it is performed on every CCM replica
+// regardless of the leader election (see Start), the SKS agent being elected
+// using its own Lease.
+func (p *cloudProvider) start(clientBuilder cloudprovider.ControllerClientBuilder, stop <-chan struct{}) {
+	p.startOnce.Do(func() {
+		p.doStart(clientBuilder, stop)
+	})
+}
+
+func (p *cloudProvider) doStart(clientBuilder cloudprovider.ControllerClientBuilder, stop <-chan struct{}) {
 	restConfig := clientBuilder.ConfigOrDie("exoscale-cloud-controller-manager")
 	p.kclient = kubernetes.NewForConfigOrDie(restConfig)
 
@@ -104,9 +168,14 @@ func (p *cloudProvider) Initialize(clientBuilder cloudprovider.ControllerClientB
 	p.ctx = ctx
 	p.stop = cancel
 
+	eventBroadcaster := record.NewBroadcaster(record.WithContext(ctx))
+	eventBroadcaster.StartRecordingToSink(&typedcorev1.EventSinkImpl{Interface: p.kclient.CoreV1().Events("")})
+	p.recorder = eventBroadcaster.NewRecorder(scheme.Scheme, v1.EventSource{Component: "exoscale-cloud-controller-manager"})
+
 	client, err := newRefreshableExoscaleClient(
 		p.ctx,
-		&p.cfg.Global,
+		&p.config().Global,
+		p.kclient,
 		v3.ZoneName(p.zone),
 		switchZoneCallback,
 	)
@@ -123,23 +192,50 @@ func (p *cloudProvider) Initialize(clientBuilder cloudprovider.ControllerClientB
 		provider.stop()
 	}(p)
 
-	if v := os.Getenv("EXOSCALE_SKS_AGENT_RUNNERS"); v != "" {
-		if err := p.runSKSAgent(strings.Split(v, ",")); err != nil {
+	if p.cfgFile != "" {
+		go p.watchConfigFile(p.ctx, p.cfgFile)
+	}
+
+	if cfg := p.config().SKSAgent; len(cfg.Runners) > 0 {
+		agent, err := newSKSAgent(p, cfg)
+		if err != nil {
 			fatalf("SKS agent failed to start: %s", err)
 		}
+		p.sksAgent = agent
+		go agent.run(p.ctx)
 	}
 }
 
+// warningEventf records a Warning Kubernetes Event about the object specified,
+// if the provider has been initialized with an event recorder.
+func (p *cloudProvider) warningEventf(object runtime.Object, reason, messageFmt string, args ...interface{}) {
+	if p.recorder == nil {
+		return
+	}
+
+	p.recorder.Eventf(object, v1.EventTypeWarning, reason, messageFmt, args...)
+}
+
+// normalEventf records a Normal Kubernetes Event about the object specified,
+// if the provider has been initialized with an event recorder.
+func (p *cloudProvider) normalEventf(object runtime.Object, reason, messageFmt string, args ...interface{}) {
+	if p.recorder == nil {
+		return
+	}
+
+	p.recorder.Eventf(object, v1.EventTypeNormal, reason, messageFmt, args...)
+}
+
 // LoadBalancer returns a balancer interface.
 // Also returns true if the interface is supported, false otherwise.
 func (p *cloudProvider) LoadBalancer() (cloudprovider.LoadBalancer, bool) {
-	return p.loadBalancer, !p.cfg.LoadBalancer.Disabled
+	return p.loadBalancer, !p.config().LoadBalancer.Disabled
 }
 
 // Instances returns an instances interface.
 // Also returns true if the interface is supported, false otherwise.
 func (p *cloudProvider) Instances() (cloudprovider.Instances, bool) {
-	return p.instances, !p.cfg.Instances.Disabled
+	return p.instances, !p.config().Instances.Disabled
 }
 
 // InstancesV2 is an implementation for instances and should only be implemented by external cloud providers.
@@ -147,7 +243,7 @@ func (p *cloudProvider) Instances() (cloudprovider.Instances, bool) {
 // API calls to the cloud provider when registering and syncing nodes.
 // Also returns true if the interface is supported, false otherwise.
 func (p *cloudProvider) InstancesV2() (cloudprovider.InstancesV2, bool) {
-	return p.instancesV2, !p.cfg.Instances.Disabled
+	return p.instancesV2, !p.config().Instances.Disabled
 }
 
 // Zones returns a zones interface.
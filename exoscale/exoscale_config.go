@@ -1,34 +1,53 @@
 package exoscale
 
 import (
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
 	"io"
+	"net/url"
 	"os"
+	"time"
 
+	v3 "github.com/exoscale/egoscale/v3"
 	"gopkg.in/yaml.v3"
 )
 
+// redactedValue replaces the secrets of the configuration when displayed.
+const redactedValue = "<redacted>"
+
 type cloudConfig struct {
 	Global       globalConfig
 	Instances    instancesConfig
 	LoadBalancer loadBalancerConfig `yaml:"loadBalancer"`
+	SKSAgent     sksAgentConfig     `yaml:"sksAgent"`
 }
 
 type globalConfig struct {
-	APIKey             string `yaml:"apiKey"`
-	APISecret          string `yaml:"apiSecret"`
-	APICredentialsFile string `yaml:"apiCredentialsFile"`
-	APIEndpoint        string `yaml:"apiEndpoint"`
+	APIKey               string          `yaml:"apiKey"`
+	APISecret            string          `yaml:"apiSecret"`
+	APICredentialsFile   string          `yaml:"apiCredentialsFile"`
+	APICredentialsSecret string          `yaml:"apiCredentialsSecret"`
+	APIEndpoint          string          `yaml:"apiEndpoint"`
+	APIEnvironment       string          `yaml:"apiEnvironment"`
+	APIEndpointTemplate  string          `yaml:"apiEndpointTemplate"`
+	APIRoleID            string          `yaml:"apiRoleID"`
+	APIRoleTTL           time.Duration   `yaml:"apiRoleTTL"`
+	APIClient            apiClientConfig `yaml:"apiClient"`
+	HTTP                 httpConfig      `yaml:"http"`
 }
 
 func readExoscaleConfig(config io.Reader) (cloudConfig, error) {
 	cfg := cloudConfig{}
 
-	// Unmarshall configuration file (YAML)
+	// Unmarshall configuration file (YAML), rejecting unknown fields (e.g.
+	// typos) which would otherwise be silently ignored.
 	if config != nil {
-		err := yaml.NewDecoder(config).Decode(&cfg)
-		if err != nil {
-			return cloudConfig{}, err
+		decoder := yaml.NewDecoder(config)
+		decoder.KnownFields(true)
+		if err := decoder.Decode(&cfg); err != nil && !errors.Is(err, io.EOF) {
+			return cloudConfig{}, fmt.Errorf("invalid cloud-config: %w", err)
 		}
 	}
 
@@ -42,13 +61,137 @@ func readExoscaleConfig(config io.Reader) (cloudConfig, error) {
 	if value, exists := os.LookupEnv("EXOSCALE_API_CREDENTIALS_FILE"); exists {
 		cfg.Global.APICredentialsFile = value
 	}
+	if value, exists := os.LookupEnv("EXOSCALE_API_CREDENTIALS_SECRET"); exists {
+		cfg.Global.APICredentialsSecret = value
+	}
+	if value, exists := os.LookupEnv("EXOSCALE_API_ROLE_ID"); exists {
+		cfg.Global.APIRoleID = value
+	}
 	if value, exists := os.LookupEnv("EXOSCALE_API_ENDPOINT"); exists {
 		cfg.Global.APIEndpoint = value
-	} else if value, exists := os.LookupEnv("EXOSCALE_API_ENVIRONMENT"); exists {
-		if value == "ppapi" {
-			cfg.Global.APIEndpoint = fmt.Sprintf("https://%s-ch-gva-2.exoscale.com/v2", value)
-		}
+	}
+	if value, exists := os.LookupEnv("EXOSCALE_API_ENVIRONMENT"); exists {
+		cfg.Global.APIEnvironment = value
+	}
+	if err := cfg.SKSAgent.loadEnv(); err != nil {
+		return cloudConfig{}, fmt.Errorf("invalid cloud-config:\n%w", err)
+	}
+
+	environment, err := newAPIEnvironment(cfg.Global.APIEnvironment, cfg.Global.APIEndpointTemplate)
+	if err != nil {
+		return cloudConfig{}, err
+	}
+
+	// The zone API endpoints are derived from the environment, unless an
+	// explicit (global) endpoint is specified.
+	if cfg.Global.APIEndpoint == "" && !environment.isDefault() {
+		cfg.Global.APIEndpoint = string(environment.endpoint(apiEnvironmentBootstrapZone))
+	}
+
+	if err := cfg.validate(); err != nil {
+		return cloudConfig{}, fmt.Errorf("invalid cloud-config:\n%w", err)
 	}
 
 	return cfg, nil
 }
+
+// validate checks the consistency of the configuration.
+func (c *cloudConfig) validate() error {
+	return errors.Join(
+		c.Global.validate(),
+		c.Instances.validate(),
+		c.LoadBalancer.validate(),
+		c.SKSAgent.validate(),
+	)
+}
+
+// validate checks the consistency of the global configuration.
+func (c *globalConfig) validate() error {
+	var errs []error
+
+	if (c.APIKey == "") != (c.APISecret == "") {
+		errs = append(errs, errors.New("global: apiKey and apiSecret must be specified together"))
+	} else if !c.hasCredentials(context.Background()) {
+		errs = append(errs, fmt.Errorf("global: %w", errMissingCredentials))
+	}
+
+	if c.APICredentialsSecret != "" {
+		if _, _, err := parseCredentialsSecret(c.APICredentialsSecret); err != nil {
+			errs = append(errs, fmt.Errorf("global.apiCredentialsSecret: %w", err))
+		}
+	}
+
+	if c.APIRoleID != "" {
+		if _, err := v3.ParseUUID(c.APIRoleID); err != nil {
+			errs = append(errs, fmt.Errorf("global.apiRoleID: invalid IAM role ID %q: %w", c.APIRoleID, err))
+		}
+	}
+
+	if c.APIRoleTTL < 0 {
+		errs = append(errs, errors.New("global.apiRoleTTL: must not be negative"))
+	}
+
+	errs = append(errs, c.APIClient.validate(), c.HTTP.validate())
+
+	return errors.Join(errs...)
+}
+
+// hasCredentials returns whether an API credentials source is configured,
+// or else whether credentials can be found in the Exoscale CLI configuration.
+func (c *globalConfig) hasCredentials(ctx context.Context) bool {
+	if c.APIKey != "" || c.APICredentialsFile != "" || c.APICredentialsSecret != "" {
+		return true
+	}
+
+	_, _, err := newCredentialsChain(c, nil).retrieve(ctx)
+
+	return err == nil
+}
+
+// redacted returns a copy of the configuration with its secrets redacted.
+func (c cloudConfig) redacted() cloudConfig {
+	if c.Global.APISecret != "" {
+		c.Global.APISecret = redactedValue
+	}
+
+	for _, proxy := range []*string{&c.Global.HTTP.HTTPProxy, &c.Global.HTTP.HTTPSProxy} {
+		if u, err := url.Parse(*proxy); err == nil && u.User != nil {
+			*proxy = u.Redacted()
+		}
+	}
+
+	return c
+}
+
+// ValidateConfig validates the cloud-config specified (which may be nil) merged
+// with the environment, without reaching the Exoscale API, and returns the
+// effective configuration (YAML) with its secrets redacted.
+func ValidateConfig(config io.Reader) ([]byte, error) {
+	cfg, err := readExoscaleConfig(config)
+	if err != nil {
+		return nil, err
+	}
+
+	// Display the defaults in effect.
+	environment, _ := newAPIEnvironment(cfg.Global.APIEnvironment, cfg.Global.APIEndpointTemplate)
+	cfg.Global.APIEnvironment = environment.name
+	cfg.Global.APIEndpointTemplate = environment.endpointTemplate
+	cfg.Global.APIClient = cfg.Global.APIClient.withDefaults()
+	cfg.LoadBalancer = cfg.LoadBalancer.withDefaults()
+	cfg.SKSAgent = cfg.SKSAgent.withDefaults()
+	if cfg.Global.APIRoleID != "" && cfg.Global.APIRoleTTL == 0 {
+		cfg.Global.APIRoleTTL = defaultAPIRoleTTL
+	}
+
+	var effective bytes.Buffer
+	encoder := yaml.NewEncoder(&effective)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(cfg.redacted()); err != nil {
+		return nil, err
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, err
+	}
+
+	return effective.Bytes(), nil
+}
@@ -0,0 +1,141 @@
+package exoscale
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"time"
+
+	"gopkg.in/fsnotify.v1"
+)
+
+// cloudConfigFileWatchRetryInterval is the delay between attempts to watch
+// the cloud-config file directory.
+const cloudConfigFileWatchRetryInterval = 10 * time.Second
+
+// config returns the cloud-config currently in effect.
+func (p *cloudProvider) config() *cloudConfig {
+	p.cfgMu.RLock()
+	defer p.cfgMu.RUnlock()
+
+	return p.cfg
+}
+
+// reloadConfig validates the cloud-config specified and swaps it in place of
+// the one currently in effect, which is kept if the new one is invalid.
+//
+// Only the instances (overrides), load balancer and SKS agent runners settings
+// are reloaded: the global (API client) settings, the controllers and SKS agent
+// runners enablement and the SKS agent leader election require restarting the
+// CCM to be applied.
+func (p *cloudProvider) reloadConfig(data []byte) error {
+	cfg, err := readExoscaleConfig(bytes.NewReader(data))
+	if err != nil {
+		metricCloudConfigReloads.WithLabelValues("failure").Inc()
+		return err
+	}
+
+	p.cfgMu.Lock()
+	defer p.cfgMu.Unlock()
+
+	if !reflect.DeepEqual(cfg.Global, p.cfg.Global) {
+		warnf("cloud-config: changes to the global section are ignored until the CCM restarts")
+		cfg.Global = p.cfg.Global
+	}
+	if cfg.Instances.Disabled != p.cfg.Instances.Disabled {
+		warnf("cloud-config: changes to instances.disabled are ignored until the CCM restarts")
+		cfg.Instances.Disabled = p.cfg.Instances.Disabled
+	}
+	if cfg.LoadBalancer.Disabled != p.cfg.LoadBalancer.Disabled {
+		warnf("cloud-config: changes to loadBalancer.disabled are ignored until the CCM restarts")
+		cfg.LoadBalancer.Disabled = p.cfg.LoadBalancer.Disabled
+	}
+	if !slices.Equal(cfg.SKSAgent.Runners, p.cfg.SKSAgent.Runners) {
+		warnf("cloud-config: changes to sksAgent.runners are ignored until the CCM restarts")
+		cfg.SKSAgent.Runners = p.cfg.SKSAgent.Runners
+	}
+	if cfg.SKSAgent.LeaderElection != p.cfg.SKSAgent.LeaderElection {
+		warnf("cloud-config: changes to sksAgent.leaderElection are ignored until the CCM restarts")
+		cfg.SKSAgent.LeaderElection = p.cfg.SKSAgent.LeaderElection
+	}
+
+	p.cfg = &cfg
+	metricCloudConfigReloads.WithLabelValues("success").Inc()
+
+	return nil
+}
+
+// watchConfigFile reloads the cloud-config every time the file specified
+// changes, until the context is cancelled.
+func (p *cloudProvider) watchConfigFile(ctx context.Context, path string) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		errorf("failed to watch cloud-config file %q: %v", path, err)
+		return
+	}
+	defer watcher.Close()
+
+	// The file content at startup, used to skip reloads upon events which
+	// don't change it.
+	current, err := os.ReadFile(path)
+	if err != nil {
+		errorf("failed to read cloud-config file %q: %v", path, err)
+	}
+
+	// We watch the folder because the file might get replaced, e.g. when
+	// mounted from a Kubernetes ConfigMap (whose files are symlinks into
+	// a directory swapped atomically upon update).
+	for {
+		if err = watcher.Add(filepath.Dir(path)); err == nil {
+			break
+		}
+		errorf("failed to watch cloud-config file %q, retrying in %s: %v", path, cloudConfigFileWatchRetryInterval, err)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(cloudConfigFileWatchRetryInterval):
+		}
+	}
+
+	for {
+		select {
+		case _, ok := <-watcher.Events:
+			if !ok {
+				errorf("cloud-config file watcher event channel closed")
+				return
+			}
+
+			data, err := os.ReadFile(path)
+			if err != nil {
+				// The file might be in the middle of being replaced.
+				debugf("failed to read cloud-config file %q: %v", path, err)
+				continue
+			}
+			if bytes.Equal(data, current) {
+				continue
+			}
+			current = data
+
+			if err := p.reloadConfig(data); err != nil {
+				errorf("failed to reload cloud-config from file %q, keeping the current configuration: %v", path, err)
+				continue
+			}
+			infof("reloaded cloud-config from file %q", path)
+
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				errorf("cloud-config file watcher error channel closed")
+				return
+			}
+			errorf("error while watching cloud-config file %q: %v", path, err)
+
+		case <-ctx.Done():
+			infof("closing cloud-config file watcher")
+			return
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package exoscale
+
+import (
+	"context"
+	"os"
+	"path"
+	"time"
+
+	"k8s.io/apimachinery/pkg/types"
+)
+
+var testConfigYAML_reloaded = `---
+instances:
+  overrides:
+    - name: "reloaded-node"
+      external: true
+      externalID: "reloaded"
+loadBalancer:
+  retainOnDelete: true
+`
+
+func (ts *exoscaleCCMTestSuite) Test_cloudProvider_reloadConfig() {
+	os.Unsetenv("EXOSCALE_API_KEY")
+	os.Unsetenv("EXOSCALE_API_SECRET")
+	os.Unsetenv("EXOSCALE_API_ENDPOINT")
+	ts.setTestCredentialsEnv()
+
+	ts.Require().NoError(ts.p.reloadConfig([]byte(testConfigYAML_reloaded)))
+
+	// The components use the reloaded configuration...
+	instanceID, err := ts.p.instances.InstanceID(context.Background(), types.NodeName("reloaded-node"))
+	ts.Require().NoError(err)
+	ts.Require().Equal("reloaded", instanceID)
+	ts.Require().True(ts.p.loadBalancer.(*loadBalancer).config().RetainOnDelete)
+
+	// ... except for its global section, which requires a restart.
+	ts.Require().Equal(testConfig_typical.Global, ts.p.config().Global)
+
+	// An invalid configuration is not swapped in.
+	ts.Require().Error(ts.p.reloadConfig([]byte("---\ninstances:\n  overrides:\n    - name: \"/[/\"\n")))
+	ts.Require().True(ts.p.config().LoadBalancer.RetainOnDelete)
+}
+
+func (ts *exoscaleCCMTestSuite) Test_cloudProvider_watchConfigFile() {
+	os.Unsetenv("EXOSCALE_API_KEY")
+	os.Unsetenv("EXOSCALE_API_SECRET")
+	os.Unsetenv("EXOSCALE_API_ENDPOINT")
+	ts.setTestCredentialsEnv()
+
+	tmpdir, err := os.MkdirTemp(os.TempDir(), "exoscale-ccm")
+	ts.Require().NoError(err)
+	defer os.RemoveAll(tmpdir)
+
+	testCloudConfigFile := path.Join(tmpdir, "cloud-config.yaml")
+	ts.Require().NoError(os.WriteFile(testCloudConfigFile, []byte(testConfigYAML_typical), 0o600))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go ts.p.watchConfigFile(ctx, testCloudConfigFile)
+
+	time.Sleep(1 * time.Second)
+	ts.Require().NoError(os.WriteFile(testCloudConfigFile, []byte(testConfigYAML_reloaded), 0o600))
+	time.Sleep(1 * time.Second)
+
+	ts.Require().True(ts.p.config().LoadBalancer.RetainOnDelete)
+	ts.Require().Len(ts.p.config().Instances.Overrides, 1)
+	ts.Require().Equal("reloaded-node", ts.p.config().Instances.Overrides[0].Name)
+}
@@ -9,6 +9,7 @@ import (
 	"github.com/gofrs/uuid"
 	"github.com/stretchr/testify/suite"
 	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/record"
 )
 
 var (
@@ -23,16 +24,17 @@ type exoscaleCCMTestSuite struct {
 
 func (ts *exoscaleCCMTestSuite) SetupTest() {
 	ts.p = &cloudProvider{
-		cfg:     &testConfig_typical,
-		ctx:     context.Background(),
-		client:  new(exoscaleClientMock),
-		kclient: fake.NewSimpleClientset(),
-		zone:    testZone,
+		cfg:      &testConfig_typical,
+		ctx:      context.Background(),
+		client:   new(exoscaleClientMock),
+		kclient:  fake.NewSimpleClientset(),
+		recorder: record.NewFakeRecorder(100),
+		zone:     testZone,
 	}
 
-	ts.p.instances = &instances{p: ts.p, cfg: &testConfig_typical.Instances}
-	ts.p.instancesV2 = &instancesV2{p: ts.p, cfg: &testConfig_typical.Instances}
-	ts.p.loadBalancer = &loadBalancer{p: ts.p, cfg: &testConfig_typical.LoadBalancer}
+	ts.p.instances = &instances{p: ts.p}
+	ts.p.instancesV2 = &instancesV2{p: ts.p}
+	ts.p.loadBalancer = &loadBalancer{p: ts.p}
 	ts.p.zones = &zones{p: ts.p}
 }
 
@@ -0,0 +1,134 @@
+package exoscale
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"k8s.io/apiserver/pkg/server/healthz"
+	cloudprovider "k8s.io/cloud-provider"
+)
+
+// DefaultHealthzBindAddress is the default address the Exoscale-specific
+// health checks are served on, see ServeHealthz.
+const DefaultHealthzBindAddress = ":10260"
+
+// APICredentialsHealthCheckName is the name of the health check reporting
+// whether valid Exoscale API credentials are in use, served under
+// /readyz/<name> only by ServeHealthz: invalid API credentials must not cause
+// the CCM to be restarted.
+const APICredentialsHealthCheckName = "exoscale-api-credentials"
+
+// SKSAgentRunnerHealthCheckNamePrefix prefixes the names of the health checks
+// reporting whether the SKS agent runners are running and functional, one per
+// runner, served under /healthz/<name> and /readyz/<name> by ServeHealthz.
+const SKSAgentRunnerHealthCheckNamePrefix = "exoscale-sks-agent-"
+
+// Start starts the Exoscale cloud provider on this CCM replica, until stop is
+// closed: as opposed to Initialize, only called on the CCM leader, it is meant
+// to be called by every replica regardless of the leader election, so that
+// the tasks having their own leader election (the SKS agent) run and standby
+// replicas report their health (see ServeHealthz).
+func Start(cloud cloudprovider.Interface, clientBuilder cloudprovider.ControllerClientBuilder, stop <-chan struct{}) error {
+	p, ok := cloud.(*cloudProvider)
+	if !ok {
+		return fmt.Errorf("unsupported cloud provider %T", cloud)
+	}
+
+	p.start(clientBuilder, stop)
+
+	return nil
+}
+
+// ServeHealthz serves the health checks of the Exoscale cloud provider,
+// previously started using Start, under /healthz (the SKS agent runners ones)
+// and /readyz (all of them) on the address specified, until stop is closed.
+//
+// As opposed to the checks of the cloud controllers, only served by the CCM
+// leader, these are served by every replica regardless of the leader
+// election, so that standby replicas report e.g. invalid API credentials
+// before taking over.
+func ServeHealthz(cloud cloudprovider.Interface, addr string, stop <-chan struct{}) error {
+	p, ok := cloud.(*cloudProvider)
+	if !ok {
+		return fmt.Errorf("unsupported cloud provider %T", cloud)
+	}
+
+	mux := http.NewServeMux()
+	livez, readyz := p.healthChecks()
+	healthz.InstallHandler(mux, livez...)
+	healthz.InstallReadyzHandler(mux, readyz...)
+
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", addr, err)
+	}
+
+	server := &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		<-stop
+		_ = server.Close()
+	}()
+	go func() {
+		infof("serving health checks on %s", listener.Addr())
+		if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			errorf("health checks server failed: %v", err)
+		}
+	}()
+
+	return nil
+}
+
+// healthChecks returns the Exoscale-specific health checks served by
+// ServeHealthz: the liveness ones, one per enabled SKS agent runner, and the
+// readiness ones, adding the API credentials one.
+func (p *cloudProvider) healthChecks() (livez, readyz []healthz.HealthChecker) {
+	if p.sksAgent != nil {
+		for _, def := range p.sksAgent.runners {
+			livez = append(livez, &sksAgentRunnerHealthChecker{agent: p.sksAgent, runner: def.name})
+		}
+	}
+
+	readyz = append([]healthz.HealthChecker{&apiCredentialsHealthChecker{p: p}}, livez...)
+
+	return livez, readyz
+}
+
+// apiCredentialsHealthChecker reports the Exoscale API credentials health.
+type apiCredentialsHealthChecker struct {
+	p *cloudProvider
+}
+
+var _ healthz.HealthChecker = (*apiCredentialsHealthChecker)(nil)
+
+func (c *apiCredentialsHealthChecker) Name() string {
+	return APICredentialsHealthCheckName
+}
+
+func (c *apiCredentialsHealthChecker) Check(_ *http.Request) error {
+	client, ok := c.p.client.(*refreshableExoscaleClient)
+	if !ok {
+		return errors.New("exoscale client not initialized")
+	}
+
+	return client.credentialsHealth()
+}
+
+// sksAgentRunnerHealthChecker reports the health of an SKS agent runner on
+// this replica.
+type sksAgentRunnerHealthChecker struct {
+	agent  *sksAgent
+	runner string
+}
+
+var _ healthz.HealthChecker = (*sksAgentRunnerHealthChecker)(nil)
+
+func (c *sksAgentRunnerHealthChecker) Name() string {
+	return SKSAgentRunnerHealthCheckNamePrefix + c.runner
+}
+
+func (c *sksAgentRunnerHealthChecker) Check(_ *http.Request) error {
+	return c.agent.runnerHealth(c.runner)
+}
@@ -0,0 +1,59 @@
+package exoscale
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+
+	v3 "github.com/exoscale/egoscale/v3"
+	"k8s.io/apiserver/pkg/server/healthz"
+)
+
+func (ts *exoscaleCCMTestSuite) Test_apiCredentialsHealthChecker_Check() {
+	client := newTestRefreshableExoscaleClient(&globalConfig{APIKey: testAPIKey, APISecret: testAPISecret}, nil)
+	ts.p.client = client
+	checker := &apiCredentialsHealthChecker{p: ts.p}
+
+	ts.Require().NoError(client.refreshCredentials(context.Background()))
+	ts.Require().NoError(checker.Check(&http.Request{}))
+
+	client.validateCredentials = func(context.Context, *v3.Client) error { return errors.New("unauthorized") }
+	ts.Require().Error(client.refreshCredentials(context.Background()))
+	ts.Require().ErrorContains(checker.Check(&http.Request{}), "unauthorized")
+}
+
+func (ts *exoscaleCCMTestSuite) Test_cloudProvider_healthChecks() {
+	client := newTestRefreshableExoscaleClient(&globalConfig{APIKey: testAPIKey, APISecret: testAPISecret}, nil)
+	client.validateCredentials = func(context.Context, *v3.Client) error { return errors.New("unauthorized") }
+	ts.p.client = client
+	ts.Require().Error(client.refreshCredentials(context.Background()))
+
+	// The SKS agent runners are reported even though this replica isn't
+	// the leader, i.e. isn't running them.
+	agent, err := newSKSAgent(ts.p, sksAgentConfig{
+		Runners:        []string{sksAgentNodeCSRValidation},
+		LeaderElection: sksAgentLeaderElectionConfig{Disabled: true},
+	})
+	ts.Require().NoError(err)
+	ts.p.sksAgent = agent
+
+	mux := http.NewServeMux()
+	livez, readyz := ts.p.healthChecks()
+	healthz.InstallHandler(mux, livez...)
+	healthz.InstallReadyzHandler(mux, readyz...)
+
+	// Invalid API credentials only fail the readiness checks.
+	for path, code := range map[string]int{
+		"/healthz": http.StatusOK,
+		"/healthz/" + APICredentialsHealthCheckName:                                   http.StatusNotFound,
+		"/healthz/" + SKSAgentRunnerHealthCheckNamePrefix + sksAgentNodeCSRValidation: http.StatusOK,
+		"/readyz": http.StatusInternalServerError,
+		"/readyz/" + APICredentialsHealthCheckName:                                   http.StatusInternalServerError,
+		"/readyz/" + SKSAgentRunnerHealthCheckNamePrefix + sksAgentNodeCSRValidation: http.StatusOK,
+	} {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		ts.Require().Equal(code, rec.Code, path)
+	}
+}
@@ -21,21 +21,26 @@ import (
 var labelInvalidCharsRegex = regexp.MustCompile(`^[^A-Za-z0-9]|[^A-Za-z0-9]$|([^-A-Za-z0-9_.])`)
 
 type instances struct {
-	p   *cloudProvider
-	cfg *instancesConfig
+	p *cloudProvider
 }
 
-func newInstances(provider *cloudProvider, config *instancesConfig) cloudprovider.Instances {
-	return &instances{
-		p:   provider,
-		cfg: config,
-	}
+func newInstances(provider *cloudProvider) cloudprovider.Instances {
+	return &instances{p: provider}
+}
+
+// config returns the instances configuration currently in effect.
+func (i *instances) config() *instancesConfig {
+	return &i.p.config().Instances
 }
 
 // NodeAddresses returns the addresses of the specified instance.
 func (i *instances) NodeAddresses(ctx context.Context, nodeName types.NodeName) ([]v1.NodeAddress, error) {
+	ctx = withAPICaller(ctx, apiCallerNode)
+
+	cfg := i.config()
+
 	// first look for a statically-configured override
-	override := i.cfg.getInstanceOverride(nodeName)
+	override := cfg.getInstanceOverride(nodeName)
 	if override != nil {
 		if n := len(override.Addresses); n > 0 {
 			nodeAddresses := make([]v1.NodeAddress, n)
@@ -52,7 +57,7 @@ func (i *instances) NodeAddresses(ctx context.Context, nodeName types.NodeName)
 	}
 
 	// Use Exoscale API ?
-	if i.cfg.ExternalOnly {
+	if cfg.ExternalOnly {
 		return nil, fmt.Errorf("no override found (Exoscale API disabled)")
 	}
 
@@ -70,8 +75,12 @@ func (i *instances) NodeAddresses(ctx context.Context, nodeName types.NodeName)
 // from the node whose nodeaddresses are being queried. i.e. local metadata
 // services cannot be used in this method to obtain nodeaddresses
 func (i *instances) NodeAddressesByProviderID(ctx context.Context, providerID string) ([]v1.NodeAddress, error) {
+	ctx = withAPICaller(ctx, apiCallerNode)
+
+	cfg := i.config()
+
 	// first look for a statically-configured override
-	override := i.cfg.getInstanceOverrideByProviderID(providerID)
+	override := cfg.getInstanceOverrideByProviderID(providerID)
 	if override != nil {
 		if n := len(override.Addresses); n > 0 {
 			nodeAddresses := make([]v1.NodeAddress, n)
@@ -88,7 +97,7 @@ func (i *instances) NodeAddressesByProviderID(ctx context.Context, providerID st
 	}
 
 	// Use Exoscale API ?
-	if i.cfg.ExternalOnly {
+	if cfg.ExternalOnly {
 		return nil, fmt.Errorf("no override found (Exoscale API disabled)")
 	}
 
@@ -161,8 +170,12 @@ func (i *instances) NodeAddressesByProviderID(ctx context.Context, providerID st
 // (see GetInstanceProviderID in https://github.com/kubernetes/cloud-provider/blob/master/cloud.go)
 // TL;DR: ProviderID = "exoscale://<InstanceID>"
 func (i *instances) InstanceID(ctx context.Context, nodeName types.NodeName) (string, error) {
+	ctx = withAPICaller(ctx, apiCallerNode)
+
+	cfg := i.config()
+
 	// first look for a statically-configured override
-	override := i.cfg.getInstanceOverride(nodeName)
+	override := cfg.getInstanceOverride(nodeName)
 	if override != nil {
 		if override.External {
 			if override.ExternalID != "" {
@@ -174,7 +187,7 @@ func (i *instances) InstanceID(ctx context.Context, nodeName types.NodeName) (st
 	}
 
 	// Use Exoscale API ?
-	if i.cfg.ExternalOnly {
+	if cfg.ExternalOnly {
 		return "", fmt.Errorf("no override found (Exoscale API disabled)")
 	}
 
@@ -189,8 +202,12 @@ func (i *instances) InstanceID(ctx context.Context, nodeName types.NodeName) (st
 
 // InstanceType returns the type of the specified instance.
 func (i *instances) InstanceType(ctx context.Context, nodeName types.NodeName) (string, error) {
+	ctx = withAPICaller(ctx, apiCallerNode)
+
+	cfg := i.config()
+
 	// first look for a statically-configured override
-	override := i.cfg.getInstanceOverride(nodeName)
+	override := cfg.getInstanceOverride(nodeName)
 	if override != nil {
 		if override.Type != "" {
 			return override.Type, nil
@@ -200,7 +217,7 @@ func (i *instances) InstanceType(ctx context.Context, nodeName types.NodeName) (
 	}
 
 	// Use Exoscale API ?
-	if i.cfg.ExternalOnly {
+	if cfg.ExternalOnly {
 		return "", fmt.Errorf("no override found (Exoscale API disabled)")
 	}
 
@@ -214,8 +231,12 @@ func (i *instances) InstanceType(ctx context.Context, nodeName types.NodeName) (
 
 // InstanceTypeByProviderID returns the type of the specified instance.
 func (i *instances) InstanceTypeByProviderID(ctx context.Context, providerID string) (string, error) {
+	ctx = withAPICaller(ctx, apiCallerNode)
+
+	cfg := i.config()
+
 	// first look for a statically-configured override
-	override := i.cfg.getInstanceOverrideByProviderID(providerID)
+	override := cfg.getInstanceOverrideByProviderID(providerID)
 	if override != nil {
 		if override.Type != "" {
 			return override.Type, nil
@@ -225,7 +246,7 @@ func (i *instances) InstanceTypeByProviderID(ctx context.Context, providerID str
 	}
 
 	// Use Exoscale API ?
-	if i.cfg.ExternalOnly {
+	if cfg.ExternalOnly {
 		return "", fmt.Errorf("no override found (Exoscale API disabled)")
 	}
 
@@ -258,8 +279,12 @@ func (i *instances) CurrentNodeName(_ context.Context, hostname string) (types.N
 // If false is returned with no error, the instance will be immediately deleted by the cloud controller manager.
 // This method should still return true for instances that exist but are stopped/sleeping.
 func (i *instances) InstanceExistsByProviderID(ctx context.Context, providerID string) (bool, error) {
+	ctx = withAPICaller(ctx, apiCallerNode)
+
+	cfg := i.config()
+
 	// first look for a statically-configured override
-	override := i.cfg.getInstanceOverrideByProviderID(providerID)
+	override := cfg.getInstanceOverrideByProviderID(providerID)
 	if override != nil {
 		if override.External {
 			return true, nil
@@ -267,7 +292,7 @@ func (i *instances) InstanceExistsByProviderID(ctx context.Context, providerID s
 	}
 
 	// Use Exoscale API ?
-	if i.cfg.ExternalOnly {
+	if cfg.ExternalOnly {
 		return false, nil
 	}
 
@@ -285,8 +310,12 @@ func (i *instances) InstanceExistsByProviderID(ctx context.Context, providerID s
 
 // InstanceShutdownByProviderID returns true if the instance is shutdown in cloudprovider
 func (i *instances) InstanceShutdownByProviderID(ctx context.Context, providerID string) (bool, error) {
+	ctx = withAPICaller(ctx, apiCallerNode)
+
+	cfg := i.config()
+
 	// first look for a statically-configured override
-	override := i.cfg.getInstanceOverrideByProviderID(providerID)
+	override := cfg.getInstanceOverrideByProviderID(providerID)
 	if override != nil {
 		if override.External {
 			return false, cloudprovider.NotImplemented
@@ -294,7 +323,7 @@ func (i *instances) InstanceShutdownByProviderID(ctx context.Context, providerID
 	}
 
 	// Use Exoscale API ?
-	if i.cfg.ExternalOnly {
+	if cfg.ExternalOnly {
 		return false, fmt.Errorf("no override found (Exoscale API disabled)")
 	}
 
@@ -307,20 +336,20 @@ func (i *instances) InstanceShutdownByProviderID(ctx context.Context, providerID
 }
 
 func (c *refreshableExoscaleClient) GetInstance(ctx context.Context, id v3.UUID) (*v3.Instance, error) {
-	c.RLock()
-	defer c.RUnlock()
+	ctx, cancel := c.withTimeout(ctx, "GetInstance")
+	defer cancel()
 
-	return c.exo.GetInstance(
+	return c.client().GetInstance(
 		ctx,
 		id,
 	)
 }
 
 func (c *refreshableExoscaleClient) GetInstanceType(ctx context.Context, id v3.UUID) (*v3.InstanceType, error) {
-	c.RLock()
-	defer c.RUnlock()
+	ctx, cancel := c.withTimeout(ctx, "GetInstanceType")
+	defer cancel()
 
-	return c.exo.GetInstanceType(
+	return c.client().GetInstanceType(
 		ctx,
 		id,
 	)
@@ -330,10 +359,10 @@ func (c *refreshableExoscaleClient) ListInstances(
 	ctx context.Context,
 	opts ...v3.ListInstancesOpt,
 ) (*v3.ListInstancesResponse, error) {
-	c.RLock()
-	defer c.RUnlock()
+	ctx, cancel := c.withTimeout(ctx, "ListInstances")
+	defer cancel()
 
-	return c.exo.ListInstances(
+	return c.client().ListInstances(
 		ctx,
 		opts...,
 	)
@@ -2,10 +2,13 @@ package exoscale
 
 import (
 	"crypto/sha256"
+	"errors"
 	"fmt"
+	"net"
 	"regexp"
 	"strings"
 
+	v1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/types"
 )
 
@@ -34,6 +37,60 @@ func defaultInstanceOverrideExternalID(overrideName string) string {
 	return fmt.Sprintf("external-%x", sha256.Sum256([]byte(overrideName)))
 }
 
+// validate checks the consistency of the instances configuration.
+func (c *instancesConfig) validate() error {
+	var (
+		errs        []error
+		externalIDs = make(map[string]int)
+	)
+
+	for i, override := range c.Overrides {
+		field := fmt.Sprintf("instances.overrides[%d]", i)
+
+		if override.Name == "" {
+			errs = append(errs, fmt.Errorf("%s.name: must be specified", field))
+		} else if len(override.Name) > 1 && strings.HasPrefix(override.Name, "/") && strings.HasSuffix(override.Name, "/") {
+			if _, err := regexp.Compile(strings.Trim(override.Name, "/")); err != nil {
+				errs = append(errs, fmt.Errorf("%s.name: invalid regular expression: %w", field, err))
+			}
+		}
+
+		externalID := override.ExternalID
+		if externalID == "" {
+			externalID = defaultInstanceOverrideExternalID(override.Name)
+		}
+		if j, ok := externalIDs[externalID]; ok {
+			errs = append(errs, fmt.Errorf("%s: duplicate external ID %q (already used by instances.overrides[%d])",
+				field, externalID, j))
+		} else {
+			externalIDs[externalID] = i
+		}
+
+		for j, address := range override.Addresses {
+			field := fmt.Sprintf("%s.addresses[%d]", field, j)
+
+			switch v1.NodeAddressType(address.Type) {
+			case v1.NodeInternalIP, v1.NodeExternalIP:
+				if net.ParseIP(address.Address) == nil {
+					errs = append(errs, fmt.Errorf("%s.address: invalid IP address %q", field, address.Address))
+				}
+
+			case v1.NodeHostName, v1.NodeInternalDNS, v1.NodeExternalDNS:
+				if address.Address == "" {
+					errs = append(errs, fmt.Errorf("%s.address: must be specified", field))
+				}
+
+			default:
+				errs = append(errs, fmt.Errorf("%s.type: invalid address type %q, expected one of %s, %s, %s, %s or %s",
+					field, address.Type,
+					v1.NodeHostName, v1.NodeExternalIP, v1.NodeInternalIP, v1.NodeExternalDNS, v1.NodeInternalDNS))
+			}
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
 // Return statically-configured instance override
 func (c *instancesConfig) getInstanceOverride(nodeName types.NodeName) *instancesOverrideConfig {
 	var config *instancesOverrideConfig
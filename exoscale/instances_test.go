@@ -38,7 +38,7 @@ func (ts *exoscaleCCMTestSuite) TestNodeAddresses() {
 	}
 
 	ts.p.client.(*exoscaleClientMock).
-		On("GetInstance", ts.p.ctx, testInstanceID).
+		On("GetInstance", withAPICaller(ts.p.ctx, apiCallerNode), testInstanceID).
 		Return(
 			resp,
 			nil,
@@ -152,7 +152,7 @@ func (ts *exoscaleCCMTestSuite) TestNodeAddresses() {
 
 func (ts *exoscaleCCMTestSuite) TestNodeAddressesByProviderID() {
 	ts.p.client.(*exoscaleClientMock).
-		On("GetInstance", ts.p.ctx, testInstanceID).
+		On("GetInstance", withAPICaller(ts.p.ctx, apiCallerNode), testInstanceID).
 		Return(
 			&v3.Instance{
 				ID:       testInstanceID,
@@ -199,7 +199,7 @@ func (ts *exoscaleCCMTestSuite) TestNodeAddressesByProviderID() {
 
 func (ts *exoscaleCCMTestSuite) TestNodeAddressesByProviderID_WithIPV6Enabled() {
 	ts.p.client.(*exoscaleClientMock).
-		On("GetInstance", ts.p.ctx, testInstanceID).
+		On("GetInstance", withAPICaller(ts.p.ctx, apiCallerNode), testInstanceID).
 		Return(
 			&v3.Instance{
 				ID:          testInstanceID,
@@ -255,7 +255,7 @@ func (ts *exoscaleCCMTestSuite) TestNodeAddressesByProviderID_WithIPV6Enabled()
 
 func (ts *exoscaleCCMTestSuite) TestNodeAddressesByProviderID_WithPrivateNetworkIDs() {
 	ts.p.client.(*exoscaleClientMock).
-		On("GetInstance", ts.p.ctx, testInstanceID).
+		On("GetInstance", withAPICaller(ts.p.ctx, apiCallerNode), testInstanceID).
 		Return(
 			&v3.Instance{
 				ID:       testInstanceID,
@@ -308,7 +308,7 @@ func (ts *exoscaleCCMTestSuite) TestNodeAddressesByProviderID_WithPrivateNetwork
 
 func (ts *exoscaleCCMTestSuite) TestNodeAddressesByProviderID_WithOnlyPrivateNetworkIDs() {
 	ts.p.client.(*exoscaleClientMock).
-		On("GetInstance", ts.p.ctx, testInstanceID).
+		On("GetInstance", withAPICaller(ts.p.ctx, apiCallerNode), testInstanceID).
 		Return(
 			&v3.Instance{
 				ID:   v3.UUID(testInstanceID),
@@ -377,7 +377,7 @@ func (ts *exoscaleCCMTestSuite) TestInstanceID() {
 
 func (ts *exoscaleCCMTestSuite) TestInstanceType() {
 	ts.p.client.(*exoscaleClientMock).
-		On("GetInstance", ts.p.ctx, testInstanceID).
+		On("GetInstance", withAPICaller(ts.p.ctx, apiCallerNode), testInstanceID).
 		Return(
 			&v3.Instance{
 				ID: testInstanceID,
@@ -390,7 +390,7 @@ func (ts *exoscaleCCMTestSuite) TestInstanceType() {
 		)
 
 	ts.p.client.(*exoscaleClientMock).
-		On("GetInstanceType", ts.p.ctx, testInstanceTypeID).
+		On("GetInstanceType", withAPICaller(ts.p.ctx, apiCallerNode), testInstanceTypeID).
 		Return(
 			&v3.InstanceType{
 				Authorized: &testInstanceTypeAuthorized,
@@ -426,7 +426,7 @@ func (ts *exoscaleCCMTestSuite) TestInstanceType() {
 
 func (ts *exoscaleCCMTestSuite) TestInstanceTypeByProviderID() {
 	ts.p.client.(*exoscaleClientMock).
-		On("GetInstance", ts.p.ctx, testInstanceID).
+		On("GetInstance", withAPICaller(ts.p.ctx, apiCallerNode), testInstanceID).
 		Return(
 			&v3.Instance{
 				ID: testInstanceID,
@@ -439,7 +439,7 @@ func (ts *exoscaleCCMTestSuite) TestInstanceTypeByProviderID() {
 		)
 
 	ts.p.client.(*exoscaleClientMock).
-		On("GetInstanceType", ts.p.ctx, testInstanceTypeID).
+		On("GetInstanceType", withAPICaller(ts.p.ctx, apiCallerNode), testInstanceTypeID).
 		Return(
 			&v3.InstanceType{
 				Authorized: &testInstanceTypeAuthorized,
@@ -501,7 +501,7 @@ func (ts *exoscaleCCMTestSuite) TestCurrentNodeName() {
 // TODO FIX THIs TEST
 func (ts *exoscaleCCMTestSuite) TestInstanceExistsByProviderID() {
 	ts.p.client.(*exoscaleClientMock).
-		On("GetInstance", ts.p.ctx, testInstanceID).
+		On("GetInstance", withAPICaller(ts.p.ctx, apiCallerNode), testInstanceID).
 		Return(
 			&v3.Instance{
 				ID:   testInstanceID,
@@ -534,7 +534,7 @@ func (ts *exoscaleCCMTestSuite) TestInstanceExistsByProviderID() {
 	// nonExistentID := ts.randomID()
 
 	// ts.p.client.(*exoscaleClientMock).
-	// 	On("GetInstance", ts.p.ctx, nonExistentID).
+	// 	On("GetInstance", withAPICaller(ts.p.ctx, apiCallerNode), nonExistentID).
 	// 	Return(&v3.Instance{}, v3.ErrNotFound)
 
 	// exists, err = ts.p.instances.InstanceExistsByProviderID(ts.p.ctx, providerPrefix+nonExistentID)
@@ -544,7 +544,7 @@ func (ts *exoscaleCCMTestSuite) TestInstanceExistsByProviderID() {
 
 func (ts *exoscaleCCMTestSuite) TestInstanceShutdownByProviderID() {
 	ts.p.client.(*exoscaleClientMock).
-		On("GetInstance", ts.p.ctx, testInstanceID).
+		On("GetInstance", withAPICaller(ts.p.ctx, apiCallerNode), testInstanceID).
 		Return(
 			&v3.Instance{
 				ID:    testInstanceID,
@@ -14,22 +14,27 @@ import (
 )
 
 type instancesV2 struct {
-	p   *cloudProvider
-	cfg *instancesConfig
+	p *cloudProvider
 }
 
-func newInstancesV2(provider *cloudProvider, config *instancesConfig) cloudprovider.InstancesV2 {
-	return &instancesV2{
-		p:   provider,
-		cfg: config,
-	}
+func newInstancesV2(provider *cloudProvider) cloudprovider.InstancesV2 {
+	return &instancesV2{p: provider}
+}
+
+// config returns the instances configuration currently in effect.
+func (i *instancesV2) config() *instancesConfig {
+	return &i.p.config().Instances
 }
 
 // InstanceExists returns true if the instance for the given node exists according to the cloud provider.
 // Use the node.name or node.spec.providerID field to find the node in the cloud provider.
 func (i *instancesV2) InstanceExists(ctx context.Context, node *v1.Node) (bool, error) {
+	ctx = withAPICaller(ctx, apiCallerNode)
+
+	cfg := i.config()
+
 	// first look for a statically-configured override
-	override := i.nodeInstanceOverride(node)
+	override := i.nodeInstanceOverride(cfg, node)
 	if override != nil {
 		if override.External {
 			return true, nil
@@ -37,7 +42,7 @@ func (i *instancesV2) InstanceExists(ctx context.Context, node *v1.Node) (bool,
 	}
 
 	// Use Exoscale API ?
-	if i.cfg.ExternalOnly {
+	if cfg.ExternalOnly {
 		return false, nil
 	}
 
@@ -56,8 +61,12 @@ func (i *instancesV2) InstanceExists(ctx context.Context, node *v1.Node) (bool,
 // InstanceShutdown returns true if the instance is shutdown according to the cloud provider.
 // Use the node.name or node.spec.providerID field to find the node in the cloud provider.
 func (i *instancesV2) InstanceShutdown(ctx context.Context, node *v1.Node) (bool, error) {
+	ctx = withAPICaller(ctx, apiCallerNode)
+
+	cfg := i.config()
+
 	// first look for a statically-configured override
-	override := i.nodeInstanceOverride(node)
+	override := i.nodeInstanceOverride(cfg, node)
 	if override != nil {
 		if override.External {
 			return false, cloudprovider.NotImplemented
@@ -65,7 +74,7 @@ func (i *instancesV2) InstanceShutdown(ctx context.Context, node *v1.Node) (bool
 	}
 
 	// Use Exoscale API ?
-	if i.cfg.ExternalOnly {
+	if cfg.ExternalOnly {
 		return false, fmt.Errorf("no override found (Exoscale API disabled)")
 	}
 
@@ -80,10 +89,14 @@ func (i *instancesV2) InstanceShutdown(ctx context.Context, node *v1.Node) (bool
 // InstanceMetadata returns the instance's metadata. The values returned in InstanceMetadata are
 // translated into specific fields and labels in the Node object on registration.
 func (i *instancesV2) InstanceMetadata(ctx context.Context, node *v1.Node) (*cloudprovider.InstanceMetadata, error) {
+	ctx = withAPICaller(ctx, apiCallerNode)
+
+	cfg := i.config()
+
 	meta := &cloudprovider.InstanceMetadata{}
 
 	// first look for a statically-configured override
-	override := i.nodeInstanceOverride(node)
+	override := i.nodeInstanceOverride(cfg, node)
 	if override != nil {
 		if override.Type != "" {
 			meta.InstanceType = override.Type
@@ -117,7 +130,7 @@ func (i *instancesV2) InstanceMetadata(ctx context.Context, node *v1.Node) (*clo
 	}
 
 	// Use Exoscale API ?
-	if i.cfg.ExternalOnly {
+	if cfg.ExternalOnly {
 		return nil, fmt.Errorf("no override found (Exoscale API disabled)")
 	}
 
@@ -153,14 +166,14 @@ func (i *instancesV2) InstanceMetadata(ctx context.Context, node *v1.Node) (*clo
 
 // nodeInstanceOverride returns the statically-configured override matching the
 // node, looking up node.spec.providerID first, then the node name.
-func (i *instancesV2) nodeInstanceOverride(node *v1.Node) *instancesOverrideConfig {
+func (i *instancesV2) nodeInstanceOverride(cfg *instancesConfig, node *v1.Node) *instancesOverrideConfig {
 	if node.Spec.ProviderID != "" {
-		if override := i.cfg.getInstanceOverrideByProviderID(node.Spec.ProviderID); override != nil {
+		if override := cfg.getInstanceOverrideByProviderID(node.Spec.ProviderID); override != nil {
 			return override
 		}
 	}
 
-	return i.cfg.getInstanceOverride(types.NodeName(node.Name))
+	return cfg.getInstanceOverride(types.NodeName(node.Name))
 }
 
 // computeInstanceByNode returns the Exoscale Compute instance backing the node,
@@ -31,7 +31,7 @@ func (ts *exoscaleCCMTestSuite) testNode() *v1.Node {
 
 func (ts *exoscaleCCMTestSuite) TestInstanceExists() {
 	ts.p.client.(*exoscaleClientMock).
-		On("GetInstance", ts.p.ctx, testInstanceID).
+		On("GetInstance", withAPICaller(ts.p.ctx, apiCallerNode), testInstanceID).
 		Return(
 			&v3.Instance{
 				ID:   testInstanceID,
@@ -47,7 +47,7 @@ func (ts *exoscaleCCMTestSuite) TestInstanceExists() {
 
 func (ts *exoscaleCCMTestSuite) TestInstanceExists_uninitializedNode() {
 	ts.p.client.(*exoscaleClientMock).
-		On("GetInstance", ts.p.ctx, testInstanceID).
+		On("GetInstance", withAPICaller(ts.p.ctx, apiCallerNode), testInstanceID).
 		Return(
 			&v3.Instance{
 				ID:   testInstanceID,
@@ -68,7 +68,7 @@ func (ts *exoscaleCCMTestSuite) TestInstanceExists_uninitializedNode() {
 
 func (ts *exoscaleCCMTestSuite) TestInstanceShutdown() {
 	ts.p.client.(*exoscaleClientMock).
-		On("GetInstance", ts.p.ctx, testInstanceID).
+		On("GetInstance", withAPICaller(ts.p.ctx, apiCallerNode), testInstanceID).
 		Return(
 			&v3.Instance{
 				ID:    testInstanceID,
@@ -85,7 +85,7 @@ func (ts *exoscaleCCMTestSuite) TestInstanceShutdown() {
 
 func (ts *exoscaleCCMTestSuite) TestInstanceShutdown_running() {
 	ts.p.client.(*exoscaleClientMock).
-		On("GetInstance", ts.p.ctx, testInstanceID).
+		On("GetInstance", withAPICaller(ts.p.ctx, apiCallerNode), testInstanceID).
 		Return(
 			&v3.Instance{
 				ID:    testInstanceID,
@@ -102,7 +102,7 @@ func (ts *exoscaleCCMTestSuite) TestInstanceShutdown_running() {
 
 func (ts *exoscaleCCMTestSuite) TestInstanceMetadata() {
 	ts.p.client.(*exoscaleClientMock).
-		On("GetInstance", ts.p.ctx, testInstanceID).
+		On("GetInstance", withAPICaller(ts.p.ctx, apiCallerNode), testInstanceID).
 		Return(
 			&v3.Instance{
 				ID: testInstanceID,
@@ -116,7 +116,7 @@ func (ts *exoscaleCCMTestSuite) TestInstanceMetadata() {
 		)
 
 	ts.p.client.(*exoscaleClientMock).
-		On("GetInstanceType", ts.p.ctx, testInstanceTypeID).
+		On("GetInstanceType", withAPICaller(ts.p.ctx, apiCallerNode), testInstanceTypeID).
 		Return(
 			&v3.InstanceType{
 				Authorized: &testInstanceTypeAuthorized,
@@ -148,7 +148,7 @@ func (ts *exoscaleCCMTestSuite) TestInstanceMetadata() {
 
 func (ts *exoscaleCCMTestSuite) TestInstanceMetadata_withPrivateNetworkIDs() {
 	ts.p.client.(*exoscaleClientMock).
-		On("GetInstance", ts.p.ctx, testInstanceID).
+		On("GetInstance", withAPICaller(ts.p.ctx, apiCallerNode), testInstanceID).
 		Return(
 			&v3.Instance{
 				ID: testInstanceID,
@@ -165,7 +165,7 @@ func (ts *exoscaleCCMTestSuite) TestInstanceMetadata_withPrivateNetworkIDs() {
 		)
 
 	ts.p.client.(*exoscaleClientMock).
-		On("GetInstanceType", ts.p.ctx, testInstanceTypeID).
+		On("GetInstanceType", withAPICaller(ts.p.ctx, apiCallerNode), testInstanceTypeID).
 		Return(
 			&v3.InstanceType{
 				Authorized: &testInstanceTypeAuthorized,
@@ -11,29 +11,45 @@ import (
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/go-cmp/cmp/cmpopts"
 	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	cloudprovider "k8s.io/cloud-provider"
 
 	v3 "github.com/exoscale/egoscale/v3"
 )
 
 const (
-	annotationPrefix                                 = "service.beta.kubernetes.io/exoscale-loadbalancer-"
-	annotationLoadBalancerID                         = annotationPrefix + "id"
-	annotationLoadBalancerName                       = annotationPrefix + "name"
-	annotationLoadBalancerDescription                = annotationPrefix + "description"
-	annotationLoadBalancerExternal                   = annotationPrefix + "external"
-	annotationLoadBalancerServiceStrategy            = annotationPrefix + "service-strategy"
-	annotationLoadBalancerServiceName                = annotationPrefix + "service-name"
-	annotationLoadBalancerServiceDescription         = annotationPrefix + "service-description"
-	annotationLoadBalancerServiceInstancePoolID      = annotationPrefix + "service-instancepool-id"
-	annotationLoadBalancerSKSClusterName             = annotationPrefix + "sks-cluster-name" // required for annotationLoadBalancerServiceSKSNodePoolName
-	annotationLoadBalancerServiceSKSNodePoolName     = annotationPrefix + "service-sks-nodepool-name"
-	annotationLoadBalancerServiceHealthCheckMode     = annotationPrefix + "service-healthcheck-mode"
-	annotationLoadBalancerServiceHealthCheckPort     = annotationPrefix + "service-healthcheck-port"
-	annotationLoadBalancerServiceHealthCheckURI      = annotationPrefix + "service-healthcheck-uri"
-	annotationLoadBalancerServiceHealthCheckInterval = annotationPrefix + "service-healthcheck-interval"
-	annotationLoadBalancerServiceHealthCheckTimeout  = annotationPrefix + "service-healthcheck-timeout"
-	annotationLoadBalancerServiceHealthCheckRetries  = annotationPrefix + "service-healthcheck-retries"
+	annotationPrefix                                    = "service.beta.kubernetes.io/exoscale-loadbalancer-"
+	annotationLoadBalancerID                            = annotationPrefix + "id"
+	annotationLoadBalancerName                          = annotationPrefix + "name"
+	annotationLoadBalancerDescription                   = annotationPrefix + "description"
+	annotationLoadBalancerExternal                      = annotationPrefix + "external"
+	annotationLoadBalancerServiceStrategy               = annotationPrefix + "service-strategy"
+	annotationLoadBalancerServiceName                   = annotationPrefix + "service-name"
+	annotationLoadBalancerServiceDescription            = annotationPrefix + "service-description"
+	annotationLoadBalancerServiceInstancePoolID         = annotationPrefix + "service-instancepool-id"
+	annotationLoadBalancerServiceInstancePoolIDInferred = annotationPrefix + "service-instancepool-id-inferred"
+	annotationLoadBalancerSKSClusterName                = annotationPrefix + "sks-cluster-name" // required for annotationLoadBalancerServiceSKSNodePoolName
+	annotationLoadBalancerServiceSKSNodePoolName        = annotationPrefix + "service-sks-nodepool-name"
+	annotationLoadBalancerServiceHealthCheckMode        = annotationPrefix + "service-healthcheck-mode"
+	annotationLoadBalancerServiceHealthCheckPort        = annotationPrefix + "service-healthcheck-port"
+	annotationLoadBalancerServiceHealthCheckURI         = annotationPrefix + "service-healthcheck-uri"
+	annotationLoadBalancerServiceHealthCheckInterval    = annotationPrefix + "service-healthcheck-interval"
+	annotationLoadBalancerServiceHealthCheckTimeout     = annotationPrefix + "service-healthcheck-timeout"
+	annotationLoadBalancerServiceHealthCheckRetries     = annotationPrefix + "service-healthcheck-retries"
+	annotationLoadBalancerRetainOnDelete                = annotationPrefix + "retain-on-delete"
+)
+
+// Labels set on NLB instances managed by the CCM: the UID of the Kubernetes
+// Service owning the NLB instance is recorded upon creation, and the other
+// ones are set upon Service deletion if the NLB instance is retained, to match
+// it against a Service re-created later on in the same cluster (identified by
+// the UID of its kube-system Namespace).
+const (
+	nlbLabelServiceUID       = "k8s-service-uid"
+	nlbLabelServiceNamespace = "k8s-service-namespace"
+	nlbLabelServiceName      = "k8s-service-name"
+	nlbLabelClusterUID       = "k8s-cluster-uid"
+	nlbLabelRetained         = "k8s-retained"
 )
 
 var (
@@ -44,12 +60,28 @@ var (
 	defaultNLBServiceStrategy            v3.LoadBalancerServiceStrategy        = v3.LoadBalancerServiceStrategyRoundRobin
 )
 
+// Reasons of the Kubernetes Events recorded on Services.
+const (
+	eventReasonNLBServiceRolledBack     = "NLBServiceRolledBack"
+	eventReasonNLBServiceRollbackFailed = "NLBServiceRollbackFailed"
+)
+
 var errLoadBalancerNotFound = errors.New("load balancer not found")
 var errLoadBalancerIDAnnotationNotFound = errors.New("load balancer ID annotation not found")
+var errLoadBalancerServiceConflict = errors.New("NLB service port conflict")
+
+// errLoadBalancerServiceNotAdded reports that the creation of an NLB service
+// failed and that it is known not to exist, as opposed to failures occurring
+// once it was created or after which its existence couldn't be checked.
+var errLoadBalancerServiceNotAdded = errors.New("NLB service not added")
 
 type loadBalancer struct {
-	p   *cloudProvider
-	cfg *loadBalancerConfig
+	p *cloudProvider
+}
+
+// config returns the load balancer configuration currently in effect.
+func (l loadBalancer) config() *loadBalancerConfig {
+	return &l.p.config().LoadBalancer
 }
 
 // isExternal returns true if the NLB instance is marked as "external" in the
@@ -58,11 +90,19 @@ func (l loadBalancer) isExternal(service *v1.Service) bool {
 	return strings.ToLower(getAnnotation(service, annotationLoadBalancerExternal, "false")) == "true"
 }
 
-func newLoadBalancer(provider *cloudProvider, config *loadBalancerConfig) cloudprovider.LoadBalancer {
-	return &loadBalancer{
-		p:   provider,
-		cfg: config,
-	}
+// isRetainOnDelete returns true if the NLB instance must be kept (along with
+// its public IP address) when the Kubernetes Service is deleted. The Service
+// manifest annotation takes precedence over the cloud-config default.
+func (l loadBalancer) isRetainOnDelete(service *v1.Service) bool {
+	return strings.ToLower(getAnnotation(
+		service,
+		annotationLoadBalancerRetainOnDelete,
+		strconv.FormatBool(l.config().RetainOnDelete),
+	)) == "true"
+}
+
+func newLoadBalancer(provider *cloudProvider) cloudprovider.LoadBalancer {
+	return &loadBalancer{p: provider}
 }
 
 // GetLoadBalancer returns whether the specified load balancer exists, and
@@ -74,6 +114,8 @@ func (l *loadBalancer) GetLoadBalancer(
 	_ string,
 	service *v1.Service,
 ) (*v1.LoadBalancerStatus, bool, error) {
+	ctx = withAPICaller(ctx, apiCallerService)
+
 	nlb, err := l.fetchLoadBalancer(ctx, service)
 	if err != nil {
 		if err == errLoadBalancerNotFound {
@@ -87,8 +129,8 @@ func (l *loadBalancer) GetLoadBalancer(
 
 // GetLoadBalancerName returns the name of the load balancer. Implementations must treat the
 // *v1.Service parameter as read-only and not modify it.
-func (l *loadBalancer) GetLoadBalancerName(_ context.Context, _ string, service *v1.Service) string {
-	return getAnnotation(service, annotationLoadBalancerName, "k8s-"+string(service.UID))
+func (l *loadBalancer) GetLoadBalancerName(_ context.Context, clusterName string, service *v1.Service) string {
+	return getAnnotation(service, annotationLoadBalancerName, l.config().loadBalancerName(clusterName, service))
 }
 
 // EnsureLoadBalancer creates a new load balancer 'name', or updates the existing one.
@@ -98,10 +140,12 @@ func (l *loadBalancer) GetLoadBalancerName(_ context.Context, _ string, service
 // Parameter 'clusterName' is the name of the cluster as presented to kube-controller-manager
 func (l *loadBalancer) EnsureLoadBalancer(
 	ctx context.Context,
-	_ string,
+	clusterName string,
 	service *v1.Service,
 	nodes []*v1.Node,
 ) (*v1.LoadBalancerStatus, error) {
+	ctx = withAPICaller(ctx, apiCallerService)
+
 	if l.isExternal(service) {
 		lbID := getAnnotation(service, annotationLoadBalancerID, "")
 		lbName := getAnnotation(service, annotationLoadBalancerName, "")
@@ -125,6 +169,10 @@ func (l *loadBalancer) EnsureLoadBalancer(
 				)
 			}
 
+			if err := l.checkLoadBalancerPolicy(service, &nlb); err != nil {
+				return nil, err
+			}
+
 			if err := l.patchAnnotation(ctx, service, annotationLoadBalancerID, nlb.ID.String()); err != nil {
 				return nil, fmt.Errorf("error patching annotations: %w", err)
 			}
@@ -171,56 +219,34 @@ func (l *loadBalancer) EnsureLoadBalancer(
 		}
 	} else if getAnnotation(service, annotationLoadBalancerServiceSKSNodePoolName, "") != "" {
 		return nil, errors.New("SKS node pool name specified without SKS cluster name")
-	} else if getAnnotation(service, annotationLoadBalancerServiceInstancePoolID, "") == "" {
-		// Inferring the Instance Pool ID from the cluster Nodes that run the Service in case no Instance Pool ID
-		// has been specified in the annotations.
-		//
-		// IMPORTANT: this use case is not compatible with Services referencing Pods using Node Selectors
-		// (see https://github.com/kubernetes/kubernetes/issues/45234 for an explanation of the problem).
-		// The list of Nodes passed as argument to this method contains *ALL* the Nodes in the cluster, not only the
-		// ones that actually host the Pods targeted by the Service.
-
-		debugf("no NLB service Instance Pool ID specified in Service annotations, inferring from cluster Nodes")
-
-		var instancePoolID v3.UUID
-		for _, node := range nodes {
-			instance, err := l.p.client.GetInstance(ctx, v3.UUID(node.Status.NodeInfo.SystemUUID))
+	} else {
+		// Inferring the Instance Pool ID from the cluster Nodes hosting the Service endpoints in case no
+		// Instance Pool ID has been specified in the annotations, or re-evaluating a previously inferred one.
+		infer := getAnnotation(service, annotationLoadBalancerServiceInstancePoolID, "") == ""
+		if !infer {
+			reinfer, err := l.shouldReinferInstancePool(ctx, service, nodes)
 			if err != nil {
-				return nil, fmt.Errorf("error retrieving Compute instance information: %s", err)
-			}
-
-			// Standalone Node, leaving it alone.
-			if instance.Manager == nil || instance.Manager.Type != "instance-pool" {
-				continue
-			}
-
-			if instancePoolID != "" && instance.Manager.ID != instancePoolID {
-				return nil, errors.New(
-					"multiple Instance Pools detected across cluster Nodes, " +
-						"an Instance Pool ID must be specified in Service manifest annotations",
-				)
+				return nil, err
 			}
-
-			instancePoolID = instance.Manager.ID
-		}
-
-		if instancePoolID == "" {
-			return nil, errors.New("couldn't infer any Instance Pool from cluster Nodes")
+			infer = reinfer
 		}
-
-		debugf("inferred NLB service Instance Pool ID from cluster Nodes: %s", instancePoolID)
-
-		err := l.patchAnnotation(ctx, service, annotationLoadBalancerServiceInstancePoolID, instancePoolID.String())
-		if err != nil {
-			return nil, fmt.Errorf("error patching annotations: %s", err)
+		if infer {
+			if err := l.inferInstancePool(ctx, service, nodes); err != nil {
+				return nil, err
+			}
 		}
 	}
 
-	lbSpec, err := buildLoadBalancerFromAnnotations(service)
+	lbSpec, err := buildLoadBalancerFromAnnotations(service, l.config(), clusterName)
 	if err != nil {
 		return nil, err
 	}
 
+	// Checking the target Instance Pools prior to creating any NLB instance.
+	if err := l.checkInstancePoolsPolicy(ctx, service, lbSpec); err != nil {
+		return nil, err
+	}
+
 	nlb, err := l.fetchLoadBalancer(ctx, service)
 	if err != nil {
 		if errors.Is(err, errLoadBalancerNotFound) {
@@ -228,33 +254,58 @@ func (l *loadBalancer) EnsureLoadBalancer(
 				return nil, errors.New("NLB instance marked as external in Service annotations, cannot create")
 			}
 
-			infof("creating new NLB %q", lbSpec.Name)
-
-			op, err := l.p.client.CreateLoadBalancer(ctx, v3.CreateLoadBalancerRequest{
-				Name:        lbSpec.Name,
-				Description: lbSpec.Description,
-				Labels:      lbSpec.Labels,
-			})
+			// Prior to creating a new NLB instance, look for an existing one this
+			// Service should use instead: in case the CCM crashed after a previous
+			// creation but before the NLB ID could be recorded in the Service
+			// annotations, blindly creating a new instance would leak the former.
+			existing, retained, err := l.findLoadBalancer(ctx, service)
 			if err != nil {
 				return nil, err
 			}
 
-			nlb, err = l.p.client.GetLoadBalancer(ctx, op.Reference.ID)
-			if err != nil {
-				return nil, err
+			switch {
+			case existing != nil && retained:
+				// The retained NLB instance hasn't been created for this Service.
+				if err := l.checkLoadBalancerPolicy(service, existing); err != nil {
+					return nil, err
+				}
+				if nlb, err = l.adoptLoadBalancer(ctx, service, lbSpec, existing); err != nil {
+					return nil, err
+				}
+
+			case existing != nil:
+				infof("found NLB %q previously created for the Service (ID: %s), reusing it", existing.Name, existing.ID)
+				nlb = existing
+
+			default:
+				infof("creating new NLB %q", lbSpec.Name)
+
+				op, err := l.p.client.CreateLoadBalancer(ctx, v3.CreateLoadBalancerRequest{
+					Name:        lbSpec.Name,
+					Description: lbSpec.Description,
+					Labels:      mergeLabels(lbSpec.Labels, v3.Labels{nlbLabelServiceUID: string(service.UID)}),
+				})
+				if err != nil {
+					return nil, err
+				}
+
+				nlb, err = l.p.client.GetLoadBalancer(ctx, op.Reference.ID)
+				if err != nil {
+					return nil, err
+				}
+
+				debugf("NLB %q created successfully (ID: %s)", nlb.Name, nlb.ID)
 			}
 
 			if err := l.patchAnnotation(ctx, service, annotationLoadBalancerID, nlb.ID.String()); err != nil {
 				return nil, fmt.Errorf("error patching annotations: %s", err)
 			}
-
-			debugf("NLB %q created successfully (ID: %s)", nlb.Name, nlb.ID)
 		} else {
 			return nil, err
 		}
 	}
 
-	if err = l.updateLoadBalancer(ctx, service); err != nil {
+	if err = l.updateLoadBalancer(ctx, clusterName, service); err != nil {
 		return nil, err
 	}
 
@@ -265,8 +316,26 @@ func (l *loadBalancer) EnsureLoadBalancer(
 // Implementations must treat the *v1.Service and *v1.Node
 // parameters as read-only and not modify them.
 // Parameter 'clusterName' is the name of the cluster as presented to kube-controller-manager
-func (l *loadBalancer) UpdateLoadBalancer(ctx context.Context, _ string, service *v1.Service, _ []*v1.Node) error {
-	return l.updateLoadBalancer(ctx, service)
+func (l *loadBalancer) UpdateLoadBalancer(
+	ctx context.Context,
+	clusterName string,
+	service *v1.Service,
+	nodes []*v1.Node,
+) error {
+	ctx = withAPICaller(ctx, apiCallerService)
+
+	// The Nodes hosting the Service endpoints might have moved to a different Instance Pool.
+	reinfer, err := l.shouldReinferInstancePool(ctx, service, nodes)
+	if err != nil {
+		return err
+	}
+	if reinfer {
+		if err := l.inferInstancePool(ctx, service, nodes); err != nil {
+			return err
+		}
+	}
+
+	return l.updateLoadBalancer(ctx, clusterName, service)
 }
 
 // EnsureLoadBalancerDeleted deletes the specified load balancer if it
@@ -277,7 +346,9 @@ func (l *loadBalancer) UpdateLoadBalancer(ctx context.Context, _ string, service
 // doesn't exist even if some part of it is still lying around.
 // Implementations must treat the *v1.Service parameter as read-only and not modify it.
 // Parameter 'clusterName' is the name of the cluster as presented to kube-controller-manager
-func (l *loadBalancer) EnsureLoadBalancerDeleted(ctx context.Context, _ string, service *v1.Service) error {
+func (l *loadBalancer) EnsureLoadBalancerDeleted(ctx context.Context, clusterName string, service *v1.Service) error {
+	ctx = withAPICaller(ctx, apiCallerService)
+
 	nlb, err := l.fetchLoadBalancer(ctx, service)
 	if err != nil {
 		if errors.Is(err, errLoadBalancerNotFound) {
@@ -287,15 +358,43 @@ func (l *loadBalancer) EnsureLoadBalancerDeleted(ctx context.Context, _ string,
 		return err
 	}
 
+	// The Service must not be able to delete NLB services of an NLB instance
+	// it isn't allowed to use (e.g. by pointing its annotations at another
+	// team's NLB instance before being deleted): only the NLB services named
+	// after its UID, necessarily created for it while it was allowed to use
+	// the NLB instance, are deleted. The deletion fails (keeping the Service
+	// finalizer) as long as other NLB services it would own are left.
+	rejected := l.checkLoadBalancerPolicy(service, nlb)
+	var leftover bool
+
+	// The NLB services names are only needed to determine the ownership of
+	// NLB services named after the Service annotations: invalid annotations
+	// must not prevent the deletion of the other ones.
+	lbSpec, err := buildLoadBalancerFromAnnotations(service, l.config(), clusterName)
+	if err != nil {
+		debugf("unable to build NLB spec from Service annotations: %v", err)
+		lbSpec = nil
+	}
+
 	// Since a NLB instance can be shared among unrelated k8s Services,
-	// as a safety precaution we delete the NLB services matching this k8s
-	// Service's ports individually rather than the whole NLB instance directly.
-	// If at the end of the process there are no unrelated NLB services
-	// remaining, we can safely delete the NLB instance.
+	// as a safety precaution we delete the NLB services owned by this k8s
+	// Service and matching its ports individually rather than the whole NLB
+	// instance directly. If at the end of the process there are no unrelated
+	// NLB services remaining, we can safely delete the NLB instance.
 	remainingServices := len(nlb.Services)
 	for _, nlbService := range nlb.Services {
+		if !ownsLoadBalancerService(service, nlb, nlbService, lbSpec) {
+			debugf("NLB service %s/%s doesn't belong to this Service, leaving it alone", nlb.Name, nlbService.Name)
+			continue
+		}
+
 		for _, servicePort := range service.Spec.Ports {
 			if nlbService.Port == int64(servicePort.Port) && strings.EqualFold(string(nlbService.Protocol), string(servicePort.Protocol)) {
+				if rejected != nil && !strings.HasPrefix(nlbService.Name, string(service.UID)+"-") {
+					leftover = true
+					continue
+				}
+
 				infof("deleting NLB service %s/%s", nlb.Name, nlbService.Name)
 				_, err := l.p.client.DeleteLoadBalancerService(ctx, nlb.ID, nlbService.ID)
 				if err != nil {
@@ -307,12 +406,23 @@ func (l *loadBalancer) EnsureLoadBalancerDeleted(ctx context.Context, _ string,
 		}
 	}
 
+	if rejected != nil {
+		if leftover {
+			return fmt.Errorf("not deleting NLB %s services: %w", nlb.ID, rejected)
+		}
+		return nil
+	}
+
 	if remainingServices == 0 {
 		if l.isExternal(service) {
 			debugf("NLB instance marked as external in Service annotations, skipping delete")
 			return nil
 		}
 
+		if l.isRetainOnDelete(service) {
+			return l.retainLoadBalancer(ctx, service, nlb)
+		}
+
 		infof("deleting NLB %q", nlb.Name)
 
 		_, err := l.p.client.DeleteLoadBalancer(ctx, nlb.ID)
@@ -323,8 +433,8 @@ func (l *loadBalancer) EnsureLoadBalancerDeleted(ctx context.Context, _ string,
 }
 
 // updateLoadBalancer updates the matching Exoscale NLB instance according to the *v1.Service spec provided.
-func (l *loadBalancer) updateLoadBalancer(ctx context.Context, service *v1.Service) error {
-	nlbUpdate, err := buildLoadBalancerFromAnnotations(service)
+func (l *loadBalancer) updateLoadBalancer(ctx context.Context, clusterName string, service *v1.Service) error {
+	nlbUpdate, err := buildLoadBalancerFromAnnotations(service, l.config(), clusterName)
 	if err != nil {
 		return err
 	}
@@ -338,14 +448,30 @@ func (l *loadBalancer) updateLoadBalancer(ctx context.Context, service *v1.Servi
 		return err
 	}
 
-	// If this NLB is not marked as external and top-level fields changed, update them.
-	if !l.isExternal(service) && isLoadBalancerUpdated(nlbCurrent, nlbUpdate) {
+	if err := l.checkLoadBalancerPolicy(service, nlbCurrent); err != nil {
+		return err
+	}
+	if err := l.checkInstancePoolsPolicy(ctx, service, nlbUpdate); err != nil {
+		return err
+	}
+
+	// NLB instances created before the ownership label was set are only
+	// recognized by their default name: label them, so that they remain
+	// owned by the Service once renamed.
+	if isLoadBalancerOwned(service, nlbCurrent) && nlbCurrent.Labels[nlbLabelServiceUID] == "" {
+		nlbUpdate.Labels = mergeLabels(nlbUpdate.Labels, v3.Labels{nlbLabelServiceUID: string(service.UID)})
+	}
+
+	// If this NLB is not marked as external, doesn't belong to another Service
+	// and top-level fields changed, update them.
+	if !l.isExternal(service) && !isLoadBalancerOwnedByOther(service, nlbCurrent) &&
+		isLoadBalancerUpdated(nlbCurrent, nlbUpdate) {
 		infof("updating NLB %q", nlbCurrent.Name)
 
 		if _, err = l.p.client.UpdateLoadBalancer(ctx, nlbUpdate.ID, v3.UpdateLoadBalancerRequest{
 			Name:        nlbUpdate.Name,
-			Description: nlbCurrent.Description,
-			Labels:      nlbUpdate.Labels,
+			Description: nlbUpdate.Description,
+			Labels:      mergeLabels(nlbCurrent.Labels, nlbUpdate.Labels),
 		}); err != nil {
 			return err
 		}
@@ -353,7 +479,8 @@ func (l *loadBalancer) updateLoadBalancer(ctx context.Context, service *v1.Servi
 		debugf("NLB %q updated successfully", nlbCurrent.Name)
 	}
 
-	// First loop: delete any old NLB services whose port/protocol no longer exist in the updated spec.
+	// First loop: delete any old NLB services owned by this Service whose port/protocol no longer exist
+	// in the updated spec, and detect port/protocol conflicts with NLB services owned by other Services.
 	// Info: There is a long standing bug in kubectl where patching a Service towards
 	// the same port tcp/udp and possible even other properties doesn't trigger
 	// It needs then a server side apply or replace
@@ -368,17 +495,33 @@ func (l *loadBalancer) updateLoadBalancer(ctx context.Context, service *v1.Servi
 	// We'll collect existing services that still match a port/protocol into this map
 	nlbServices := make(map[ServiceKey]v3.LoadBalancerService)
 
+	// Stale services are only deleted once we know there is no conflict.
+	var nlbServicesStale []v3.LoadBalancerService
+
 next:
 	for _, nlbServiceCurrent := range nlbCurrent.Services {
 		key := ServiceKey{Port: nlbServiceCurrent.Port, Protocol: nlbServiceCurrent.Protocol}
-		debugf("Checking existing NLB service %s/%s - key %v",
-			nlbCurrent.Name, nlbServiceCurrent.Name, key)
+		owned := ownsLoadBalancerService(service, nlbCurrent, nlbServiceCurrent, nlbUpdate)
+		debugf("Checking existing NLB service %s/%s - key %v (owned: %t)",
+			nlbCurrent.Name, nlbServiceCurrent.Name, key, owned)
 
 		// See if there's a matching port/protocol in nlbUpdate
 		for _, nlbServiceUpdate := range nlbUpdate.Services {
 			updateKey := ServiceKey{Port: nlbServiceUpdate.Port, Protocol: nlbServiceUpdate.Protocol}
 
 			if key == updateKey {
+				if !owned {
+					return fmt.Errorf(
+						"%w: port %d/%s of NLB %q is already used by NLB service %q, "+
+							"which doesn't belong to this Service",
+						errLoadBalancerServiceConflict,
+						key.Port,
+						key.Protocol,
+						nlbCurrent.Name,
+						nlbServiceCurrent.Name,
+					)
+				}
+
 				// Keep it around for the second loop (updates)
 				debugf("Match found for existing service %s/%s with updated service %s/%s",
 					nlbCurrent.Name, nlbServiceCurrent.Name, nlbUpdate.Name, nlbServiceUpdate.Name)
@@ -389,29 +532,33 @@ next:
 		}
 
 		// If we got here, this existing NLB service doesn't match any desired port/protocol.
-		if l.isExternal(service) {
+		if !owned {
 			debugf(
-				"NLB service %s/%s doesn't match any service port, but the NLB is marked external. "+
-					"Avoiding deletion since it may belong to another Service.",
+				"NLB service %s/%s doesn't match any service port, but doesn't belong to this Service. "+
+					"Avoiding deletion since it belongs to another Service.",
 				nlbCurrent.Name,
 				nlbServiceCurrent.Name,
 			)
 			continue
 		}
 
+		nlbServicesStale = append(nlbServicesStale, nlbServiceCurrent)
+	}
+
+	for _, nlbServiceStale := range nlbServicesStale {
 		infof("NLB service %s/%s doesn't match any service port, deleting",
 			nlbCurrent.Name,
-			nlbServiceCurrent.Name)
+			nlbServiceStale.Name)
 
 		if _, err := l.p.client.DeleteLoadBalancerService(
 			ctx,
 			nlbCurrent.ID,
-			nlbServiceCurrent.ID,
+			nlbServiceStale.ID,
 		); err != nil {
 			return err
 		}
 
-		debugf("NLB service %s/%s deleted successfully", nlbCurrent.Name, nlbServiceCurrent.Name)
+		debugf("NLB service %s/%s deleted successfully", nlbCurrent.Name, nlbServiceStale.Name)
 	}
 
 	// Second loop: for each desired service, either update the existing one or create a new one.
@@ -427,48 +574,10 @@ next:
 			// No existing one, so create brand new
 			infof("creating new NLB service %s/%s", nlbCurrent.Name, nlbServiceUpdate.Name)
 
-			_, err = l.p.client.AddServiceToLoadBalancer(ctx, nlbCurrent.ID, v3.AddServiceToLoadBalancerRequest{
-				Name:        nlbServiceUpdate.Name,
-				Description: nlbServiceUpdate.Description,
-				Port:        nlbServiceUpdate.Port,
-				TargetPort:  nlbServiceUpdate.TargetPort,
-				Protocol:    v3.AddServiceToLoadBalancerRequestProtocol(nlbServiceUpdate.Protocol),
-				Strategy:    v3.AddServiceToLoadBalancerRequestStrategy(nlbServiceUpdate.Strategy),
-				Healthcheck: nlbServiceUpdate.Healthcheck,
-				InstancePool: &v3.InstancePool{
-					ID: nlbServiceUpdate.InstancePool.ID,
-				},
-			})
-			if err != nil {
+			if err := l.addLoadBalancerService(ctx, nlbCurrent, nlbServiceUpdate); err != nil {
 				return err
 			}
 
-			// Operation returns load balancer (not service) reference.
-			// We now need to look for newly created service.
-			nlb, err := l.p.client.GetLoadBalancer(ctx, nlbCurrent.ID)
-			if err != nil {
-				return err
-			}
-
-			svc := v3.LoadBalancerService{}
-			for _, item := range nlb.Services {
-				if item.Name == nlbServiceUpdate.Name {
-					svc = item
-				}
-			}
-			if svc.Name == "" {
-				return fmt.Errorf(
-					"failed to create NLB service %s/%s",
-					nlbCurrent.Name,
-					nlbServiceUpdate.Name,
-				)
-			}
-
-			debugf("NLB service %s/%s created successfully (ID: %s)",
-				nlbCurrent.Name,
-				nlbServiceUpdate.Name,
-				svc.ID,
-			)
 			continue
 		}
 
@@ -503,49 +612,17 @@ next:
 			}
 			debugf("NLB service %s/%s deleted successfully", nlbCurrent.Name, nlbServiceCurrent.Name)
 
-			// 2. Create fresh
-			_, err = l.p.client.AddServiceToLoadBalancer(ctx, nlbCurrent.ID, v3.AddServiceToLoadBalancerRequest{
-				Name:        nlbServiceUpdate.Name,
-				Description: nlbServiceUpdate.Description,
-				Port:        nlbServiceUpdate.Port,
-				TargetPort:  nlbServiceUpdate.TargetPort,
-				Protocol:    v3.AddServiceToLoadBalancerRequestProtocol(nlbServiceUpdate.Protocol),
-				Strategy:    v3.AddServiceToLoadBalancerRequestStrategy(nlbServiceUpdate.Strategy),
-				Healthcheck: nlbServiceUpdate.Healthcheck,
-				InstancePool: &v3.InstancePool{
-					ID: nlbServiceUpdate.InstancePool.ID,
-				},
-			})
-			if err != nil {
-				return err
-			}
-
-			// Operation returns load balancer (not service) reference.
-			// We now need to look for newly created service.
-			nlb, err := l.p.client.GetLoadBalancer(ctx, nlbCurrent.ID)
-			if err != nil {
-				return err
-			}
-
-			svc := v3.LoadBalancerService{}
-			for _, item := range nlb.Services {
-				if item.Name == nlbServiceUpdate.Name {
-					svc = item
+			// 2. Create fresh, restoring the previous definition if the new NLB
+			// service doesn't exist so that a bad target change never leaves the
+			// Service port without listener. Failures occurring once it has been
+			// created (i.e. the port is taken) are simply reported.
+			if err := l.addLoadBalancerService(ctx, nlbCurrent, nlbServiceUpdate); err != nil {
+				if errors.Is(err, errLoadBalancerServiceNotAdded) {
+					return l.rollbackLoadBalancerService(ctx, service, nlbCurrent, nlbServiceCurrent, err)
 				}
-			}
-			if svc.Name == "" {
-				return fmt.Errorf(
-					"failed to create NLB service %s/%s",
-					nlbCurrent.Name,
-					nlbServiceUpdate.Name,
-				)
+				return fmt.Errorf("failed recreating NLB service: %w", err)
 			}
 
-			debugf("NLB service %s/%s created successfully (ID: %s)",
-				nlbCurrent.Name,
-				nlbServiceUpdate.Name,
-				svc.ID)
-
 			continue
 		}
 
@@ -578,6 +655,114 @@ next:
 	return nil
 }
 
+// addLoadBalancerService creates the NLB service svc on the NLB instance nlb,
+// and ensures it has actually been created. Failures after which the NLB
+// service doesn't exist are reported as errLoadBalancerServiceNotAdded.
+func (l *loadBalancer) addLoadBalancerService(ctx context.Context, nlb *v3.LoadBalancer, svc v3.LoadBalancerService) error {
+	req := v3.AddServiceToLoadBalancerRequest{
+		Name:        svc.Name,
+		Description: svc.Description,
+		Port:        svc.Port,
+		TargetPort:  svc.TargetPort,
+		Protocol:    v3.AddServiceToLoadBalancerRequestProtocol(svc.Protocol),
+		Strategy:    v3.AddServiceToLoadBalancerRequestStrategy(svc.Strategy),
+		Healthcheck: svc.Healthcheck,
+	}
+	if svc.InstancePool != nil {
+		req.InstancePool = &v3.InstancePool{
+			ID: svc.InstancePool.ID,
+		}
+	}
+
+	if _, err := l.p.client.AddServiceToLoadBalancer(ctx, nlb.ID, req); err != nil {
+		return l.loadBalancerServiceNotAdded(ctx, nlb, svc.Name, err)
+	}
+
+	// Operation returns load balancer (not service) reference.
+	// We now need to look for newly created service.
+	created, err := l.getLoadBalancerService(ctx, nlb, svc.Name)
+	if err != nil {
+		return l.loadBalancerServiceNotAdded(ctx, nlb, svc.Name, err)
+	}
+	if created == nil {
+		return fmt.Errorf("%w: failed to create NLB service %s/%s", errLoadBalancerServiceNotAdded, nlb.Name, svc.Name)
+	}
+
+	debugf("NLB service %s/%s created successfully (ID: %s)", nlb.Name, svc.Name, created.ID)
+
+	return nil
+}
+
+// loadBalancerServiceNotAdded checks whether the NLB service name exists on
+// the NLB instance nlb after its creation failed with error cause (e.g. the
+// operation timed out, or the NLB instance couldn't be read back), and wraps
+// cause as errLoadBalancerServiceNotAdded if it doesn't.
+func (l *loadBalancer) loadBalancerServiceNotAdded(ctx context.Context, nlb *v3.LoadBalancer, name string, cause error) error {
+	existing, err := l.getLoadBalancerService(ctx, nlb, name)
+	if err != nil {
+		return fmt.Errorf("%w (checking whether NLB service %s/%s exists failed: %v)", cause, nlb.Name, name, err)
+	}
+	if existing != nil {
+		return cause
+	}
+
+	return fmt.Errorf("%w: %w", errLoadBalancerServiceNotAdded, cause)
+}
+
+// getLoadBalancerService returns the NLB service name of the NLB instance
+// nlb as currently defined, or nil if it doesn't exist.
+func (l *loadBalancer) getLoadBalancerService(ctx context.Context, nlb *v3.LoadBalancer, name string) (*v3.LoadBalancerService, error) {
+	nlbCurrent, err := l.p.client.GetLoadBalancer(ctx, nlb.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, item := range nlbCurrent.Services {
+		if item.Name == name {
+			return &item, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// rollbackLoadBalancerService re-creates the previous definition of an NLB
+// service deleted in order to be re-created with a different target, after
+// the re-creation was rejected with error cause.
+func (l *loadBalancer) rollbackLoadBalancerService(
+	ctx context.Context,
+	service *v1.Service,
+	nlb *v3.LoadBalancer,
+	previous v3.LoadBalancerService,
+	cause error,
+) error {
+	errorf("failed to recreate NLB service %s/%s, restoring previous definition: %v", nlb.Name, previous.Name, cause)
+
+	if err := l.addLoadBalancerService(ctx, nlb, previous); err != nil {
+		l.p.warningEventf(
+			service,
+			eventReasonNLBServiceRollbackFailed,
+			"Failed to restore NLB service %s/%s after its re-creation failed (%v): %v",
+			nlb.Name,
+			previous.Name,
+			cause,
+			err,
+		)
+		return fmt.Errorf("failed recreating NLB service: %w (restoring previous definition failed: %v)", cause, err)
+	}
+
+	l.p.warningEventf(
+		service,
+		eventReasonNLBServiceRolledBack,
+		"Failed to recreate NLB service %s/%s, previous definition restored: %v",
+		nlb.Name,
+		previous.Name,
+		cause,
+	)
+
+	return fmt.Errorf("failed recreating NLB service (previous definition restored): %w", cause)
+}
+
 func (l *loadBalancer) fetchLoadBalancer(
 	ctx context.Context,
 	service *v1.Service,
@@ -598,19 +783,143 @@ func (l *loadBalancer) fetchLoadBalancer(
 	return nil, errLoadBalancerNotFound
 }
 
+// retainLoadBalancer labels the NLB instance as retained instead of deleting
+// it, so that a Kubernetes Service re-created later with the same
+// namespace/name adopts it and keeps its public IP address.
+func (l *loadBalancer) retainLoadBalancer(ctx context.Context, service *v1.Service, nlb *v3.LoadBalancer) error {
+	clusterUID, err := l.clusterUID(ctx)
+	if err != nil {
+		return err
+	}
+
+	labels := make(v3.Labels, len(nlb.Labels)+4)
+	for k, v := range nlb.Labels {
+		labels[k] = v
+	}
+	labels[nlbLabelServiceNamespace] = service.Namespace
+	labels[nlbLabelServiceName] = service.Name
+	labels[nlbLabelClusterUID] = clusterUID
+	labels[nlbLabelRetained] = "true"
+
+	infof("retaining NLB %q (IP: %s)", nlb.Name, nlb.IP)
+
+	_, err = l.p.client.UpdateLoadBalancer(ctx, nlb.ID, v3.UpdateLoadBalancerRequest{Labels: labels})
+	return err
+}
+
+// clusterUID returns the UID of the kube-system Namespace, identifying the
+// Kubernetes cluster among the ones sharing the Exoscale organization.
+func (l *loadBalancer) clusterUID(ctx context.Context) (string, error) {
+	namespace, err := l.p.kclient.CoreV1().Namespaces().Get(ctx, metav1.NamespaceSystem, metav1.GetOptions{})
+	if err != nil {
+		return "", fmt.Errorf("error retrieving the cluster identity: %w", err)
+	}
+
+	return string(namespace.UID), nil
+}
+
+// findLoadBalancer looks up an existing NLB instance the Kubernetes Service
+// should use in lieu of creating a new one, which is either an NLB instance
+// previously created for this very Service (matched by its ownership label,
+// or by its default name for NLB instances created before labels were set),
+// or an NLB instance retained upon deletion of a former Service of the same
+// cluster having the same namespace/name, in which case retained is true.
+func (l *loadBalancer) findLoadBalancer(
+	ctx context.Context,
+	service *v1.Service,
+) (nlb *v3.LoadBalancer, retained bool, err error) {
+	nlbs, err := l.p.client.ListLoadBalancers(ctx)
+	if err != nil {
+		return nil, false, fmt.Errorf("error listing NLBs: %w", err)
+	}
+
+	for _, item := range nlbs.LoadBalancers {
+		if isLoadBalancerOwned(service, &item) {
+			return &item, false, nil
+		}
+	}
+
+	var clusterUID string
+	for _, item := range nlbs.LoadBalancers {
+		if item.Labels[nlbLabelRetained] != "true" ||
+			item.Labels[nlbLabelServiceNamespace] != service.Namespace ||
+			item.Labels[nlbLabelServiceName] != service.Name {
+			continue
+		}
+
+		// Services of other clusters may have the same namespace/name.
+		if clusterUID == "" {
+			if clusterUID, err = l.clusterUID(ctx); err != nil {
+				return nil, false, err
+			}
+		}
+		if item.Labels[nlbLabelClusterUID] == clusterUID {
+			return &item, true, nil
+		}
+	}
+
+	return nil, false, nil
+}
+
+// adoptLoadBalancer takes over a retained NLB instance on behalf of the
+// Kubernetes Service, clearing its retained label and recording the new owner.
+func (l *loadBalancer) adoptLoadBalancer(
+	ctx context.Context,
+	service *v1.Service,
+	lbSpec *v3.LoadBalancer,
+	nlb *v3.LoadBalancer,
+) (*v3.LoadBalancer, error) {
+	labels := make(v3.Labels, len(nlb.Labels))
+	for k, v := range nlb.Labels {
+		if k != nlbLabelRetained {
+			labels[k] = v
+		}
+	}
+	labels[nlbLabelServiceUID] = string(service.UID)
+
+	infof("adopting retained NLB %q (IP: %s) for Service %s/%s", nlb.Name, nlb.IP, service.Namespace, service.Name)
+
+	if _, err := l.p.client.UpdateLoadBalancer(ctx, nlb.ID, v3.UpdateLoadBalancerRequest{
+		Name:        lbSpec.Name,
+		Description: lbSpec.Description,
+		Labels:      labels,
+	}); err != nil {
+		return nil, fmt.Errorf("error adopting retained NLB: %w", err)
+	}
+
+	nlb.Name = lbSpec.Name
+	nlb.Description = lbSpec.Description
+	nlb.Labels = labels
+
+	return nlb, nil
+}
+
 func (l *loadBalancer) patchAnnotation(ctx context.Context, service *v1.Service, k, v string) error {
+	return l.patchAnnotations(ctx, service, map[string]string{k: v})
+}
+
+// patchAnnotations sets the Service annotations specified in a single patch
+// request, so that they are never observed partially applied.
+func (l *loadBalancer) patchAnnotations(ctx context.Context, service *v1.Service, annotations map[string]string) error {
 	patcher := newServicePatcher(ctx, l.p.kclient, service)
 
 	if service.Annotations == nil {
 		service.Annotations = map[string]string{}
 	}
 
-	if cur, ok := service.Annotations[k]; ok && cur == v {
+	var changed bool
+	for k, v := range annotations {
+		if cur, ok := service.Annotations[k]; ok && cur == v {
+			continue
+		}
+
+		service.Annotations[k] = v
+		changed = true
+	}
+	if !changed {
 		return nil
 	}
 
-	service.Annotations[k] = v
-
 	return patcher.Patch()
 }
 
@@ -618,18 +927,18 @@ func (c *refreshableExoscaleClient) CreateLoadBalancer(
 	ctx context.Context,
 	req v3.CreateLoadBalancerRequest,
 ) (*v3.Operation, error) {
-	c.RLock()
-	defer c.RUnlock()
+	callCtx, cancel := c.withTimeout(ctx, "CreateLoadBalancer")
+	defer cancel()
 
-	op, err := c.exo.CreateLoadBalancer(
-		ctx,
+	op, err := c.client().CreateLoadBalancer(
+		callCtx,
 		req,
 	)
 	if err != nil {
 		return nil, err
 	}
 
-	return c.exo.Wait(ctx, op, v3.OperationStateSuccess)
+	return c.Wait(ctx, op, v3.OperationStateSuccess)
 }
 
 func (c *refreshableExoscaleClient) AddServiceToLoadBalancer(
@@ -637,11 +946,11 @@ func (c *refreshableExoscaleClient) AddServiceToLoadBalancer(
 	id v3.UUID,
 	req v3.AddServiceToLoadBalancerRequest,
 ) (*v3.Operation, error) {
-	c.RLock()
-	defer c.RUnlock()
+	callCtx, cancel := c.withTimeout(ctx, "AddServiceToLoadBalancer")
+	defer cancel()
 
-	op, err := c.exo.AddServiceToLoadBalancer(
-		ctx,
+	op, err := c.client().AddServiceToLoadBalancer(
+		callCtx,
 		id,
 		req,
 	)
@@ -649,25 +958,25 @@ func (c *refreshableExoscaleClient) AddServiceToLoadBalancer(
 		return nil, err
 	}
 
-	return c.exo.Wait(ctx, op, v3.OperationStateSuccess)
+	return c.Wait(ctx, op, v3.OperationStateSuccess)
 }
 
 func (c *refreshableExoscaleClient) DeleteLoadBalancer(
 	ctx context.Context,
 	id v3.UUID,
 ) (*v3.Operation, error) {
-	c.RLock()
-	defer c.RUnlock()
+	callCtx, cancel := c.withTimeout(ctx, "DeleteLoadBalancer")
+	defer cancel()
 
-	op, err := c.exo.DeleteLoadBalancer(
-		ctx,
+	op, err := c.client().DeleteLoadBalancer(
+		callCtx,
 		id,
 	)
 	if err != nil {
 		return nil, err
 	}
 
-	return c.exo.Wait(ctx, op, v3.OperationStateSuccess)
+	return c.Wait(ctx, op, v3.OperationStateSuccess)
 }
 
 func (c *refreshableExoscaleClient) DeleteLoadBalancerService(
@@ -675,11 +984,11 @@ func (c *refreshableExoscaleClient) DeleteLoadBalancerService(
 	id v3.UUID,
 	serviceID v3.UUID,
 ) (*v3.Operation, error) {
-	c.RLock()
-	defer c.RUnlock()
+	callCtx, cancel := c.withTimeout(ctx, "DeleteLoadBalancerService")
+	defer cancel()
 
-	op, err := c.exo.DeleteLoadBalancerService(
-		ctx,
+	op, err := c.client().DeleteLoadBalancerService(
+		callCtx,
 		id,
 		serviceID,
 	)
@@ -687,17 +996,17 @@ func (c *refreshableExoscaleClient) DeleteLoadBalancerService(
 		return nil, err
 	}
 
-	return c.exo.Wait(ctx, op, v3.OperationStateSuccess)
+	return c.Wait(ctx, op, v3.OperationStateSuccess)
 }
 
 func (c *refreshableExoscaleClient) GetLoadBalancer(
 	ctx context.Context,
 	id v3.UUID,
 ) (*v3.LoadBalancer, error) {
-	c.RLock()
-	defer c.RUnlock()
+	ctx, cancel := c.withTimeout(ctx, "GetLoadBalancer")
+	defer cancel()
 
-	return c.exo.GetLoadBalancer(
+	return c.client().GetLoadBalancer(
 		ctx,
 		id,
 	)
@@ -706,10 +1015,10 @@ func (c *refreshableExoscaleClient) GetLoadBalancer(
 func (c *refreshableExoscaleClient) ListLoadBalancers(
 	ctx context.Context,
 ) (*v3.ListLoadBalancersResponse, error) {
-	c.RLock()
-	defer c.RUnlock()
+	ctx, cancel := c.withTimeout(ctx, "ListLoadBalancers")
+	defer cancel()
 
-	return c.exo.ListLoadBalancers(
+	return c.client().ListLoadBalancers(
 		ctx,
 	)
 }
@@ -719,11 +1028,11 @@ func (c *refreshableExoscaleClient) UpdateLoadBalancer(
 	id v3.UUID,
 	req v3.UpdateLoadBalancerRequest,
 ) (*v3.Operation, error) {
-	c.RLock()
-	defer c.RUnlock()
+	callCtx, cancel := c.withTimeout(ctx, "UpdateLoadBalancer")
+	defer cancel()
 
-	op, err := c.exo.UpdateLoadBalancer(
-		ctx,
+	op, err := c.client().UpdateLoadBalancer(
+		callCtx,
 		id,
 		req,
 	)
@@ -731,7 +1040,7 @@ func (c *refreshableExoscaleClient) UpdateLoadBalancer(
 		return nil, err
 	}
 
-	return c.exo.Wait(ctx, op, v3.OperationStateSuccess)
+	return c.Wait(ctx, op, v3.OperationStateSuccess)
 }
 
 func (c *refreshableExoscaleClient) UpdateLoadBalancerService(
@@ -740,11 +1049,11 @@ func (c *refreshableExoscaleClient) UpdateLoadBalancerService(
 	serviceID v3.UUID,
 	req v3.UpdateLoadBalancerServiceRequest,
 ) (*v3.Operation, error) {
-	c.RLock()
-	defer c.RUnlock()
+	callCtx, cancel := c.withTimeout(ctx, "UpdateLoadBalancerService")
+	defer cancel()
 
-	op, err := c.exo.UpdateLoadBalancerService(
-		ctx,
+	op, err := c.client().UpdateLoadBalancerService(
+		callCtx,
 		id,
 		serviceID,
 		req,
@@ -753,7 +1062,7 @@ func (c *refreshableExoscaleClient) UpdateLoadBalancerService(
 		return nil, err
 	}
 
-	return c.exo.Wait(ctx, op, v3.OperationStateSuccess)
+	return c.Wait(ctx, op, v3.OperationStateSuccess)
 }
 
 func getAnnotation(service *v1.Service, annotation, defaultValue string) string {
@@ -769,18 +1078,31 @@ func getAnnotation(service *v1.Service, annotation, defaultValue string) string
 	return ""
 }
 
-func buildLoadBalancerFromAnnotations(service *v1.Service) (*v3.LoadBalancer, error) {
+// buildLoadBalancerFromAnnotations returns the NLB instance specified by the
+// Service annotations, falling back to the cloud-config defaults.
+func buildLoadBalancerFromAnnotations(
+	service *v1.Service,
+	config *loadBalancerConfig,
+	clusterName string,
+) (*v3.LoadBalancer, error) {
+	defaults := config.withDefaults()
+
 	lb := v3.LoadBalancer{
-		ID:          v3.UUID(getAnnotation(service, annotationLoadBalancerID, "")),
-		Name:        getAnnotation(service, annotationLoadBalancerName, "k8s-"+string(service.UID)),
-		Description: getAnnotation(service, annotationLoadBalancerDescription, ""),
-		Services:    make([]v3.LoadBalancerService, 0),
+		ID:   v3.UUID(getAnnotation(service, annotationLoadBalancerID, "")),
+		Name: getAnnotation(service, annotationLoadBalancerName, defaults.loadBalancerName(clusterName, service)),
+		Description: getAnnotation(
+			service,
+			annotationLoadBalancerDescription,
+			defaults.loadBalancerDescription(clusterName, service),
+		),
+		Labels:   defaults.Labels,
+		Services: make([]v3.LoadBalancerService, 0),
 	}
 
 	hcInterval, err := time.ParseDuration(getAnnotation(
 		service,
 		annotationLoadBalancerServiceHealthCheckInterval,
-		defaultNLBServiceHealthcheckInterval,
+		defaults.HealthCheck.Interval.String(),
 	))
 	if err != nil {
 		return nil, err
@@ -789,7 +1111,7 @@ func buildLoadBalancerFromAnnotations(service *v1.Service) (*v3.LoadBalancer, er
 	hcTimeout, err := time.ParseDuration(getAnnotation(
 		service,
 		annotationLoadBalancerServiceHealthCheckTimeout,
-		defaultNLBServiceHealthCheckTimeout,
+		defaults.HealthCheck.Timeout.String(),
 	))
 	if err != nil {
 		return nil, err
@@ -798,7 +1120,7 @@ func buildLoadBalancerFromAnnotations(service *v1.Service) (*v3.LoadBalancer, er
 	hcRetriesI, err := strconv.Atoi(getAnnotation(
 		service,
 		annotationLoadBalancerServiceHealthCheckRetries,
-		fmt.Sprint(defaultNLBServiceHealthcheckRetries),
+		fmt.Sprint(defaults.HealthCheck.Retries),
 	))
 	if err != nil {
 		return nil, err
@@ -858,7 +1180,7 @@ func buildLoadBalancerFromAnnotations(service *v1.Service) (*v3.LoadBalancer, er
 				Mode: v3.LoadBalancerServiceHealthcheckMode(getAnnotation(
 					service,
 					annotationLoadBalancerServiceHealthCheckMode,
-					string(defaultNLBServiceHealthcheckMode),
+					defaults.HealthCheck.Mode,
 				)),
 				Port:     int64(hcPort),
 				URI:      getAnnotation(service, annotationLoadBalancerServiceHealthCheckURI, ""),
@@ -875,7 +1197,7 @@ func buildLoadBalancerFromAnnotations(service *v1.Service) (*v3.LoadBalancer, er
 			Strategy: v3.LoadBalancerServiceStrategy(getAnnotation(
 				service,
 				annotationLoadBalancerServiceStrategy,
-				string(defaultNLBServiceStrategy),
+				defaults.Strategy,
 			)),
 			TargetPort: svcTargetPort,
 		}
@@ -894,6 +1216,54 @@ func buildLoadBalancerFromAnnotations(service *v1.Service) (*v3.LoadBalancer, er
 	return &lb, nil
 }
 
+// isLoadBalancerOwned returns true if the NLB instance has been created for
+// the Kubernetes Service, as recorded by its ownership label or, for NLB
+// instances created before labels were set, by its default name.
+func isLoadBalancerOwned(service *v1.Service, nlb *v3.LoadBalancer) bool {
+	return nlb.Labels[nlbLabelServiceUID] == string(service.UID) || nlb.Name == "k8s-"+string(service.UID)
+}
+
+// isLoadBalancerOwnedByOther returns true if the NLB instance has been created
+// for another Kubernetes Service than the one specified.
+func isLoadBalancerOwnedByOther(service *v1.Service, nlb *v3.LoadBalancer) bool {
+	owner, ok := nlb.Labels[nlbLabelServiceUID]
+	return ok && owner != string(service.UID)
+}
+
+// ownsLoadBalancerService returns true if the NLB service belongs to the
+// Kubernetes Service, which is the case if its name is prefixed by the Service
+// UID (default naming) or matches a name set from the Service annotations
+// (lbSpec, which may be nil). NLB services named otherwise (e.g. manually
+// renamed) are considered owned only if they are hosted by an NLB instance
+// created for this Service and their name isn't prefixed by another UID.
+func ownsLoadBalancerService(
+	service *v1.Service,
+	nlb *v3.LoadBalancer,
+	nlbService v3.LoadBalancerService,
+	lbSpec *v3.LoadBalancer,
+) bool {
+	if strings.HasPrefix(nlbService.Name, string(service.UID)+"-") {
+		return true
+	}
+
+	if lbSpec != nil {
+		for _, svc := range lbSpec.Services {
+			if svc.Name == nlbService.Name {
+				return true
+			}
+		}
+	}
+
+	// NLB services named after another Service UID belong to that Service.
+	if len(nlbService.Name) > 36 && nlbService.Name[36] == '-' {
+		if _, err := v3.ParseUUID(nlbService.Name[:36]); err == nil {
+			return false
+		}
+	}
+
+	return isLoadBalancerOwned(service, nlb)
+}
+
 func isLoadBalancerUpdated(current, update *v3.LoadBalancer) bool {
 	if current.Name != update.Name {
 		return true
@@ -903,9 +1273,34 @@ func isLoadBalancerUpdated(current, update *v3.LoadBalancer) bool {
 		return true
 	}
 
+	for k, v := range update.Labels {
+		if current.Labels[k] != v {
+			return true
+		}
+	}
+
 	return false
 }
 
+// mergeLabels returns the labels of base overridden by the ones of override,
+// or nil if there are no labels to override (i.e. leaving them unchanged upon
+// update).
+func mergeLabels(base, override v3.Labels) v3.Labels {
+	if len(override) == 0 {
+		return nil
+	}
+
+	labels := make(v3.Labels, len(base)+len(override))
+	for k, v := range base {
+		labels[k] = v
+	}
+	for k, v := range override {
+		labels[k] = v
+	}
+
+	return labels
+}
+
 func isLoadBalancerServiceUpdated(current, update v3.LoadBalancerService) bool {
 	return !cmp.Equal(current, update, cmpopts.IgnoreFields(current, "State", "HealthcheckStatus"))
 }
@@ -1,6 +1,203 @@
 package exoscale
 
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+
+	v3 "github.com/exoscale/egoscale/v3"
+	v1 "k8s.io/api/core/v1"
+)
+
 // LoadBalancer configuration (<-> cloud-config file)
 type loadBalancerConfig struct {
-	Disabled bool // if true, disables this controller
+	Disabled       bool // if true, disables this controller
+	RetainOnDelete bool `yaml:"retainOnDelete"` // if true, keep NLB instances (and their IP) upon Service deletion
+
+	// Defaults of the NLB instances and services, overridden by the Service
+	// annotations.
+	Strategy            string                        `yaml:"strategy"`
+	HealthCheck         loadBalancerHealthCheckConfig `yaml:"healthcheck"`
+	Labels              map[string]string             `yaml:"labels"`              // set on the NLB instances
+	NameTemplate        string                        `yaml:"nameTemplate"`        // NLB instance name, see loadBalancerTemplateData
+	DescriptionTemplate string                        `yaml:"descriptionTemplate"` // NLB instance description, see loadBalancerTemplateData
+
+	// Instance Pools and NLB instances the Services may use.
+	Policy loadBalancerPolicyConfig `yaml:"policy"`
+}
+
+type loadBalancerHealthCheckConfig struct {
+	Mode     string        `yaml:"mode"`
+	Interval time.Duration `yaml:"interval"`
+	Timeout  time.Duration `yaml:"timeout"`
+	Retries  int64         `yaml:"retries"`
+}
+
+// loadBalancerTemplateData is the data available to the NLB instance name and
+// description templates, e.g. "{{.ClusterName}}-{{.Namespace}}-{{.Name}}".
+type loadBalancerTemplateData struct {
+	ClusterName string // as set by the cloud-controller-manager --cluster-name flag
+	Namespace   string
+	Name        string
+	UID         string
+}
+
+// withDefaults returns a copy of the configuration, unset values being
+// replaced by their defaults.
+func (c loadBalancerConfig) withDefaults() loadBalancerConfig {
+	if c.Strategy == "" {
+		c.Strategy = string(defaultNLBServiceStrategy)
+	}
+	if c.HealthCheck.Mode == "" {
+		c.HealthCheck.Mode = string(defaultNLBServiceHealthcheckMode)
+	}
+	if c.HealthCheck.Interval == 0 {
+		c.HealthCheck.Interval, _ = time.ParseDuration(defaultNLBServiceHealthcheckInterval)
+	}
+	if c.HealthCheck.Timeout == 0 {
+		c.HealthCheck.Timeout, _ = time.ParseDuration(defaultNLBServiceHealthCheckTimeout)
+	}
+	if c.HealthCheck.Retries == 0 {
+		c.HealthCheck.Retries = defaultNLBServiceHealthcheckRetries
+	}
+
+	return c
+}
+
+// validate checks the consistency of the load balancer configuration.
+func (c *loadBalancerConfig) validate() error {
+	var errs []error
+
+	if c.Strategy != "" && !slices.Contains([]v3.LoadBalancerServiceStrategy{
+		v3.LoadBalancerServiceStrategyRoundRobin,
+		v3.LoadBalancerServiceStrategyMaglevHash,
+		v3.LoadBalancerServiceStrategySourceHash,
+	}, v3.LoadBalancerServiceStrategy(c.Strategy)) {
+		errs = append(errs, fmt.Errorf(
+			"loadBalancer.strategy: invalid strategy %q (expected round-robin, maglev-hash or source-hash)",
+			c.Strategy,
+		))
+	}
+
+	if c.HealthCheck.Mode != "" && !slices.Contains([]v3.LoadBalancerServiceHealthcheckMode{
+		v3.LoadBalancerServiceHealthcheckModeTCP,
+		v3.LoadBalancerServiceHealthcheckModeHTTP,
+		v3.LoadBalancerServiceHealthcheckModeHttps,
+	}, v3.LoadBalancerServiceHealthcheckMode(c.HealthCheck.Mode)) {
+		errs = append(errs, fmt.Errorf(
+			"loadBalancer.healthcheck.mode: invalid mode %q (expected tcp, http or https)",
+			c.HealthCheck.Mode,
+		))
+	}
+	if c.HealthCheck.Interval < 0 {
+		errs = append(errs, errors.New("loadBalancer.healthcheck.interval: must not be negative"))
+	}
+	if c.HealthCheck.Timeout < 0 {
+		errs = append(errs, errors.New("loadBalancer.healthcheck.timeout: must not be negative"))
+	}
+	if c.HealthCheck.Retries < 0 {
+		errs = append(errs, errors.New("loadBalancer.healthcheck.retries: must not be negative"))
+	}
+
+	keys := make([]string, 0, len(c.Labels))
+	for k := range c.Labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		switch k {
+		case "":
+			errs = append(errs, errors.New("loadBalancer.labels: empty label key"))
+		case nlbLabelServiceUID, nlbLabelServiceNamespace, nlbLabelServiceName, nlbLabelRetained:
+			errs = append(errs, fmt.Errorf("loadBalancer.labels: label %q is reserved", k))
+		}
+	}
+
+	for _, t := range []struct{ field, text string }{
+		{"nameTemplate", c.NameTemplate},
+		{"descriptionTemplate", c.DescriptionTemplate},
+	} {
+		if t.text == "" {
+			continue
+		}
+
+		out, err := renderLoadBalancerTemplate(t.text, loadBalancerTemplateData{
+			ClusterName: "kubernetes",
+			Namespace:   "default",
+			Name:        "example",
+			UID:         "00000000-0000-0000-0000-000000000000",
+		})
+		switch {
+		case err != nil:
+			errs = append(errs, fmt.Errorf("loadBalancer.%s: %w", t.field, err))
+		case t.field == "nameTemplate" && strings.TrimSpace(out) == "":
+			errs = append(errs, errors.New("loadBalancer.nameTemplate: renders an empty name"))
+		}
+	}
+
+	errs = append(errs, c.Policy.validate())
+
+	return errors.Join(errs...)
+}
+
+// loadBalancerName returns the default name of the NLB instance of the
+// Kubernetes Service, rendered from the name template if any.
+func (c *loadBalancerConfig) loadBalancerName(clusterName string, service *v1.Service) string {
+	defaultName := "k8s-" + string(service.UID)
+	if c.NameTemplate == "" {
+		return defaultName
+	}
+
+	name, err := renderLoadBalancerTemplate(c.NameTemplate, newLoadBalancerTemplateData(clusterName, service))
+	if err != nil || strings.TrimSpace(name) == "" {
+		errorf("unable to render NLB name template, using default name %q: %v", defaultName, err)
+		return defaultName
+	}
+
+	return name
+}
+
+// loadBalancerDescription returns the default description of the NLB
+// instance of the Kubernetes Service, rendered from the description template
+// if any.
+func (c *loadBalancerConfig) loadBalancerDescription(clusterName string, service *v1.Service) string {
+	if c.DescriptionTemplate == "" {
+		return ""
+	}
+
+	description, err := renderLoadBalancerTemplate(c.DescriptionTemplate, newLoadBalancerTemplateData(clusterName, service))
+	if err != nil {
+		errorf("unable to render NLB description template: %v", err)
+		return ""
+	}
+
+	return description
+}
+
+func newLoadBalancerTemplateData(clusterName string, service *v1.Service) loadBalancerTemplateData {
+	return loadBalancerTemplateData{
+		ClusterName: clusterName,
+		Namespace:   service.Namespace,
+		Name:        service.Name,
+		UID:         string(service.UID),
+	}
+}
+
+func renderLoadBalancerTemplate(text string, data loadBalancerTemplateData) (string, error) {
+	tmpl, err := template.New("").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", err
+	}
+
+	var out bytes.Buffer
+	if err := tmpl.Execute(&out, data); err != nil {
+		return "", err
+	}
+
+	return out.String(), nil
 }
@@ -0,0 +1,51 @@
+package exoscale
+
+import (
+	"strings"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func (ts *exoscaleCCMTestSuite) Test_loadBalancerConfig_validate() {
+	ts.Require().NoError((&loadBalancerConfig{}).validate())
+	ts.Require().NoError((&loadBalancerConfig{
+		Strategy:     "maglev-hash",
+		HealthCheck:  loadBalancerHealthCheckConfig{Mode: "https"},
+		Labels:       map[string]string{"team": "web"},
+		NameTemplate: "{{.ClusterName}}-{{.Namespace}}-{{.Name}}",
+	}).validate())
+
+	_, err := readExoscaleConfig(strings.NewReader(`---
+loadBalancer:
+  strategy: random
+  healthcheck:
+    mode: udp
+    interval: -10s
+  labels:
+    k8s-service-uid: "x"
+  nameTemplate: "{{.Cluster}}"
+  descriptionTemplate: "{{.Name"
+`))
+	ts.Require().Error(err)
+	for _, expected := range []string{
+		`loadBalancer.strategy: invalid strategy "random"`,
+		`loadBalancer.healthcheck.mode: invalid mode "udp"`,
+		"loadBalancer.healthcheck.interval: must not be negative",
+		`loadBalancer.labels: label "k8s-service-uid" is reserved`,
+		"loadBalancer.nameTemplate:",
+		"loadBalancer.descriptionTemplate:",
+	} {
+		ts.Require().ErrorContains(err, expected)
+	}
+}
+
+func (ts *exoscaleCCMTestSuite) Test_loadBalancer_GetLoadBalancerName_template() {
+	ts.p.cfg = &cloudConfig{LoadBalancer: loadBalancerConfig{NameTemplate: "{{.ClusterName}}-{{.Namespace}}-{{.Name}}"}}
+
+	service := &v1.Service{ObjectMeta: metav1.ObjectMeta{Namespace: "shop", Name: "frontend"}}
+	ts.Require().Equal("prod-shop-frontend", ts.p.loadBalancer.GetLoadBalancerName(ts.p.ctx, "prod", service))
+
+	service.Annotations = map[string]string{annotationLoadBalancerName: testNLBName}
+	ts.Require().Equal(testNLBName, ts.p.loadBalancer.GetLoadBalancerName(ts.p.ctx, "prod", service))
+}
@@ -0,0 +1,397 @@
+package exoscale
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/workqueue"
+
+	v3 "github.com/exoscale/egoscale/v3"
+)
+
+// inferredInstancePoolResyncDelay is the delay after which the inferred NLB
+// service Instance Pool of a Service is re-evaluated once its EndpointSlices
+// have changed.
+const inferredInstancePoolResyncDelay = 10 * time.Second
+
+// isInstancePoolInferred returns true if the NLB service Instance Pool ID set
+// in the Service annotations has been inferred by the CCM (as opposed to
+// specified by the user), in which case it is subject to re-evaluation.
+func (l *loadBalancer) isInstancePoolInferred(service *v1.Service) bool {
+	inferred := getAnnotation(service, annotationLoadBalancerServiceInstancePoolIDInferred, "")
+
+	return inferred != "" && inferred == getAnnotation(service, annotationLoadBalancerServiceInstancePoolID, "")
+}
+
+// shouldReinferInstancePool returns true if the NLB service Instance Pool ID
+// set in the Service annotations is subject to re-evaluation: if it has been
+// inferred by the CCM, or if it isn't marked as such but is stale.
+//
+// CCM versions predating the inferred marker annotation didn't record that
+// the Instance Pool ID was inferred, so an unmarked one is considered stale
+// (and from then on inferred) if it doesn't exist anymore or none of the
+// cluster Nodes belongs to it, as it can't serve the Service NodePorts in any
+// case (e.g. the Instance Pool of a replaced SKS Nodepool).
+func (l *loadBalancer) shouldReinferInstancePool(ctx context.Context, service *v1.Service, nodes []*v1.Node) (bool, error) {
+	if l.isInstancePoolInferred(service) {
+		return true, nil
+	}
+
+	current := getAnnotation(service, annotationLoadBalancerServiceInstancePoolID, "")
+	if current == "" || len(nodes) == 0 ||
+		getAnnotation(service, annotationLoadBalancerServiceInstancePoolIDInferred, "") != "" ||
+		getAnnotation(service, annotationLoadBalancerServiceSKSNodePoolName, "") != "" {
+		return false, nil
+	}
+
+	instancePool, err := l.p.client.GetInstancePool(ctx, v3.UUID(current))
+	if err != nil {
+		if errors.Is(err, v3.ErrNotFound) {
+			infof("Instance Pool %s of Service %s/%s not found, inferring it from cluster Nodes",
+				current, service.Namespace, service.Name)
+			return true, nil
+		}
+		return false, fmt.Errorf("error retrieving Instance Pool %s: %w", current, err)
+	}
+
+	for _, instance := range instancePool.Instances {
+		for _, node := range nodes {
+			if node.Status.NodeInfo.SystemUUID == instance.ID.String() {
+				return false, nil
+			}
+		}
+	}
+
+	infof("no cluster Node belongs to Instance Pool %s of Service %s/%s, inferring it from cluster Nodes",
+		current, service.Namespace, service.Name)
+
+	return true, nil
+}
+
+// inferInstancePool infers the NLB service Instance Pool ID from the cluster
+// Nodes hosting the Service ready endpoints, and records it in the Service
+// annotations.
+func (l *loadBalancer) inferInstancePool(ctx context.Context, service *v1.Service, nodes []*v1.Node) error {
+	instancePoolID, err := l.inferInstancePoolID(ctx, service, nodes)
+	if err != nil {
+		return err
+	}
+
+	if current := getAnnotation(service, annotationLoadBalancerServiceInstancePoolID, ""); current != "" &&
+		current != instancePoolID.String() {
+		infof("Service %s/%s endpoints moved from Instance Pool %s to %s",
+			service.Namespace, service.Name, current, instancePoolID)
+	}
+
+	debugf("inferred NLB service Instance Pool ID from cluster Nodes: %s", instancePoolID)
+
+	if err := l.patchAnnotations(ctx, service, map[string]string{
+		annotationLoadBalancerServiceInstancePoolID:         instancePoolID.String(),
+		annotationLoadBalancerServiceInstancePoolIDInferred: instancePoolID.String(),
+	}); err != nil {
+		return fmt.Errorf("error patching annotations: %s", err)
+	}
+
+	return nil
+}
+
+// inferInstancePoolID returns the ID of the Instance Pool (or SKS Nodepool
+// Instance Pool) the cluster Nodes hosting the Service ready endpoints belong
+// to. If no ready endpoint could be found (e.g. the Service has just been
+// created), all the cluster Nodes specified are considered instead.
+func (l *loadBalancer) inferInstancePoolID(ctx context.Context, service *v1.Service, nodes []*v1.Node) (v3.UUID, error) {
+	candidates := nodes
+
+	endpointNodes, err := l.serviceEndpointNodes(ctx, service, nodes)
+	if err != nil {
+		errorf("unable to retrieve Service %s/%s endpoints, inferring Instance Pool from all cluster Nodes: %v",
+			service.Namespace, service.Name, err)
+	} else if len(endpointNodes) > 0 {
+		candidates = endpointNodes
+	} else {
+		debugf("no ready endpoint found for Service %s/%s, inferring Instance Pool from all cluster Nodes",
+			service.Namespace, service.Name)
+	}
+
+	return l.nodesInstancePoolID(ctx, candidates)
+}
+
+// nodesInstancePoolID returns the ID of the single Instance Pool (or SKS
+// Nodepool Instance Pool) the cluster Nodes specified belong to, standalone
+// Nodes being ignored.
+func (l *loadBalancer) nodesInstancePoolID(ctx context.Context, nodes []*v1.Node) (v3.UUID, error) {
+	var (
+		instancePoolID v3.UUID
+		sksClusters    *v3.ListSKSClustersResponse
+	)
+	for _, node := range nodes {
+		instance, err := l.p.client.GetInstance(ctx, v3.UUID(node.Status.NodeInfo.SystemUUID))
+		if err != nil {
+			return "", fmt.Errorf("error retrieving Compute instance information: %s", err)
+		}
+
+		// Standalone Node, leaving it alone.
+		if instance.Manager == nil {
+			continue
+		}
+
+		var nodeInstancePoolID v3.UUID
+		switch instance.Manager.Type {
+		case v3.ManagerTypeInstancePool:
+			nodeInstancePoolID = instance.Manager.ID
+
+		case v3.ManagerTypeSKSNodepool:
+			if sksClusters == nil {
+				if sksClusters, err = l.p.client.ListSKSClusters(ctx); err != nil {
+					return "", fmt.Errorf("error listing SKS clusters: %s", err)
+				}
+			}
+
+			if nodeInstancePoolID = sksNodepoolInstancePoolID(sksClusters, instance.Manager.ID); nodeInstancePoolID == "" {
+				return "", fmt.Errorf("SKS node pool %s of Node %s not found", instance.Manager.ID, node.Name)
+			}
+
+		default:
+			continue
+		}
+
+		if instancePoolID != "" && nodeInstancePoolID != instancePoolID {
+			return "", errors.New(
+				"multiple Instance Pools detected across cluster Nodes hosting the Service endpoints, " +
+					"an Instance Pool ID must be specified in Service manifest annotations",
+			)
+		}
+
+		instancePoolID = nodeInstancePoolID
+	}
+
+	if instancePoolID == "" {
+		return "", errors.New("couldn't infer any Instance Pool from cluster Nodes")
+	}
+
+	return instancePoolID, nil
+}
+
+// serviceEndpointNodes returns the cluster Nodes hosting the Service ready
+// endpoints, as reported by the Service EndpointSlices.
+func (l *loadBalancer) serviceEndpointNodes(ctx context.Context, service *v1.Service, nodes []*v1.Node) ([]*v1.Node, error) {
+	endpointSlices, err := l.p.kclient.DiscoveryV1().EndpointSlices(service.Namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: discoveryv1.LabelServiceName + "=" + service.Name,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	nodesByName := make(map[string]*v1.Node, len(nodes))
+	for _, node := range nodes {
+		nodesByName[node.Name] = node
+	}
+
+	var endpointNodes []*v1.Node
+	for _, endpointSlice := range endpointSlices.Items {
+		for _, endpoint := range endpointSlice.Endpoints {
+			if endpoint.NodeName == nil || (endpoint.Conditions.Ready != nil && !*endpoint.Conditions.Ready) {
+				continue
+			}
+
+			node, ok := nodesByName[*endpoint.NodeName]
+			if !ok {
+				if node, err = l.p.kclient.CoreV1().Nodes().Get(ctx, *endpoint.NodeName, metav1.GetOptions{}); err != nil {
+					return nil, fmt.Errorf("failed to retrieve node %s from the apiserver: %w", *endpoint.NodeName, err)
+				}
+			}
+
+			nodesByName[node.Name] = node
+			if !containsNode(endpointNodes, node) {
+				endpointNodes = append(endpointNodes, node)
+			}
+		}
+	}
+
+	return endpointNodes, nil
+}
+
+// watchInferredInstancePools re-evaluates the inferred NLB service Instance
+// Pool of the Services whose EndpointSlices change, until ctx is done.
+//
+// The Service controller only re-syncs the load balancers upon Service or
+// Node changes: when the endpoints of a Service move to a different Instance
+// Pool, updating the inferred Instance Pool annotations triggers the update of
+// the NLB service.
+func (l *loadBalancer) watchInferredInstancePools(ctx context.Context) {
+	informerFactory := informers.NewSharedInformerFactory(l.p.kclient, 0)
+	endpointSlices := informerFactory.Discovery().V1().EndpointSlices()
+	services := informerFactory.Core().V1().Services()
+	nodes := informerFactory.Core().V1().Nodes()
+
+	queue := workqueue.NewTypedRateLimitingQueueWithConfig(
+		workqueue.DefaultTypedControllerRateLimiter[string](),
+		workqueue.TypedRateLimitingQueueConfig[string]{Name: "inferred-instance-pools"},
+	)
+	defer queue.ShutDown()
+
+	enqueue := func(obj interface{}) {
+		if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+			obj = tombstone.Obj
+		}
+		endpointSlice, ok := obj.(*discoveryv1.EndpointSlice)
+		if !ok {
+			return
+		}
+
+		if name := endpointSlice.Labels[discoveryv1.LabelServiceName]; name != "" {
+			// Coalescing the bursts of changes occurring during rollouts.
+			queue.AddAfter(endpointSlice.Namespace+"/"+name, inferredInstancePoolResyncDelay)
+		}
+	}
+
+	_, err := endpointSlices.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: enqueue,
+		UpdateFunc: func(_, obj interface{}) {
+			enqueue(obj)
+		},
+		DeleteFunc: enqueue,
+	})
+	if err != nil {
+		errorf("failed to watch EndpointSlices: %v", err)
+		return
+	}
+
+	servicesLister := services.Lister()
+	nodesLister := nodes.Lister()
+
+	informerFactory.Start(ctx.Done())
+	defer informerFactory.Shutdown()
+
+	if !cache.WaitForCacheSync(ctx.Done(),
+		endpointSlices.Informer().HasSynced,
+		services.Informer().HasSynced,
+		nodes.Informer().HasSynced,
+	) {
+		return
+	}
+
+	ctx = withAPICaller(ctx, apiCallerService)
+
+	go func() {
+		<-ctx.Done()
+		queue.ShutDown()
+	}()
+
+	for {
+		key, shutdown := queue.Get()
+		if shutdown {
+			return
+		}
+
+		err := func() error {
+			namespace, name, err := cache.SplitMetaNamespaceKey(key)
+			if err != nil {
+				return nil
+			}
+
+			service, err := servicesLister.Services(namespace).Get(name)
+			if err != nil {
+				// The Service has been deleted, or its EndpointSlices are not
+				// managed by a Service (e.g. mirrored ones).
+				return nil
+			}
+
+			clusterNodes, err := nodesLister.List(labels.Everything())
+			if err != nil {
+				return err
+			}
+
+			return l.reinferInstancePool(ctx, service, clusterNodes)
+		}()
+		if err != nil {
+			errorf("unable to re-evaluate the Instance Pool of Service %s: %v", key, err)
+			queue.AddRateLimited(key)
+		} else {
+			queue.Forget(key)
+		}
+		queue.Done(key)
+	}
+}
+
+// reinferInstancePool updates the inferred NLB service Instance Pool of the
+// Service specified if its ready endpoints have moved to a different one.
+// Services whose endpoints are not ready anywhere are left alone.
+func (l *loadBalancer) reinferInstancePool(ctx context.Context, service *v1.Service, nodes []*v1.Node) error {
+	if service.Spec.Type != v1.ServiceTypeLoadBalancer || !l.isInstancePoolInferred(service) {
+		return nil
+	}
+
+	endpointNodes, err := l.serviceEndpointNodes(ctx, service, nodes)
+	if err != nil {
+		return fmt.Errorf("unable to retrieve Service endpoints: %w", err)
+	}
+	if len(endpointNodes) == 0 {
+		return nil
+	}
+
+	instancePoolID, err := l.nodesInstancePoolID(ctx, endpointNodes)
+	if err != nil {
+		return err
+	}
+
+	current := getAnnotation(service, annotationLoadBalancerServiceInstancePoolID, "")
+	if instancePoolID.String() == current {
+		return nil
+	}
+
+	infof("Service %s/%s endpoints moved from Instance Pool %s to %s",
+		service.Namespace, service.Name, current, instancePoolID)
+
+	// The Service must not be modified as it belongs to the informer cache.
+	if err := l.patchAnnotations(ctx, service.DeepCopy(), map[string]string{
+		annotationLoadBalancerServiceInstancePoolID:         instancePoolID.String(),
+		annotationLoadBalancerServiceInstancePoolIDInferred: instancePoolID.String(),
+	}); err != nil {
+		return fmt.Errorf("error patching annotations: %s", err)
+	}
+
+	return nil
+}
+
+// sksNodepoolInstancePoolID returns the ID of the Instance Pool backing the
+// SKS Nodepool specified, or an empty string if not found.
+func sksNodepoolInstancePoolID(sksClusters *v3.ListSKSClustersResponse, nodepoolID v3.UUID) v3.UUID {
+	for _, cluster := range sksClusters.SKSClusters {
+		for _, nodepool := range cluster.Nodepools {
+			if nodepool.ID == nodepoolID && nodepool.InstancePool != nil {
+				return nodepool.InstancePool.ID
+			}
+		}
+	}
+
+	return ""
+}
+
+func containsNode(nodes []*v1.Node, node *v1.Node) bool {
+	for _, n := range nodes {
+		if n.Name == node.Name {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (c *refreshableExoscaleClient) GetInstancePool(ctx context.Context, id v3.UUID) (*v3.InstancePool, error) {
+	ctx, cancel := c.withTimeout(ctx, "GetInstancePool")
+	defer cancel()
+
+	return c.client().GetInstancePool(
+		ctx,
+		id,
+	)
+}
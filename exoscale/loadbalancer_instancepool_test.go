@@ -0,0 +1,312 @@
+package exoscale
+
+import (
+	v1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/utils/ptr"
+
+	v3 "github.com/exoscale/egoscale/v3"
+)
+
+func (ts *exoscaleCCMTestSuite) newInstancePoolTestNode(instancePoolID v3.UUID, managerType v3.ManagerType) *v1.Node {
+	instanceID := v3.UUID(ts.randomID())
+	node := &v1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: ts.randomString(10)},
+		Status:     v1.NodeStatus{NodeInfo: v1.NodeSystemInfo{SystemUUID: instanceID.String()}},
+	}
+
+	ts.p.client.(*exoscaleClientMock).
+		On("GetInstance", withAPICaller(ts.p.ctx, apiCallerService), instanceID).
+		Return(&v3.Instance{
+			ID:      instanceID,
+			Manager: &v3.Manager{ID: instancePoolID, Type: managerType},
+		}, nil)
+
+	return node
+}
+
+func newInstancePoolTestEndpointSlice(service *v1.Service, nodeName string, ready bool) *discoveryv1.EndpointSlice {
+	return &discoveryv1.EndpointSlice{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      service.Name + "-" + nodeName,
+			Namespace: service.Namespace,
+			Labels:    map[string]string{discoveryv1.LabelServiceName: service.Name},
+		},
+		Endpoints: []discoveryv1.Endpoint{{
+			Addresses:  []string{"10.0.0.1"},
+			Conditions: discoveryv1.EndpointConditions{Ready: ptr.To(ready)},
+			NodeName:   ptr.To(nodeName),
+		}},
+	}
+}
+
+func (ts *exoscaleCCMTestSuite) Test_loadBalancer_inferInstancePoolID() {
+	var (
+		instancePoolAID = v3.UUID(ts.randomID())
+		instancePoolBID = v3.UUID(ts.randomID())
+		sksNodepoolID   = v3.UUID(ts.randomID())
+
+		service = &v1.Service{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "test",
+				Namespace: metav1.NamespaceDefault,
+			},
+		}
+	)
+
+	ts.Run("endpoints on a single Instance Pool", func() {
+		ts.SetupTest()
+
+		nodeA := ts.newInstancePoolTestNode(instancePoolAID, v3.ManagerTypeInstancePool)
+		nodeB := ts.newInstancePoolTestNode(instancePoolBID, v3.ManagerTypeInstancePool)
+
+		ts.p.kclient = fake.NewSimpleClientset(
+			newInstancePoolTestEndpointSlice(service, nodeA.Name, false),
+			newInstancePoolTestEndpointSlice(service, nodeB.Name, true),
+		)
+
+		actual, err := ts.p.loadBalancer.(*loadBalancer).inferInstancePoolID(withAPICaller(ts.p.ctx, apiCallerService), service, []*v1.Node{nodeA, nodeB})
+		ts.Require().NoError(err)
+		ts.Require().Equal(instancePoolBID, actual)
+	})
+
+	ts.Run("endpoints on a SKS Nodepool", func() {
+		ts.SetupTest()
+
+		nodeA := ts.newInstancePoolTestNode(instancePoolAID, v3.ManagerTypeInstancePool)
+		nodeB := ts.newInstancePoolTestNode(sksNodepoolID, v3.ManagerTypeSKSNodepool)
+
+		ts.p.client.(*exoscaleClientMock).
+			On("ListSKSClusters", withAPICaller(ts.p.ctx, apiCallerService)).
+			Return(&v3.ListSKSClustersResponse{SKSClusters: []v3.SKSCluster{{
+				Nodepools: []v3.SKSNodepool{{
+					ID:           sksNodepoolID,
+					InstancePool: &v3.InstancePool{ID: instancePoolBID},
+				}},
+			}}}, nil)
+
+		ts.p.kclient = fake.NewSimpleClientset(newInstancePoolTestEndpointSlice(service, nodeB.Name, true))
+
+		actual, err := ts.p.loadBalancer.(*loadBalancer).inferInstancePoolID(withAPICaller(ts.p.ctx, apiCallerService), service, []*v1.Node{nodeA, nodeB})
+		ts.Require().NoError(err)
+		ts.Require().Equal(instancePoolBID, actual)
+	})
+
+	ts.Run("endpoints across multiple Instance Pools", func() {
+		ts.SetupTest()
+
+		nodeA := ts.newInstancePoolTestNode(instancePoolAID, v3.ManagerTypeInstancePool)
+		nodeB := ts.newInstancePoolTestNode(instancePoolBID, v3.ManagerTypeInstancePool)
+
+		ts.p.kclient = fake.NewSimpleClientset(
+			newInstancePoolTestEndpointSlice(service, nodeA.Name, true),
+			newInstancePoolTestEndpointSlice(service, nodeB.Name, true),
+		)
+
+		_, err := ts.p.loadBalancer.(*loadBalancer).inferInstancePoolID(withAPICaller(ts.p.ctx, apiCallerService), service, []*v1.Node{nodeA, nodeB})
+		ts.Require().Error(err)
+	})
+
+	ts.Run("no endpoints", func() {
+		ts.SetupTest()
+
+		nodeA := ts.newInstancePoolTestNode(instancePoolAID, v3.ManagerTypeInstancePool)
+
+		actual, err := ts.p.loadBalancer.(*loadBalancer).inferInstancePoolID(withAPICaller(ts.p.ctx, apiCallerService), service, []*v1.Node{nodeA})
+		ts.Require().NoError(err)
+		ts.Require().Equal(instancePoolAID, actual)
+	})
+}
+
+func (ts *exoscaleCCMTestSuite) Test_loadBalancer_UpdateLoadBalancer_reinferInstancePool() {
+	var (
+		instancePoolAID = v3.UUID(ts.randomID())
+		instancePoolBID = v3.UUID(ts.randomID())
+	)
+
+	for _, tt := range []struct {
+		name     string
+		inferred string
+		// poolA is the Instance Pool A returned by the API (nil if not
+		// found), given the Node belonging to it.
+		poolA    func(nodeA *v1.Node) *v3.InstancePool
+		nodeA    bool
+		expected v3.UUID
+	}{
+		{
+			name:     "inferred",
+			inferred: instancePoolAID.String(),
+			expected: instancePoolBID,
+		},
+		{
+			name: "specified",
+			poolA: func(nodeA *v1.Node) *v3.InstancePool {
+				return &v3.InstancePool{ID: instancePoolAID, Instances: []v3.Instance{
+					{ID: v3.UUID(nodeA.Status.NodeInfo.SystemUUID)},
+				}}
+			},
+			nodeA:    true,
+			expected: instancePoolAID,
+		},
+		{
+			// Inferred by a CCM version predating the inferred marker.
+			name: "unmarked without cluster Nodes",
+			poolA: func(_ *v1.Node) *v3.InstancePool {
+				return &v3.InstancePool{ID: instancePoolAID, Instances: []v3.Instance{{ID: v3.UUID(ts.randomID())}}}
+			},
+			expected: instancePoolBID,
+		},
+		{
+			name:     "unmarked not found",
+			poolA:    func(_ *v1.Node) *v3.InstancePool { return nil },
+			expected: instancePoolBID,
+		},
+	} {
+		ts.Run(tt.name, func() {
+			ts.SetupTest()
+
+			service := &v1.Service{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "test",
+					Namespace: metav1.NamespaceDefault,
+					Annotations: map[string]string{
+						annotationLoadBalancerID:                            testNLBID.String(),
+						annotationLoadBalancerName:                          testNLBName,
+						annotationLoadBalancerServiceInstancePoolID:         instancePoolAID.String(),
+						annotationLoadBalancerServiceInstancePoolIDInferred: tt.inferred,
+					},
+				},
+			}
+
+			nodeA := ts.newInstancePoolTestNode(instancePoolAID, v3.ManagerTypeInstancePool)
+			nodeB := ts.newInstancePoolTestNode(instancePoolBID, v3.ManagerTypeInstancePool)
+			nodes := []*v1.Node{nodeB}
+			if tt.nodeA {
+				nodes = append(nodes, nodeA)
+			}
+
+			if tt.poolA != nil {
+				if pool := tt.poolA(nodeA); pool != nil {
+					ts.p.client.(*exoscaleClientMock).
+						On("GetInstancePool", withAPICaller(ts.p.ctx, apiCallerService), instancePoolAID).
+						Return(pool, nil)
+				} else {
+					ts.p.client.(*exoscaleClientMock).
+						On("GetInstancePool", withAPICaller(ts.p.ctx, apiCallerService), instancePoolAID).
+						Return((*v3.InstancePool)(nil), v3.ErrNotFound)
+				}
+			}
+
+			ts.p.client.(*exoscaleClientMock).
+				On("GetLoadBalancer", withAPICaller(ts.p.ctx, apiCallerService), testNLBID).
+				Return(&v3.LoadBalancer{ID: testNLBID, Name: testNLBName}, nil)
+
+			ts.p.kclient = fake.NewSimpleClientset([]runtime.Object{
+				service,
+				newInstancePoolTestEndpointSlice(service, nodeB.Name, true),
+			}...)
+
+			err := ts.p.loadBalancer.UpdateLoadBalancer(ts.p.ctx, "", service, nodes)
+			ts.Require().NoError(err)
+			ts.Require().Equal(tt.expected.String(), service.Annotations[annotationLoadBalancerServiceInstancePoolID])
+			if tt.expected == instancePoolBID {
+				ts.Require().Equal(instancePoolBID.String(), service.Annotations[annotationLoadBalancerServiceInstancePoolIDInferred])
+			}
+		})
+	}
+}
+
+func (ts *exoscaleCCMTestSuite) Test_loadBalancer_inferInstancePool_singlePatch() {
+	var (
+		instancePoolID = v3.UUID(ts.randomID())
+
+		service = &v1.Service{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "test",
+				Namespace: metav1.NamespaceDefault,
+			},
+		}
+	)
+
+	node := ts.newInstancePoolTestNode(instancePoolID, v3.ManagerTypeInstancePool)
+	kclient := fake.NewSimpleClientset(service)
+	ts.p.kclient = kclient
+
+	err := ts.p.loadBalancer.(*loadBalancer).inferInstancePool(withAPICaller(ts.p.ctx, apiCallerService), service, []*v1.Node{node})
+	ts.Require().NoError(err)
+
+	// Both annotations must be recorded at once, a failure in between would
+	// leave the inferred Instance Pool looking specified by the user.
+	var patches int
+	for _, action := range kclient.Actions() {
+		if action.GetVerb() == "patch" {
+			patches++
+		}
+	}
+	ts.Require().Equal(1, patches)
+
+	actual, err := kclient.CoreV1().Services(service.Namespace).Get(ts.p.ctx, service.Name, metav1.GetOptions{})
+	ts.Require().NoError(err)
+	ts.Require().Equal(instancePoolID.String(), actual.Annotations[annotationLoadBalancerServiceInstancePoolID])
+	ts.Require().Equal(instancePoolID.String(), actual.Annotations[annotationLoadBalancerServiceInstancePoolIDInferred])
+}
+
+func (ts *exoscaleCCMTestSuite) Test_loadBalancer_reinferInstancePool() {
+	var (
+		instancePoolAID = v3.UUID(ts.randomID())
+		instancePoolBID = v3.UUID(ts.randomID())
+	)
+
+	for _, tt := range []struct {
+		name      string
+		inferred  string
+		endpoints bool
+		expected  v3.UUID
+	}{
+		{name: "endpoints moved", inferred: instancePoolAID.String(), endpoints: true, expected: instancePoolBID},
+		{name: "no ready endpoints", inferred: instancePoolAID.String(), endpoints: false, expected: instancePoolAID},
+		{name: "specified", inferred: "", endpoints: true, expected: instancePoolAID},
+	} {
+		ts.Run(tt.name, func() {
+			ts.SetupTest()
+
+			service := &v1.Service{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "test",
+					Namespace: metav1.NamespaceDefault,
+					Annotations: map[string]string{
+						annotationLoadBalancerServiceInstancePoolID:         instancePoolAID.String(),
+						annotationLoadBalancerServiceInstancePoolIDInferred: tt.inferred,
+					},
+				},
+				Spec: v1.ServiceSpec{Type: v1.ServiceTypeLoadBalancer},
+			}
+
+			nodeA := ts.newInstancePoolTestNode(instancePoolAID, v3.ManagerTypeInstancePool)
+			nodeB := ts.newInstancePoolTestNode(instancePoolBID, v3.ManagerTypeInstancePool)
+
+			kclient := fake.NewSimpleClientset(
+				service,
+				newInstancePoolTestEndpointSlice(service, nodeB.Name, tt.endpoints),
+			)
+			ts.p.kclient = kclient
+
+			err := ts.p.loadBalancer.(*loadBalancer).reinferInstancePool(
+				withAPICaller(ts.p.ctx, apiCallerService),
+				service,
+				[]*v1.Node{nodeA, nodeB},
+			)
+			ts.Require().NoError(err)
+
+			actual, err := kclient.CoreV1().Services(service.Namespace).Get(ts.p.ctx, service.Name, metav1.GetOptions{})
+			ts.Require().NoError(err)
+			ts.Require().Equal(tt.expected.String(), actual.Annotations[annotationLoadBalancerServiceInstancePoolID])
+
+			// The Service specified (from the informer cache) is left untouched.
+			ts.Require().Equal(instancePoolAID.String(), service.Annotations[annotationLoadBalancerServiceInstancePoolID])
+		})
+	}
+}
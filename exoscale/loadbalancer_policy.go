@@ -0,0 +1,237 @@
+package exoscale
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	v1 "k8s.io/api/core/v1"
+
+	v3 "github.com/exoscale/egoscale/v3"
+)
+
+// eventReasonNLBPolicyRejected is the reason of the Kubernetes Events recorded
+// on Services rejected by the load balancer policy.
+const eventReasonNLBPolicyRejected = "NLBPolicyRejected"
+
+var errLoadBalancerPolicyRejected = errors.New("rejected by the load balancer policy")
+
+// Load balancer policy configuration (<-> cloud-config file)
+type loadBalancerPolicyConfig struct {
+	loadBalancerPolicyRules `yaml:",inline"`
+
+	// Namespaces rules replace the cluster-wide ones for the Services of the
+	// namespaces specified.
+	Namespaces map[string]loadBalancerPolicyRules `yaml:"namespaces"`
+}
+
+// loadBalancerPolicyRules restricts the Instance Pools and NLB instances the
+// Services may use. A nil/empty Instance Pools rule allows any, whereas once a
+// policy is configured, NLB instances not created for the Service are only
+// allowed if matched by ExternalLoadBalancers.
+type loadBalancerPolicyRules struct {
+	InstancePools         *loadBalancerPolicyMatch `yaml:"instancePools"`
+	SKSNodepools          []string                 `yaml:"sksNodepools"` // "<cluster>/<nodepool>", nodepool may be "*"
+	ExternalLoadBalancers *loadBalancerPolicyMatch `yaml:"externalLoadBalancers"`
+}
+
+// loadBalancerPolicyMatch matches resources by ID, or by labels (all of which
+// must be set on the resource).
+type loadBalancerPolicyMatch struct {
+	IDs    []string          `yaml:"ids"`
+	Labels map[string]string `yaml:"labels"`
+}
+
+// validate checks the consistency of the load balancer policy configuration.
+func (c *loadBalancerPolicyConfig) validate() error {
+	errs := []error{c.loadBalancerPolicyRules.validate("loadBalancer.policy")}
+
+	namespaces := make([]string, 0, len(c.Namespaces))
+	for namespace := range c.Namespaces {
+		namespaces = append(namespaces, namespace)
+	}
+	sort.Strings(namespaces)
+	for _, namespace := range namespaces {
+		rules := c.Namespaces[namespace]
+		errs = append(errs, rules.validate(fmt.Sprintf("loadBalancer.policy.namespaces[%s]", namespace)))
+	}
+
+	return errors.Join(errs...)
+}
+
+func (r *loadBalancerPolicyRules) validate(path string) error {
+	var errs []error
+
+	for _, m := range []struct {
+		field string
+		match *loadBalancerPolicyMatch
+	}{
+		{"instancePools", r.InstancePools},
+		{"externalLoadBalancers", r.ExternalLoadBalancers},
+	} {
+		if m.match == nil {
+			continue
+		}
+		for i, id := range m.match.IDs {
+			if _, err := v3.ParseUUID(id); err != nil {
+				errs = append(errs, fmt.Errorf("%s.%s.ids[%d]: invalid ID %q", path, m.field, i, id))
+			}
+		}
+	}
+
+	for i, nodepool := range r.SKSNodepools {
+		if cluster, name, ok := strings.Cut(nodepool, "/"); !ok || cluster == "" || name == "" {
+			errs = append(errs, fmt.Errorf(
+				"%s.sksNodepools[%d]: invalid SKS nodepool %q (expected <cluster>/<nodepool>)",
+				path, i, nodepool,
+			))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// isConfigured returns true if any policy rule is set: without any, the
+// Services may use any Instance Pool and NLB instance.
+func (c *loadBalancerPolicyConfig) isConfigured() bool {
+	return c.InstancePools != nil ||
+		len(c.SKSNodepools) > 0 ||
+		c.ExternalLoadBalancers != nil ||
+		len(c.Namespaces) > 0
+}
+
+// rules returns the policy rules applying to the Service specified.
+func (c *loadBalancerPolicyConfig) rules(service *v1.Service) loadBalancerPolicyRules {
+	if rules, ok := c.Namespaces[service.Namespace]; ok {
+		return rules
+	}
+
+	return c.loadBalancerPolicyRules
+}
+
+// restrictsInstancePools returns true if the rules restrict the Instance
+// Pools the Services may forward traffic to.
+func (r *loadBalancerPolicyRules) restrictsInstancePools() bool {
+	return r.InstancePools != nil || len(r.SKSNodepools) > 0
+}
+
+// matches returns true if the resource ID or labels specified are matched.
+func (m *loadBalancerPolicyMatch) matches(id v3.UUID, labels v3.Labels) bool {
+	if m == nil {
+		return false
+	}
+
+	if slices.Contains(m.IDs, id.String()) {
+		return true
+	}
+
+	if len(m.Labels) == 0 {
+		return false
+	}
+	for k, v := range m.Labels {
+		if labels[k] != v {
+			return false
+		}
+	}
+
+	return true
+}
+
+// matchesSKSNodepool returns true if the Instance Pool specified backs one of
+// the SKS nodepools the rules allow.
+func (r *loadBalancerPolicyRules) matchesSKSNodepool(sksClusters *v3.ListSKSClustersResponse, poolID v3.UUID) bool {
+	for _, cluster := range sksClusters.SKSClusters {
+		for _, nodepool := range cluster.Nodepools {
+			if nodepool.InstancePool == nil || nodepool.InstancePool.ID != poolID {
+				continue
+			}
+
+			return slices.Contains(r.SKSNodepools, cluster.Name+"/"+nodepool.Name) ||
+				slices.Contains(r.SKSNodepools, cluster.Name+"/*")
+		}
+	}
+
+	return false
+}
+
+// checkInstancePoolsPolicy returns an error wrapping
+// errLoadBalancerPolicyRejected if the NLB services specified forward traffic
+// to Instance Pools the Service isn't allowed to use.
+func (l *loadBalancer) checkInstancePoolsPolicy(ctx context.Context, service *v1.Service, lbSpec *v3.LoadBalancer) error {
+	rules := l.config().Policy.rules(service)
+	if !rules.restrictsInstancePools() {
+		return nil
+	}
+
+	var sksClusters *v3.ListSKSClustersResponse
+	checked := make(map[v3.UUID]bool)
+	for _, svc := range lbSpec.Services {
+		if svc.InstancePool == nil || svc.InstancePool.ID == "" || checked[svc.InstancePool.ID] {
+			continue
+		}
+		poolID := svc.InstancePool.ID
+		checked[poolID] = true
+
+		if rules.InstancePools.matches(poolID, nil) {
+			continue
+		}
+
+		if rules.InstancePools != nil && len(rules.InstancePools.Labels) > 0 {
+			pool, err := l.p.client.GetInstancePool(ctx, poolID)
+			if err != nil {
+				return fmt.Errorf("error retrieving Instance Pool %s: %w", poolID, err)
+			}
+			if rules.InstancePools.matches(poolID, pool.Labels) {
+				continue
+			}
+		}
+
+		if len(rules.SKSNodepools) > 0 {
+			if sksClusters == nil {
+				var err error
+				if sksClusters, err = l.p.client.ListSKSClusters(ctx); err != nil {
+					return fmt.Errorf("error listing SKS clusters: %w", err)
+				}
+			}
+			if rules.matchesSKSNodepool(sksClusters, poolID) {
+				continue
+			}
+		}
+
+		return l.rejectf(service, "Instance Pool %s is not allowed for Services of namespace %q", poolID, service.Namespace)
+	}
+
+	return nil
+}
+
+// checkLoadBalancerPolicy returns an error wrapping
+// errLoadBalancerPolicyRejected if the NLB instance specified hasn't been
+// created for the Service, and the Service isn't explicitly allowed to use it.
+// The check is opt-in: without any policy configured, all the NLB instances
+// are allowed, whereas with one, leaving the ExternalLoadBalancers rule unset
+// doesn't allow any.
+func (l *loadBalancer) checkLoadBalancerPolicy(service *v1.Service, nlb *v3.LoadBalancer) error {
+	policy := &l.config().Policy
+	if !policy.isConfigured() || isLoadBalancerOwned(service, nlb) {
+		return nil
+	}
+
+	rules := policy.rules(service)
+	if rules.ExternalLoadBalancers.matches(nlb.ID, nlb.Labels) {
+		return nil
+	}
+
+	return l.rejectf(service, "NLB %s is not allowed for Services of namespace %q", nlb.ID, service.Namespace)
+}
+
+// rejectf records a Warning Event on the Service rejected by the load
+// balancer policy, and returns the corresponding error.
+func (l *loadBalancer) rejectf(service *v1.Service, format string, args ...interface{}) error {
+	message := fmt.Sprintf(format, args...)
+	l.p.warningEventf(service, eventReasonNLBPolicyRejected, "%s", message)
+
+	return fmt.Errorf("%w: %s", errLoadBalancerPolicyRejected, message)
+}
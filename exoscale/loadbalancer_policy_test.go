@@ -0,0 +1,276 @@
+package exoscale
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/stretchr/testify/mock"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/record"
+
+	v3 "github.com/exoscale/egoscale/v3"
+)
+
+func (ts *exoscaleCCMTestSuite) Test_loadBalancerPolicyConfig_validate() {
+	_, err := readExoscaleConfig(strings.NewReader(`---
+loadBalancer:
+  policy:
+    instancePools:
+      ids: ["not-an-id"]
+    sksNodepools: ["my-cluster"]
+    namespaces:
+      team-a:
+        externalLoadBalancers:
+          ids: ["x"]
+`))
+	ts.Require().Error(err)
+	for _, expected := range []string{
+		`loadBalancer.policy.instancePools.ids[0]: invalid ID "not-an-id"`,
+		`loadBalancer.policy.sksNodepools[0]: invalid SKS nodepool "my-cluster"`,
+		`loadBalancer.policy.namespaces[team-a].externalLoadBalancers.ids[0]: invalid ID "x"`,
+	} {
+		ts.Require().ErrorContains(err, expected)
+	}
+}
+
+func (ts *exoscaleCCMTestSuite) Test_loadBalancer_checkInstancePoolsPolicy() {
+	var (
+		allowedPoolID  = v3.UUID(ts.randomID())
+		labeledPoolID  = v3.UUID(ts.randomID())
+		nodepoolPoolID = v3.UUID(ts.randomID())
+		otherPoolID    = v3.UUID(ts.randomID())
+		service        = &v1.Service{ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", Name: "web"}}
+		lbSpec         = func(poolID v3.UUID) *v3.LoadBalancer {
+			return &v3.LoadBalancer{Services: []v3.LoadBalancerService{{InstancePool: &v3.InstancePool{ID: poolID}}}}
+		}
+	)
+
+	ts.p.cfg = &cloudConfig{LoadBalancer: loadBalancerConfig{Policy: loadBalancerPolicyConfig{
+		loadBalancerPolicyRules: loadBalancerPolicyRules{
+			InstancePools: &loadBalancerPolicyMatch{
+				IDs:    []string{allowedPoolID.String()},
+				Labels: map[string]string{"team": "a"},
+			},
+			SKSNodepools: []string{"prod/web"},
+		},
+		Namespaces: map[string]loadBalancerPolicyRules{"kube-system": {}},
+	}}}
+
+	ts.p.client.(*exoscaleClientMock).
+		On("GetInstancePool", ts.p.ctx, labeledPoolID).
+		Return(&v3.InstancePool{ID: labeledPoolID, Labels: v3.Labels{"team": "a"}}, nil)
+	ts.p.client.(*exoscaleClientMock).
+		On("GetInstancePool", ts.p.ctx, mock.Anything).
+		Return(&v3.InstancePool{}, nil)
+	ts.p.client.(*exoscaleClientMock).
+		On("ListSKSClusters", ts.p.ctx).
+		Return(&v3.ListSKSClustersResponse{SKSClusters: []v3.SKSCluster{{
+			Name: "prod",
+			Nodepools: []v3.SKSNodepool{
+				{Name: "web", InstancePool: &v3.InstancePool{ID: nodepoolPoolID}},
+				{Name: "db", InstancePool: &v3.InstancePool{ID: otherPoolID}},
+			},
+		}}}, nil)
+
+	l := ts.p.loadBalancer.(*loadBalancer)
+	ts.Require().NoError(l.checkInstancePoolsPolicy(ts.p.ctx, service, lbSpec(allowedPoolID)))
+	ts.Require().NoError(l.checkInstancePoolsPolicy(ts.p.ctx, service, lbSpec(labeledPoolID)))
+	ts.Require().NoError(l.checkInstancePoolsPolicy(ts.p.ctx, service, lbSpec(nodepoolPoolID)))
+
+	err := l.checkInstancePoolsPolicy(ts.p.ctx, service, lbSpec(otherPoolID))
+	ts.Require().ErrorIs(err, errLoadBalancerPolicyRejected)
+	events := ts.p.recorder.(*record.FakeRecorder).Events
+	ts.Require().Len(events, 1)
+	ts.Require().Contains(<-events, "Warning "+eventReasonNLBPolicyRejected)
+
+	// The namespace rules replace the cluster-wide ones.
+	service.Namespace = "kube-system"
+	ts.Require().NoError(l.checkInstancePoolsPolicy(ts.p.ctx, service, lbSpec(otherPoolID)))
+}
+
+func (ts *exoscaleCCMTestSuite) Test_loadBalancer_checkLoadBalancerPolicy() {
+	var (
+		serviceUID = ts.randomID()
+		service    = &v1.Service{ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", UID: types.UID(serviceUID)}}
+		allowedNLB = &v3.LoadBalancer{ID: v3.UUID(ts.randomID()), Labels: v3.Labels{"shared": "true"}}
+		ownNLB     = &v3.LoadBalancer{ID: v3.UUID(ts.randomID()), Labels: v3.Labels{nlbLabelServiceUID: serviceUID}}
+		otherNLB   = &v3.LoadBalancer{ID: v3.UUID(ts.randomID()), Labels: v3.Labels{nlbLabelServiceUID: ts.randomID()}}
+	)
+
+	l := ts.p.loadBalancer.(*loadBalancer)
+	ts.Require().NoError(l.checkLoadBalancerPolicy(service, otherNLB))
+
+	ts.p.cfg = &cloudConfig{LoadBalancer: loadBalancerConfig{Policy: loadBalancerPolicyConfig{
+		loadBalancerPolicyRules: loadBalancerPolicyRules{
+			ExternalLoadBalancers: &loadBalancerPolicyMatch{Labels: map[string]string{"shared": "true"}},
+		},
+	}}}
+
+	ts.Require().NoError(l.checkLoadBalancerPolicy(service, allowedNLB))
+	ts.Require().NoError(l.checkLoadBalancerPolicy(service, ownNLB))
+	ts.Require().ErrorIs(l.checkLoadBalancerPolicy(service, otherNLB), errLoadBalancerPolicyRejected)
+}
+
+func (ts *exoscaleCCMTestSuite) Test_loadBalancer_checkLoadBalancerPolicy_optIn() {
+	var (
+		service = &v1.Service{ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", UID: types.UID(ts.randomID())}}
+
+		// NLB instances the Service doesn't own: shared with another Service,
+		// externally managed, or created by a CCM version predating the
+		// ownership label with a custom name.
+		sharedNLB   = &v3.LoadBalancer{ID: v3.UUID(ts.randomID()), Labels: v3.Labels{nlbLabelServiceUID: ts.randomID()}}
+		externalNLB = &v3.LoadBalancer{ID: v3.UUID(ts.randomID()), Name: "ingress"}
+		legacyNLB   = &v3.LoadBalancer{ID: v3.UUID(ts.randomID()), Name: "web-nlb"}
+	)
+
+	l := ts.p.loadBalancer.(*loadBalancer)
+
+	// Without any policy configured (e.g. upon upgrade), all are allowed.
+	ts.Require().False(ts.p.cfg.LoadBalancer.Policy.isConfigured())
+	for _, nlb := range []*v3.LoadBalancer{sharedNLB, externalNLB, legacyNLB} {
+		ts.Require().NoError(l.checkLoadBalancerPolicy(service, nlb))
+	}
+	ts.Require().Empty(ts.p.recorder.(*record.FakeRecorder).Events)
+
+	// Once a policy is configured, they must be explicitly allowed.
+	ts.p.cfg = &cloudConfig{LoadBalancer: loadBalancerConfig{Policy: loadBalancerPolicyConfig{
+		loadBalancerPolicyRules: loadBalancerPolicyRules{
+			InstancePools: &loadBalancerPolicyMatch{IDs: []string{ts.randomID()}},
+		},
+	}}}
+	for _, nlb := range []*v3.LoadBalancer{sharedNLB, externalNLB, legacyNLB} {
+		ts.Require().ErrorIs(l.checkLoadBalancerPolicy(service, nlb), errLoadBalancerPolicyRejected)
+	}
+}
+
+func (ts *exoscaleCCMTestSuite) Test_loadBalancer_EnsureLoadBalancer_policyRejected() {
+	var (
+		allowedPoolID = v3.UUID(ts.randomID())
+		service       = &v1.Service{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: "team-a",
+				Name:      "web",
+				UID:       types.UID(ts.randomID()),
+				Annotations: map[string]string{
+					annotationLoadBalancerServiceInstancePoolID: testNLBServiceInstancePoolID.String(),
+				},
+			},
+			Spec: v1.ServiceSpec{
+				Ports: []v1.ServicePort{{Protocol: v1.ProtocolTCP, Port: 80, NodePort: 32058}},
+			},
+		}
+	)
+
+	ts.p.cfg = &cloudConfig{LoadBalancer: loadBalancerConfig{Policy: loadBalancerPolicyConfig{
+		loadBalancerPolicyRules: loadBalancerPolicyRules{
+			InstancePools: &loadBalancerPolicyMatch{IDs: []string{allowedPoolID.String()}},
+		},
+	}}}
+	ts.p.kclient = fake.NewSimpleClientset(service)
+
+	_, err := ts.p.loadBalancer.EnsureLoadBalancer(ts.p.ctx, "", service, nil)
+	ts.Require().ErrorIs(err, errLoadBalancerPolicyRejected)
+	ts.p.client.(*exoscaleClientMock).AssertNotCalled(ts.T(), "CreateLoadBalancer", mock.Anything, mock.Anything)
+}
+
+func (ts *exoscaleCCMTestSuite) Test_loadBalancer_EnsureLoadBalancerDeleted_policyRejected() {
+	var (
+		service = &v1.Service{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: "team-a",
+				Name:      "web",
+				UID:       types.UID(ts.randomID()),
+				Annotations: map[string]string{
+					annotationLoadBalancerID:          testNLBID.String(),
+					annotationLoadBalancerServiceName: "web",
+				},
+			},
+			Spec: v1.ServiceSpec{
+				Ports: []v1.ServicePort{{Protocol: v1.ProtocolTCP, Port: 80, NodePort: 32058}},
+			},
+		}
+
+		// An NLB instance of another team, hosting an NLB service named after
+		// the Service annotations.
+		otherNLB = &v3.LoadBalancer{
+			ID:     testNLBID,
+			Name:   testNLBName,
+			Labels: v3.Labels{nlbLabelServiceUID: ts.randomID()},
+			Services: []v3.LoadBalancerService{{
+				ID:       testNLBServiceID,
+				Name:     "web",
+				Port:     80,
+				Protocol: v3.LoadBalancerServiceProtocolTCP,
+			}},
+		}
+	)
+
+	ts.p.cfg = &cloudConfig{LoadBalancer: loadBalancerConfig{Policy: loadBalancerPolicyConfig{
+		loadBalancerPolicyRules: loadBalancerPolicyRules{
+			ExternalLoadBalancers: &loadBalancerPolicyMatch{Labels: map[string]string{"shared": "true"}},
+		},
+	}}}
+	ts.p.client.(*exoscaleClientMock).
+		On("GetLoadBalancer", withAPICaller(ts.p.ctx, apiCallerService), testNLBID).
+		Return(otherNLB, nil)
+	ts.p.client.(*exoscaleClientMock).
+		On("DeleteLoadBalancerService", mock.Anything, testNLBID, testNLBServiceID).
+		Return(&v3.Operation{}, nil)
+
+	// The NLB service is left untouched, the deletion failing (keeping the
+	// Service finalizer) as long as it is.
+	err := ts.p.loadBalancer.EnsureLoadBalancerDeleted(ts.p.ctx, "", service)
+	ts.Require().ErrorIs(err, errLoadBalancerPolicyRejected)
+	ts.p.client.(*exoscaleClientMock).AssertNotCalled(ts.T(), "DeleteLoadBalancerService",
+		mock.Anything, mock.Anything, mock.Anything)
+
+	events := ts.p.recorder.(*record.FakeRecorder).Events
+	ts.Require().Len(events, 1)
+	ts.Require().Contains(<-events, "Warning "+eventReasonNLBPolicyRejected)
+
+	// NLB services named after the Service UID have necessarily been created
+	// for it (e.g. before the policy was configured): they are deleted,
+	// leaving the NLB instance untouched.
+	otherNLB.Services[0].Name = fmt.Sprintf("%s-%d", service.UID, 80)
+	ts.Require().NoError(ts.p.loadBalancer.EnsureLoadBalancerDeleted(ts.p.ctx, "", service))
+	ts.p.client.(*exoscaleClientMock).AssertCalled(ts.T(), "DeleteLoadBalancerService",
+		mock.Anything, testNLBID, testNLBServiceID)
+	ts.p.client.(*exoscaleClientMock).AssertNotCalled(ts.T(), "DeleteLoadBalancer", mock.Anything, mock.Anything)
+}
+
+func (ts *exoscaleCCMTestSuite) Test_loadBalancer_updateLoadBalancer_labelLegacyOwned() {
+	var (
+		serviceUID = ts.randomID()
+		service    = &v1.Service{
+			ObjectMeta: metav1.ObjectMeta{
+				UID: types.UID(serviceUID),
+				Annotations: map[string]string{
+					annotationLoadBalancerID:   testNLBID.String(),
+					annotationLoadBalancerName: "web",
+				},
+			},
+		}
+
+		// An NLB instance created for the Service by a CCM version predating
+		// the ownership label, only recognized by its default name.
+		legacyNLB = &v3.LoadBalancer{ID: testNLBID, Name: "k8s-" + serviceUID, Labels: v3.Labels{"team": "web"}}
+	)
+
+	ts.p.client.(*exoscaleClientMock).
+		On("GetLoadBalancer", ts.p.ctx, testNLBID).
+		Return(legacyNLB, nil)
+	ts.p.client.(*exoscaleClientMock).
+		On("UpdateLoadBalancer", ts.p.ctx, testNLBID, v3.UpdateLoadBalancerRequest{
+			Name:   "web",
+			Labels: v3.Labels{"team": "web", nlbLabelServiceUID: serviceUID},
+		}).
+		Return(&v3.Operation{}, nil)
+
+	// The NLB instance is labeled along with its renaming, remaining owned
+	// by the Service afterwards.
+	ts.Require().NoError(ts.p.loadBalancer.(*loadBalancer).updateLoadBalancer(ts.p.ctx, "", service))
+	ts.p.client.(*exoscaleClientMock).AssertNumberOfCalls(ts.T(), "UpdateLoadBalancer", 1)
+}
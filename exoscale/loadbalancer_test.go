@@ -1,6 +1,7 @@
 package exoscale
 
 import (
+	"errors"
 	"fmt"
 	"net"
 	"reflect"
@@ -13,11 +14,13 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/record"
 
 	v3 "github.com/exoscale/egoscale/v3"
 )
 
 var (
+	testClusterUID                                                          = new(exoscaleCCMTestSuite).randomID()
 	testNLBCreatedAt                                                        = time.Now().UTC()
 	testNLBDescription                                                      = new(exoscaleCCMTestSuite).randomString(10)
 	testNLBID                         v3.UUID                               = v3.UUID(new(exoscaleCCMTestSuite).randomID())
@@ -39,8 +42,8 @@ var (
 )
 
 func (ts *exoscaleCCMTestSuite) Test_newLoadBalancer() {
-	actual := newLoadBalancer(ts.p, &testConfig_typical.LoadBalancer)
-	ts.Require().Equal(&loadBalancer{p: ts.p, cfg: &testConfig_typical.LoadBalancer}, actual)
+	actual := newLoadBalancer(ts.p)
+	ts.Require().Equal(&loadBalancer{p: ts.p}, actual)
 }
 
 func (ts *exoscaleCCMTestSuite) Test_loadBalancer_isExternal() {
@@ -101,6 +104,72 @@ func (ts *exoscaleCCMTestSuite) Test_loadBalancer_isExternal() {
 	}
 }
 
+func (ts *exoscaleCCMTestSuite) Test_loadBalancer_isRetainOnDelete() {
+	type args struct {
+		service *v1.Service
+		cfg     loadBalancerConfig
+	}
+
+	tests := []struct {
+		name string
+		args args
+		want bool
+	}{
+		{
+			name: "true (annotation)",
+			args: args{
+				service: &v1.Service{
+					ObjectMeta: metav1.ObjectMeta{
+						Annotations: map[string]string{
+							annotationLoadBalancerRetainOnDelete: "true",
+						},
+					},
+				},
+			},
+			want: true,
+		},
+		{
+			name: "true (cloud-config)",
+			args: args{
+				service: &v1.Service{},
+				cfg:     loadBalancerConfig{RetainOnDelete: true},
+			},
+			want: true,
+		},
+		{
+			name: "false (annotation overrides cloud-config)",
+			args: args{
+				service: &v1.Service{
+					ObjectMeta: metav1.ObjectMeta{
+						Annotations: map[string]string{
+							annotationLoadBalancerRetainOnDelete: "false",
+						},
+					},
+				},
+				cfg: loadBalancerConfig{RetainOnDelete: true},
+			},
+			want: false,
+		},
+		{
+			name: "false (default)",
+			args: args{
+				service: &v1.Service{},
+			},
+			want: false,
+		},
+	}
+
+	for _, tt := range tests {
+		ts.T().Run(tt.name, func(_ *testing.T) {
+			ts.p.cfg = &cloudConfig{LoadBalancer: tt.args.cfg}
+			l := loadBalancer{p: ts.p}
+			if got := l.isRetainOnDelete(tt.args.service); got != tt.want {
+				ts.T().Errorf("isRetainOnDelete() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
 func (ts *exoscaleCCMTestSuite) Test_loadBalancer_EnsureLoadBalancer_create() {
 	var (
 		k8sServiceUID                 = ts.randomID()
@@ -165,11 +234,11 @@ func (ts *exoscaleCCMTestSuite) Test_loadBalancer_EnsureLoadBalancer_create() {
 	)
 
 	ts.p.client.(*exoscaleClientMock).
-		On("ListLoadBalancers", ts.p.ctx).
+		On("ListLoadBalancers", withAPICaller(ts.p.ctx, apiCallerService)).
 		Return(&v3.ListLoadBalancersResponse{}, nil)
 
 	ts.p.client.(*exoscaleClientMock).
-		On("GetInstance", ts.p.ctx, testInstanceID).
+		On("GetInstance", withAPICaller(ts.p.ctx, apiCallerService), testInstanceID).
 		Return(&v3.Instance{
 			ID: testInstanceID,
 			Manager: &v3.Manager{
@@ -179,12 +248,13 @@ func (ts *exoscaleCCMTestSuite) Test_loadBalancer_EnsureLoadBalancer_create() {
 		}, nil)
 
 	ts.p.client.(*exoscaleClientMock).
-		On("CreateLoadBalancer", ts.p.ctx, mock.Anything).
+		On("CreateLoadBalancer", withAPICaller(ts.p.ctx, apiCallerService), mock.Anything).
 		Run(func(args mock.Arguments) {
 			nlbCreated = true
 			ts.Require().Equal(args.Get(1), v3.CreateLoadBalancerRequest{
 				Name:        testNLBName,
 				Description: testNLBDescription,
+				Labels:      v3.Labels{nlbLabelServiceUID: k8sServiceUID},
 			})
 		}).
 		Return(&v3.Operation{
@@ -194,7 +264,7 @@ func (ts *exoscaleCCMTestSuite) Test_loadBalancer_EnsureLoadBalancer_create() {
 		}, nil)
 
 	ts.p.client.(*exoscaleClientMock).
-		On("GetLoadBalancer", ts.p.ctx, testNLBID).
+		On("GetLoadBalancer", withAPICaller(ts.p.ctx, apiCallerService), testNLBID).
 		Return(&v3.LoadBalancer{
 			Description: testNLBDescription,
 			ID:          testNLBID,
@@ -203,7 +273,7 @@ func (ts *exoscaleCCMTestSuite) Test_loadBalancer_EnsureLoadBalancer_create() {
 		}, nil).Times(2)
 
 	ts.p.client.(*exoscaleClientMock).
-		On("AddServiceToLoadBalancer", ts.p.ctx, mock.Anything, mock.Anything).
+		On("AddServiceToLoadBalancer", withAPICaller(ts.p.ctx, apiCallerService), mock.Anything, mock.Anything).
 		Run(func(args mock.Arguments) {
 			nlbServiceCreated = true
 			ts.Require().Equal(args.Get(2), expectedNLBServiceRequest)
@@ -215,7 +285,7 @@ func (ts *exoscaleCCMTestSuite) Test_loadBalancer_EnsureLoadBalancer_create() {
 		}, nil)
 
 	ts.p.client.(*exoscaleClientMock).
-		On("GetLoadBalancer", ts.p.ctx, testNLBID).
+		On("GetLoadBalancer", withAPICaller(ts.p.ctx, apiCallerService), testNLBID).
 		Return(&v3.LoadBalancer{
 			Description: testNLBDescription,
 			ID:          testNLBID,
@@ -268,6 +338,109 @@ func (ts *exoscaleCCMTestSuite) Test_loadBalancer_EnsureLoadBalancer_create() {
 	ts.Require().Error(err)
 }
 
+func (ts *exoscaleCCMTestSuite) Test_loadBalancer_EnsureLoadBalancer_create_idempotent() {
+	k8sServiceUID := ts.randomID()
+
+	tests := []struct {
+		name string
+		nlb  v3.LoadBalancer
+
+		// labeled is true if the ownership label has to be set on the NLB.
+		labeled bool
+	}{
+		{
+			// The CCM crashed after the NLB creation but before its ID was
+			// recorded in the Service annotations.
+			name: "crash before ID annotation (ownership label)",
+			nlb: v3.LoadBalancer{
+				ID:     testNLBID,
+				IP:     testNLBIPaddressP,
+				Name:   testNLBName,
+				Labels: v3.Labels{nlbLabelServiceUID: k8sServiceUID},
+			},
+		},
+		{
+			// Same as above, with an NLB created by a CCM version not setting
+			// ownership labels.
+			name: "crash before ID annotation (default name)",
+			nlb: v3.LoadBalancer{
+				ID:   testNLBID,
+				IP:   testNLBIPaddressP,
+				Name: "k8s-" + k8sServiceUID,
+			},
+			labeled: true,
+		},
+		{
+			// The CCM crashed after having adopted a retained NLB but before its
+			// ID was recorded in the Service annotations.
+			name: "crash before ID annotation (adopted retained NLB)",
+			nlb: v3.LoadBalancer{
+				ID:   testNLBID,
+				IP:   testNLBIPaddressP,
+				Name: testNLBName,
+				Labels: v3.Labels{
+					nlbLabelServiceUID:       k8sServiceUID,
+					nlbLabelServiceNamespace: metav1.NamespaceDefault,
+					nlbLabelServiceName:      "test",
+				},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		ts.Run(tt.name, func() {
+			ts.SetupTest()
+
+			service := &v1.Service{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "test",
+					Namespace: metav1.NamespaceDefault,
+					UID:       types.UID(k8sServiceUID),
+					Annotations: map[string]string{
+						annotationLoadBalancerName:                  tt.nlb.Name,
+						annotationLoadBalancerServiceInstancePoolID: testNLBServiceInstancePoolID.String(),
+					},
+				},
+			}
+
+			ts.p.client.(*exoscaleClientMock).
+				On("ListLoadBalancers", withAPICaller(ts.p.ctx, apiCallerService)).
+				Return(&v3.ListLoadBalancersResponse{LoadBalancers: []v3.LoadBalancer{
+					{ID: v3.UUID(ts.randomID()), Name: ts.randomString(10)},
+					tt.nlb,
+				}}, nil)
+
+			ts.p.client.(*exoscaleClientMock).
+				On("GetLoadBalancer", withAPICaller(ts.p.ctx, apiCallerService), testNLBID).
+				Return(&tt.nlb, nil)
+
+			if tt.labeled {
+				ts.p.client.(*exoscaleClientMock).
+					On("UpdateLoadBalancer", withAPICaller(ts.p.ctx, apiCallerService), testNLBID,
+						v3.UpdateLoadBalancerRequest{
+							Name:   tt.nlb.Name,
+							Labels: v3.Labels{nlbLabelServiceUID: k8sServiceUID},
+						}).
+					Return(&v3.Operation{}, nil).
+					Once()
+			}
+
+			ts.p.kclient = fake.NewSimpleClientset(service)
+
+			status, err := ts.p.loadBalancer.EnsureLoadBalancer(ts.p.ctx, "", service, nil)
+			ts.Require().NoError(err)
+			ts.Require().Equal(testNLBIPaddress, status.Ingress[0].IP)
+			ts.Require().Equal(testNLBID.String(), service.Annotations[annotationLoadBalancerID])
+			ts.p.client.(*exoscaleClientMock).AssertNotCalled(ts.T(), "CreateLoadBalancer", ts.p.ctx, mock.Anything)
+			if tt.labeled {
+				ts.p.client.(*exoscaleClientMock).AssertNumberOfCalls(ts.T(), "UpdateLoadBalancer", 1)
+			} else {
+				ts.p.client.(*exoscaleClientMock).AssertNotCalled(ts.T(), "UpdateLoadBalancer", mock.Anything, mock.Anything, mock.Anything)
+			}
+		})
+	}
+}
+
 func (ts *exoscaleCCMTestSuite) Test_loadBalancer_EnsureLoadBalancer_reuse() {
 	var (
 		k8sServiceUID                 = ts.randomID()
@@ -326,7 +499,7 @@ func (ts *exoscaleCCMTestSuite) Test_loadBalancer_EnsureLoadBalancer_reuse() {
 	)
 
 	ts.p.client.(*exoscaleClientMock).
-		On("GetInstance", ts.p.ctx, testInstanceID).
+		On("GetInstance", withAPICaller(ts.p.ctx, apiCallerService), testInstanceID).
 		Return(&v3.Instance{
 			ID: testInstanceID,
 			Manager: &v3.Manager{
@@ -336,7 +509,7 @@ func (ts *exoscaleCCMTestSuite) Test_loadBalancer_EnsureLoadBalancer_reuse() {
 		}, nil)
 
 	ts.p.client.(*exoscaleClientMock).
-		On("GetLoadBalancer", ts.p.ctx, testNLBID).
+		On("GetLoadBalancer", withAPICaller(ts.p.ctx, apiCallerService), testNLBID).
 		Return(&v3.LoadBalancer{
 			Description: testNLBDescription,
 			ID:          testNLBID,
@@ -346,7 +519,7 @@ func (ts *exoscaleCCMTestSuite) Test_loadBalancer_EnsureLoadBalancer_reuse() {
 		Times(2)
 
 	ts.p.client.(*exoscaleClientMock).
-		On("AddServiceToLoadBalancer", ts.p.ctx, mock.Anything, mock.Anything).
+		On("AddServiceToLoadBalancer", withAPICaller(ts.p.ctx, apiCallerService), mock.Anything, mock.Anything).
 		Run(func(args mock.Arguments) {
 			nlbServiceCreated = true
 			ts.Require().Equal(args.Get(2), expectedNLBServiceRequest)
@@ -358,7 +531,7 @@ func (ts *exoscaleCCMTestSuite) Test_loadBalancer_EnsureLoadBalancer_reuse() {
 		}, nil)
 
 	ts.p.client.(*exoscaleClientMock).
-		On("GetLoadBalancer", ts.p.ctx, testNLBID).
+		On("GetLoadBalancer", withAPICaller(ts.p.ctx, apiCallerService), testNLBID).
 		Return(&v3.LoadBalancer{
 			Description: testNLBDescription,
 			ID:          testNLBID,
@@ -433,11 +606,11 @@ func (ts *exoscaleCCMTestSuite) Test_loadBalancer_EnsureLoadBalancerDeleted() {
 	)
 
 	ts.p.client.(*exoscaleClientMock).
-		On("GetLoadBalancer", ts.p.ctx, testNLBID).
+		On("GetLoadBalancer", withAPICaller(ts.p.ctx, apiCallerService), testNLBID).
 		Return(expectedNLB, nil)
 
 	ts.p.client.(*exoscaleClientMock).
-		On("DeleteLoadBalancerService", ts.p.ctx, mock.Anything, mock.Anything).
+		On("DeleteLoadBalancerService", withAPICaller(ts.p.ctx, apiCallerService), mock.Anything, mock.Anything).
 		Run(func(args mock.Arguments) {
 			nlbServiceDeleted = true
 			ts.Require().Equal(args.Get(2), expectedNLB.Services[0].ID)
@@ -445,7 +618,7 @@ func (ts *exoscaleCCMTestSuite) Test_loadBalancer_EnsureLoadBalancerDeleted() {
 		Return(&v3.Operation{}, nil)
 
 	ts.p.client.(*exoscaleClientMock).
-		On("DeleteLoadBalancer", ts.p.ctx, mock.Anything).
+		On("DeleteLoadBalancer", withAPICaller(ts.p.ctx, apiCallerService), mock.Anything).
 		Run(func(args mock.Arguments) {
 			nlbDeleted = true
 			ts.Require().Equal(args.Get(1), expectedNLB.ID)
@@ -469,109 +642,455 @@ func (ts *exoscaleCCMTestSuite) Test_loadBalancer_EnsureLoadBalancerDeleted() {
 	ts.Require().False(nlbDeleted)
 }
 
-func (ts *exoscaleCCMTestSuite) Test_loadBalancer_GetLoadBalancer() {
-	expectedStatus := &v1.LoadBalancerStatus{
-		Ingress: []v1.LoadBalancerIngress{{IP: testNLBIPaddress}},
-	}
+func (ts *exoscaleCCMTestSuite) Test_loadBalancer_EnsureLoadBalancerDeleted_retain() {
+	var (
+		k8sServiceUID                 = ts.randomID()
+		k8sServicePortPort     uint16 = 80
+		k8sServicePortNodePort uint16 = 32672
+		nlbServicePortName            = fmt.Sprintf("%s-%d", k8sServiceUID, k8sServicePortPort)
+		nlbRetained                   = false
+		nlbServiceDeleted             = false
 
-	ts.p.client.(*exoscaleClientMock).
-		On("GetLoadBalancer", ts.p.ctx, testNLBID).
-		Return(&v3.LoadBalancer{
-			ID:   testNLBID,
-			IP:   testNLBIPaddressP,
-			Name: testNLBName,
-		}, nil)
+		expectedNLB = &v3.LoadBalancer{
+			ID:     testNLBID,
+			IP:     testNLBIPaddressP,
+			Name:   testNLBName,
+			Labels: v3.Labels{"team": "web"},
+			Services: []v3.LoadBalancerService{{
+				ID:       testNLBServiceID,
+				Name:     nlbServicePortName,
+				Port:     int64(k8sServicePortPort),
+				Protocol: v3.LoadBalancerServiceProtocolTCP,
+			}},
+		}
 
-	actualStatus, exists, err := ts.p.loadBalancer.GetLoadBalancer(ts.p.ctx, "", &v1.Service{
-		ObjectMeta: metav1.ObjectMeta{
-			Annotations: map[string]string{
-				annotationLoadBalancerID: testNLBID.String(),
+		service = &v1.Service{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "test",
+				Namespace: metav1.NamespaceDefault,
+				UID:       types.UID(k8sServiceUID),
+				Annotations: map[string]string{
+					annotationLoadBalancerID:             string(testNLBID),
+					annotationLoadBalancerRetainOnDelete: "true",
+				},
 			},
-		},
-	})
-	ts.Require().Equal(expectedStatus, actualStatus)
-	ts.Require().True(exists)
-	ts.Require().NoError(err)
+			Spec: v1.ServiceSpec{
+				Ports: []v1.ServicePort{{
+					Protocol: v1.ProtocolTCP,
+					Port:     int32(k8sServicePortPort),
+					NodePort: int32(k8sServicePortNodePort),
+				}},
+			},
+		}
+	)
 
-	// Non-existent NLB
+	ts.p.client.(*exoscaleClientMock).
+		On("GetLoadBalancer", withAPICaller(ts.p.ctx, apiCallerService), testNLBID).
+		Return(expectedNLB, nil)
 
 	ts.p.client.(*exoscaleClientMock).
-		On("GetLoadBalancer", ts.p.ctx, v3.UUID("lolnope")).
-		Return(&v3.LoadBalancer{}, errLoadBalancerNotFound)
+		On("DeleteLoadBalancerService", withAPICaller(ts.p.ctx, apiCallerService), testNLBID, testNLBServiceID).
+		Run(func(_ mock.Arguments) { nlbServiceDeleted = true }).
+		Return(&v3.Operation{}, nil)
 
-	_, exists, err = ts.p.loadBalancer.GetLoadBalancer(ts.p.ctx, "", &v1.Service{
-		ObjectMeta: metav1.ObjectMeta{
-			Annotations: map[string]string{
-				annotationLoadBalancerID: "lolnope",
-			},
-		},
-	})
-	ts.Require().False(exists)
+	ts.p.client.(*exoscaleClientMock).
+		On("UpdateLoadBalancer", withAPICaller(ts.p.ctx, apiCallerService), testNLBID, mock.Anything).
+		Run(func(args mock.Arguments) {
+			nlbRetained = true
+			ts.Require().Equal(v3.UpdateLoadBalancerRequest{
+				Labels: v3.Labels{
+					"team":                   "web",
+					nlbLabelServiceNamespace: service.Namespace,
+					nlbLabelServiceName:      service.Name,
+					nlbLabelClusterUID:       testClusterUID,
+					nlbLabelRetained:         "true",
+				},
+			}, args.Get(2))
+		}).
+		Return(&v3.Operation{}, nil)
+
+	ts.p.kclient = fake.NewSimpleClientset(service, testKubeSystemNamespace())
+
+	err := ts.p.loadBalancer.EnsureLoadBalancerDeleted(ts.p.ctx, "", service)
 	ts.Require().NoError(err)
+	ts.Require().True(nlbServiceDeleted)
+	ts.Require().True(nlbRetained)
+	ts.p.client.(*exoscaleClientMock).AssertNotCalled(ts.T(), "DeleteLoadBalancer", ts.p.ctx, testNLBID)
 }
 
-func (ts *exoscaleCCMTestSuite) Test_loadBalancer_GetLoadBalancerName() {
-	testServiceUID := ts.randomID()
-
-	type args struct {
-		service *v1.Service
-	}
+func (ts *exoscaleCCMTestSuite) Test_loadBalancer_EnsureLoadBalancer_adopt() {
+	var (
+		k8sServiceUID                 = ts.randomID()
+		k8sServicePortPort     uint16 = 80
+		k8sServicePortNodePort uint16 = 32672
+		nlbServicePortName            = fmt.Sprintf("%s-%d", k8sServiceUID, k8sServicePortPort)
+		nlbAdopted                    = false
 
-	tests := []struct {
-		name string
-		args args
-		want string
-	}{
-		{
-			name: "from annotations",
-			args: args{
-				service: &v1.Service{
-					ObjectMeta: metav1.ObjectMeta{
-						UID: types.UID(testServiceUID),
-						Annotations: map[string]string{
-							annotationLoadBalancerName: testNLBName,
-						},
-					},
+		service = &v1.Service{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "test",
+				Namespace: metav1.NamespaceDefault,
+				UID:       types.UID(k8sServiceUID),
+				Annotations: map[string]string{
+					annotationLoadBalancerServiceInstancePoolID: testNLBServiceInstancePoolID.String(),
 				},
 			},
-			want: testNLBName,
-		},
-		{
-			name: "from service UID (default)",
-			args: args{
-				service: &v1.Service{
-					ObjectMeta: metav1.ObjectMeta{
-						UID:         types.UID(testServiceUID),
-						Annotations: map[string]string{},
-					},
-				},
+			Spec: v1.ServiceSpec{
+				Ports: []v1.ServicePort{{
+					Protocol: v1.ProtocolTCP,
+					Port:     int32(k8sServicePortPort),
+					NodePort: int32(k8sServicePortNodePort),
+				}},
 			},
-			want: "k8s-" + testServiceUID,
-		},
-	}
+		}
 
-	for _, tt := range tests {
-		ts.T().Run(tt.name, func(_ *testing.T) {
-			actual := ts.p.loadBalancer.GetLoadBalancerName(ts.p.ctx, "", tt.args.service)
-			if actual != tt.want {
-				ts.T().Errorf("GetLoadBalancerName() = %v, want %v", actual, tt.want)
-			}
-		})
-	}
-}
+		expectedStatus = &v1.LoadBalancerStatus{
+			Ingress: []v1.LoadBalancerIngress{{IP: testNLBIPaddress}},
+		}
+	)
 
-func (ts *exoscaleCCMTestSuite) Test_loadBalancer_UpdateLoadBalancer() {
-	ts.T().Skip("wraps loadBalancer.updateLoadBalancer()")
-}
+	ts.p.client.(*exoscaleClientMock).
+		On("ListLoadBalancers", withAPICaller(ts.p.ctx, apiCallerService)).
+		Return(&v3.ListLoadBalancersResponse{LoadBalancers: []v3.LoadBalancer{
+			{
+				ID:   v3.UUID(ts.randomID()),
+				Name: ts.randomString(10),
+				Labels: v3.Labels{
+					nlbLabelServiceNamespace: "other",
+					nlbLabelServiceName:      service.Name,
+					nlbLabelClusterUID:       testClusterUID,
+					nlbLabelRetained:         "true",
+				},
+			},
+			{
+				ID:   v3.UUID(ts.randomID()),
+				Name: ts.randomString(10),
+				Labels: v3.Labels{
+					nlbLabelServiceNamespace: service.Namespace,
+					nlbLabelServiceName:      service.Name,
+					nlbLabelClusterUID:       ts.randomID(),
+					nlbLabelRetained:         "true",
+				},
+			},
+			{
+				ID:   testNLBID,
+				IP:   testNLBIPaddressP,
+				Name: testNLBName,
+				Labels: v3.Labels{
+					nlbLabelServiceNamespace: service.Namespace,
+					nlbLabelServiceName:      service.Name,
+					nlbLabelClusterUID:       testClusterUID,
+					nlbLabelRetained:         "true",
+				},
+			},
+		}}, nil)
 
-func (ts *exoscaleCCMTestSuite) Test_loadBalancer_fetchLoadBalancer() {
-	expected := &v3.LoadBalancer{
-		ID:   testNLBID,
-		Name: testNLBName,
-	}
+	ts.p.client.(*exoscaleClientMock).
+		On("UpdateLoadBalancer", withAPICaller(ts.p.ctx, apiCallerService), testNLBID, mock.Anything).
+		Run(func(args mock.Arguments) {
+			nlbAdopted = true
+			ts.Require().Equal(v3.UpdateLoadBalancerRequest{
+				Name: "k8s-" + k8sServiceUID,
+				Labels: v3.Labels{
+					nlbLabelServiceUID:       k8sServiceUID,
+					nlbLabelServiceNamespace: service.Namespace,
+					nlbLabelServiceName:      service.Name,
+					nlbLabelClusterUID:       testClusterUID,
+				},
+			}, args.Get(2))
+		}).
+		Return(&v3.Operation{}, nil).
+		Once()
 
 	ts.p.client.(*exoscaleClientMock).
-		On("GetLoadBalancer", ts.p.ctx, testNLBID).
+		On("GetLoadBalancer", withAPICaller(ts.p.ctx, apiCallerService), testNLBID).
+		Return(&v3.LoadBalancer{
+			ID:     testNLBID,
+			IP:     testNLBIPaddressP,
+			Name:   "k8s-" + k8sServiceUID,
+			Labels: v3.Labels{nlbLabelServiceUID: k8sServiceUID},
+		}, nil).
+		Once()
+
+	ts.p.client.(*exoscaleClientMock).
+		On("AddServiceToLoadBalancer", withAPICaller(ts.p.ctx, apiCallerService), testNLBID, mock.Anything).
+		Return(&v3.Operation{}, nil)
+
+	ts.p.client.(*exoscaleClientMock).
+		On("GetLoadBalancer", withAPICaller(ts.p.ctx, apiCallerService), testNLBID).
+		Return(&v3.LoadBalancer{
+			ID:     testNLBID,
+			IP:     testNLBIPaddressP,
+			Name:   "k8s-" + k8sServiceUID,
+			Labels: v3.Labels{nlbLabelServiceUID: k8sServiceUID},
+			Services: []v3.LoadBalancerService{{
+				ID:   testNLBServiceID,
+				Name: nlbServicePortName,
+			}},
+		}, nil)
+
+	ts.p.kclient = fake.NewSimpleClientset(service, testKubeSystemNamespace())
+
+	status, err := ts.p.loadBalancer.EnsureLoadBalancer(ts.p.ctx, "", service, nil)
+	ts.Require().NoError(err)
+	ts.Require().Equal(expectedStatus, status)
+	ts.Require().True(nlbAdopted)
+	ts.Require().Equal(testNLBID.String(), service.Annotations[annotationLoadBalancerID])
+	ts.p.client.(*exoscaleClientMock).AssertNotCalled(ts.T(), "CreateLoadBalancer", ts.p.ctx, mock.Anything)
+}
+
+func (ts *exoscaleCCMTestSuite) Test_loadBalancer_findLoadBalancer_otherCluster() {
+	service := &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test",
+			Namespace: metav1.NamespaceDefault,
+			UID:       types.UID(ts.randomID()),
+		},
+	}
+
+	ts.p.client.(*exoscaleClientMock).
+		On("ListLoadBalancers", withAPICaller(ts.p.ctx, apiCallerService)).
+		Return(&v3.ListLoadBalancersResponse{LoadBalancers: []v3.LoadBalancer{{
+			ID:   testNLBID,
+			Name: testNLBName,
+			Labels: v3.Labels{
+				nlbLabelServiceNamespace: service.Namespace,
+				nlbLabelServiceName:      service.Name,
+				nlbLabelClusterUID:       ts.randomID(),
+				nlbLabelRetained:         "true",
+			},
+		}}}, nil)
+
+	ts.p.kclient = fake.NewSimpleClientset(service, testKubeSystemNamespace())
+
+	nlb, retained, err := ts.p.loadBalancer.(*loadBalancer).findLoadBalancer(withAPICaller(ts.p.ctx, apiCallerService), service)
+	ts.Require().NoError(err)
+	ts.Require().Nil(nlb)
+	ts.Require().False(retained)
+}
+
+func (ts *exoscaleCCMTestSuite) Test_loadBalancer_EnsureLoadBalancer_adopt_policyRejected() {
+	service := &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test",
+			Namespace: metav1.NamespaceDefault,
+			UID:       types.UID(ts.randomID()),
+			Annotations: map[string]string{
+				annotationLoadBalancerServiceInstancePoolID: testNLBServiceInstancePoolID.String(),
+			},
+		},
+		Spec: v1.ServiceSpec{
+			Ports: []v1.ServicePort{{Protocol: v1.ProtocolTCP, Port: 80, NodePort: 32672}},
+		},
+	}
+
+	ts.p.cfg = &cloudConfig{LoadBalancer: loadBalancerConfig{Policy: loadBalancerPolicyConfig{
+		loadBalancerPolicyRules: loadBalancerPolicyRules{
+			InstancePools: &loadBalancerPolicyMatch{IDs: []string{testNLBServiceInstancePoolID.String()}},
+		},
+	}}}
+
+	ts.p.client.(*exoscaleClientMock).
+		On("ListLoadBalancers", withAPICaller(ts.p.ctx, apiCallerService)).
+		Return(&v3.ListLoadBalancersResponse{LoadBalancers: []v3.LoadBalancer{{
+			ID:   testNLBID,
+			Name: testNLBName,
+			Labels: v3.Labels{
+				nlbLabelServiceNamespace: service.Namespace,
+				nlbLabelServiceName:      service.Name,
+				nlbLabelClusterUID:       testClusterUID,
+				nlbLabelRetained:         "true",
+			},
+		}}}, nil)
+
+	ts.p.kclient = fake.NewSimpleClientset(service, testKubeSystemNamespace())
+
+	_, err := ts.p.loadBalancer.EnsureLoadBalancer(ts.p.ctx, "", service, nil)
+	ts.Require().ErrorIs(err, errLoadBalancerPolicyRejected)
+	ts.p.client.(*exoscaleClientMock).AssertNotCalled(ts.T(), "UpdateLoadBalancer", mock.Anything, mock.Anything, mock.Anything)
+	ts.p.client.(*exoscaleClientMock).AssertNotCalled(ts.T(), "CreateLoadBalancer", mock.Anything, mock.Anything)
+}
+
+func testKubeSystemNamespace() *v1.Namespace {
+	return &v1.Namespace{ObjectMeta: metav1.ObjectMeta{
+		Name: metav1.NamespaceSystem,
+		UID:  types.UID(testClusterUID),
+	}}
+}
+
+func (ts *exoscaleCCMTestSuite) Test_loadBalancer_EnsureLoadBalancerDeleted_shared() {
+	var (
+		k8sServiceUID                 = ts.randomID()
+		k8sServicePortPort     uint16 = 80
+		k8sServicePortNodePort uint16 = 32672
+		nlbServiceOtherID             = v3.UUID(ts.randomID())
+		nlbServiceDeleted             = false
+
+		expectedNLB = &v3.LoadBalancer{
+			ID:   testNLBID,
+			Name: testNLBName,
+			Services: []v3.LoadBalancerService{
+				{
+					ID:       testNLBServiceID,
+					Name:     fmt.Sprintf("%s-%d", k8sServiceUID, k8sServicePortPort),
+					Port:     int64(k8sServicePortPort),
+					Protocol: v3.LoadBalancerServiceProtocolTCP,
+				},
+				{
+					ID:       nlbServiceOtherID,
+					Name:     fmt.Sprintf("%s-%d", ts.randomID(), 443),
+					Port:     443,
+					Protocol: v3.LoadBalancerServiceProtocolTCP,
+				},
+			},
+		}
+
+		service = &v1.Service{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "test",
+				Namespace: metav1.NamespaceDefault,
+				UID:       types.UID(k8sServiceUID),
+				Annotations: map[string]string{
+					annotationLoadBalancerID: string(testNLBID),
+				},
+			},
+			Spec: v1.ServiceSpec{
+				Ports: []v1.ServicePort{
+					{
+						Protocol: v1.ProtocolTCP,
+						Port:     int32(k8sServicePortPort),
+						NodePort: int32(k8sServicePortNodePort),
+					},
+					{
+						Protocol: v1.ProtocolTCP,
+						Port:     443,
+						NodePort: 32673,
+					},
+				},
+			},
+		}
+	)
+
+	ts.p.client.(*exoscaleClientMock).
+		On("GetLoadBalancer", withAPICaller(ts.p.ctx, apiCallerService), testNLBID).
+		Return(expectedNLB, nil)
+
+	ts.p.client.(*exoscaleClientMock).
+		On("DeleteLoadBalancerService", withAPICaller(ts.p.ctx, apiCallerService), testNLBID, testNLBServiceID).
+		Run(func(_ mock.Arguments) { nlbServiceDeleted = true }).
+		Return(&v3.Operation{}, nil)
+
+	err := ts.p.loadBalancer.EnsureLoadBalancerDeleted(ts.p.ctx, "", service)
+	ts.Require().NoError(err)
+	ts.Require().True(nlbServiceDeleted)
+	ts.p.client.(*exoscaleClientMock).AssertNotCalled(ts.T(), "DeleteLoadBalancerService",
+		ts.p.ctx, testNLBID, nlbServiceOtherID)
+	ts.p.client.(*exoscaleClientMock).AssertNotCalled(ts.T(), "DeleteLoadBalancer", ts.p.ctx, testNLBID)
+}
+
+func (ts *exoscaleCCMTestSuite) Test_loadBalancer_GetLoadBalancer() {
+	expectedStatus := &v1.LoadBalancerStatus{
+		Ingress: []v1.LoadBalancerIngress{{IP: testNLBIPaddress}},
+	}
+
+	ts.p.client.(*exoscaleClientMock).
+		On("GetLoadBalancer", withAPICaller(ts.p.ctx, apiCallerService), testNLBID).
+		Return(&v3.LoadBalancer{
+			ID:   testNLBID,
+			IP:   testNLBIPaddressP,
+			Name: testNLBName,
+		}, nil)
+
+	actualStatus, exists, err := ts.p.loadBalancer.GetLoadBalancer(ts.p.ctx, "", &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Annotations: map[string]string{
+				annotationLoadBalancerID: testNLBID.String(),
+			},
+		},
+	})
+	ts.Require().Equal(expectedStatus, actualStatus)
+	ts.Require().True(exists)
+	ts.Require().NoError(err)
+
+	// Non-existent NLB
+
+	ts.p.client.(*exoscaleClientMock).
+		On("GetLoadBalancer", withAPICaller(ts.p.ctx, apiCallerService), v3.UUID("lolnope")).
+		Return(&v3.LoadBalancer{}, errLoadBalancerNotFound)
+
+	_, exists, err = ts.p.loadBalancer.GetLoadBalancer(ts.p.ctx, "", &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Annotations: map[string]string{
+				annotationLoadBalancerID: "lolnope",
+			},
+		},
+	})
+	ts.Require().False(exists)
+	ts.Require().NoError(err)
+}
+
+func (ts *exoscaleCCMTestSuite) Test_loadBalancer_GetLoadBalancerName() {
+	testServiceUID := ts.randomID()
+
+	type args struct {
+		service *v1.Service
+	}
+
+	tests := []struct {
+		name string
+		args args
+		want string
+	}{
+		{
+			name: "from annotations",
+			args: args{
+				service: &v1.Service{
+					ObjectMeta: metav1.ObjectMeta{
+						UID: types.UID(testServiceUID),
+						Annotations: map[string]string{
+							annotationLoadBalancerName: testNLBName,
+						},
+					},
+				},
+			},
+			want: testNLBName,
+		},
+		{
+			name: "from service UID (default)",
+			args: args{
+				service: &v1.Service{
+					ObjectMeta: metav1.ObjectMeta{
+						UID:         types.UID(testServiceUID),
+						Annotations: map[string]string{},
+					},
+				},
+			},
+			want: "k8s-" + testServiceUID,
+		},
+	}
+
+	for _, tt := range tests {
+		ts.T().Run(tt.name, func(_ *testing.T) {
+			actual := ts.p.loadBalancer.GetLoadBalancerName(ts.p.ctx, "", tt.args.service)
+			if actual != tt.want {
+				ts.T().Errorf("GetLoadBalancerName() = %v, want %v", actual, tt.want)
+			}
+		})
+	}
+}
+
+func (ts *exoscaleCCMTestSuite) Test_loadBalancer_UpdateLoadBalancer() {
+	ts.T().Skip("wraps loadBalancer.updateLoadBalancer()")
+}
+
+func (ts *exoscaleCCMTestSuite) Test_loadBalancer_fetchLoadBalancer() {
+	expected := &v3.LoadBalancer{
+		ID:   testNLBID,
+		Name: testNLBName,
+	}
+
+	ts.p.client.(*exoscaleClientMock).
+		On("GetLoadBalancer", ts.p.ctx, testNLBID).
 		Return(expected, nil)
 
 	actual, err := ts.p.loadBalancer.(*loadBalancer).fetchLoadBalancer(ts.p.ctx, &v1.Service{
@@ -777,7 +1296,7 @@ func (ts *exoscaleCCMTestSuite) Test_loadBalancer_updateLoadBalancer_create() {
 			},
 		}, nil)
 
-	ts.Require().NoError(ts.p.loadBalancer.(*loadBalancer).updateLoadBalancer(ts.p.ctx, service))
+	ts.Require().NoError(ts.p.loadBalancer.(*loadBalancer).updateLoadBalancer(ts.p.ctx, "", service))
 	ts.Require().True(created)
 }
 
@@ -881,7 +1400,7 @@ func (ts *exoscaleCCMTestSuite) Test_loadBalancer_updateLoadBalancer_update() {
 		}).
 		Return(&v3.Operation{}, nil)
 
-	ts.Require().NoError(ts.p.loadBalancer.(*loadBalancer).updateLoadBalancer(ts.p.ctx, service))
+	ts.Require().NoError(ts.p.loadBalancer.(*loadBalancer).updateLoadBalancer(ts.p.ctx, "", service))
 	ts.Require().True(updated)
 }
 
@@ -977,10 +1496,460 @@ func (ts *exoscaleCCMTestSuite) Test_loadBalancer_updateLoadBalancer_delete() {
 		}).
 		Return(&v3.Operation{}, nil)
 
-	ts.Require().NoError(ts.p.loadBalancer.(*loadBalancer).updateLoadBalancer(ts.p.ctx, service))
+	ts.Require().NoError(ts.p.loadBalancer.(*loadBalancer).updateLoadBalancer(ts.p.ctx, "", service))
 	ts.Require().True(deleted)
 }
 
+func (ts *exoscaleCCMTestSuite) Test_loadBalancer_updateLoadBalancer_rollback() {
+	var (
+		k8sServiceUID                 = ts.randomID()
+		k8sServicePortPort     uint16 = 80
+		k8sServicePortNodePort uint16 = 32672
+		nlbServicePortName            = fmt.Sprintf("%s-%d", k8sServiceUID, k8sServicePortPort)
+		nlbServiceNewPoolID           = v3.UUID(ts.randomID())
+		nlbServiceRestored            = false
+
+		currentNLBService = v3.LoadBalancerService{
+			Healthcheck: &v3.LoadBalancerServiceHealthcheck{
+				Mode:     defaultNLBServiceHealthcheckMode,
+				Port:     int64(k8sServicePortNodePort),
+				Interval: 10,
+				Timeout:  5,
+				Retries:  defaultNLBServiceHealthcheckRetries,
+			},
+			InstancePool: &v3.InstancePool{ID: testNLBServiceInstancePoolID},
+			ID:           testNLBServiceID,
+			Name:         nlbServicePortName,
+			Port:         int64(k8sServicePortPort),
+			Protocol:     testNLBServiceProtocol,
+			Strategy:     testNLBServiceStrategy,
+			TargetPort:   int64(k8sServicePortNodePort),
+		}
+
+		service = &v1.Service{
+			ObjectMeta: metav1.ObjectMeta{
+				UID: types.UID(k8sServiceUID),
+				Annotations: map[string]string{
+					annotationLoadBalancerID:                    testNLBID.String(),
+					annotationLoadBalancerName:                  testNLBName,
+					annotationLoadBalancerServiceInstancePoolID: nlbServiceNewPoolID.String(),
+				},
+			},
+			Spec: v1.ServiceSpec{
+				Ports: []v1.ServicePort{{
+					Protocol: v1.ProtocolTCP,
+					Port:     int32(k8sServicePortPort),
+					NodePort: int32(k8sServicePortNodePort),
+				}},
+			},
+		}
+	)
+
+	ts.p.client.(*exoscaleClientMock).
+		On("GetLoadBalancer", ts.p.ctx, testNLBID).
+		Return(&v3.LoadBalancer{
+			ID:       testNLBID,
+			Name:     testNLBName,
+			Services: []v3.LoadBalancerService{currentNLBService},
+		}, nil).
+		Once()
+
+	ts.p.client.(*exoscaleClientMock).
+		On("DeleteLoadBalancerService", ts.p.ctx, testNLBID, testNLBServiceID).
+		Return(&v3.Operation{}, nil)
+
+	ts.p.client.(*exoscaleClientMock).
+		On("AddServiceToLoadBalancer", ts.p.ctx, testNLBID, mock.MatchedBy(
+			func(req v3.AddServiceToLoadBalancerRequest) bool {
+				return req.InstancePool.ID == nlbServiceNewPoolID
+			})).
+		Return((*v3.Operation)(nil), errors.New("quota exceeded"))
+
+	// The new NLB service doesn't exist once its creation failed.
+	ts.p.client.(*exoscaleClientMock).
+		On("GetLoadBalancer", ts.p.ctx, testNLBID).
+		Return(&v3.LoadBalancer{ID: testNLBID, Name: testNLBName}, nil).
+		Once()
+
+	ts.p.client.(*exoscaleClientMock).
+		On("GetLoadBalancer", ts.p.ctx, testNLBID).
+		Return(&v3.LoadBalancer{
+			ID:       testNLBID,
+			Name:     testNLBName,
+			Services: []v3.LoadBalancerService{currentNLBService},
+		}, nil)
+
+	ts.p.client.(*exoscaleClientMock).
+		On("AddServiceToLoadBalancer", ts.p.ctx, testNLBID, mock.MatchedBy(
+			func(req v3.AddServiceToLoadBalancerRequest) bool {
+				return req.InstancePool.ID == testNLBServiceInstancePoolID
+			})).
+		Run(func(args mock.Arguments) {
+			nlbServiceRestored = true
+			ts.Require().Equal(v3.AddServiceToLoadBalancerRequest{
+				Healthcheck:  currentNLBService.Healthcheck,
+				InstancePool: &v3.InstancePool{ID: testNLBServiceInstancePoolID},
+				Name:         nlbServicePortName,
+				Port:         int64(k8sServicePortPort),
+				Protocol:     v3.AddServiceToLoadBalancerRequestProtocol(testNLBServiceProtocol),
+				Strategy:     v3.AddServiceToLoadBalancerRequestStrategy(testNLBServiceStrategy),
+				TargetPort:   int64(k8sServicePortNodePort),
+			}, args.Get(2))
+		}).
+		Return(&v3.Operation{}, nil)
+
+	err := ts.p.loadBalancer.(*loadBalancer).updateLoadBalancer(ts.p.ctx, "", service)
+	ts.Require().ErrorContains(err, "quota exceeded")
+	ts.Require().True(nlbServiceRestored)
+
+	events := ts.p.recorder.(*record.FakeRecorder).Events
+	ts.Require().Len(events, 1)
+	ts.Require().Contains(<-events, "Warning "+eventReasonNLBServiceRolledBack)
+}
+
+func (ts *exoscaleCCMTestSuite) Test_loadBalancer_updateLoadBalancer_noRollbackOnceCreated() {
+	var (
+		k8sServiceUID                 = ts.randomID()
+		k8sServicePortPort     uint16 = 80
+		k8sServicePortNodePort uint16 = 32672
+		nlbServicePortName            = fmt.Sprintf("%s-%d", k8sServiceUID, k8sServicePortPort)
+		nlbServiceNewPoolID           = v3.UUID(ts.randomID())
+
+		service = &v1.Service{
+			ObjectMeta: metav1.ObjectMeta{
+				UID: types.UID(k8sServiceUID),
+				Annotations: map[string]string{
+					annotationLoadBalancerID:                    testNLBID.String(),
+					annotationLoadBalancerName:                  testNLBName,
+					annotationLoadBalancerServiceInstancePoolID: nlbServiceNewPoolID.String(),
+				},
+			},
+			Spec: v1.ServiceSpec{
+				Ports: []v1.ServicePort{{
+					Protocol: v1.ProtocolTCP,
+					Port:     int32(k8sServicePortPort),
+					NodePort: int32(k8sServicePortNodePort),
+				}},
+			},
+		}
+	)
+
+	ts.p.client.(*exoscaleClientMock).
+		On("GetLoadBalancer", ts.p.ctx, testNLBID).
+		Return(&v3.LoadBalancer{
+			ID:   testNLBID,
+			Name: testNLBName,
+			Services: []v3.LoadBalancerService{{
+				InstancePool: &v3.InstancePool{ID: testNLBServiceInstancePoolID},
+				ID:           testNLBServiceID,
+				Name:         nlbServicePortName,
+				Port:         int64(k8sServicePortPort),
+				Protocol:     testNLBServiceProtocol,
+				Strategy:     testNLBServiceStrategy,
+				TargetPort:   int64(k8sServicePortNodePort),
+			}},
+		}, nil).
+		Once()
+
+	ts.p.client.(*exoscaleClientMock).
+		On("DeleteLoadBalancerService", ts.p.ctx, testNLBID, testNLBServiceID).
+		Return(&v3.Operation{}, nil)
+
+	// The new NLB service is created, but it can't be read back.
+	ts.p.client.(*exoscaleClientMock).
+		On("AddServiceToLoadBalancer", ts.p.ctx, testNLBID, mock.Anything).
+		Return(&v3.Operation{}, nil)
+	ts.p.client.(*exoscaleClientMock).
+		On("GetLoadBalancer", ts.p.ctx, testNLBID).
+		Return((*v3.LoadBalancer)(nil), errors.New("service unavailable"))
+
+	err := ts.p.loadBalancer.(*loadBalancer).updateLoadBalancer(ts.p.ctx, "", service)
+	ts.Require().ErrorContains(err, "service unavailable")
+	ts.Require().NotErrorIs(err, errLoadBalancerServiceNotAdded)
+
+	// The previous definition isn't restored on the port now taken.
+	ts.p.client.(*exoscaleClientMock).AssertNumberOfCalls(ts.T(), "AddServiceToLoadBalancer", 1)
+	ts.Require().Empty(ts.p.recorder.(*record.FakeRecorder).Events)
+}
+
+func (ts *exoscaleCCMTestSuite) Test_loadBalancer_addLoadBalancerService() {
+	var (
+		nlb        = &v3.LoadBalancer{ID: testNLBID, Name: testNLBName}
+		nlbService = v3.LoadBalancerService{Name: testNLBServiceName, Port: 80}
+		nlbAbsent  = &v3.LoadBalancer{ID: testNLBID, Name: testNLBName}
+		nlbPresent = &v3.LoadBalancer{ID: testNLBID, Name: testNLBName, Services: []v3.LoadBalancerService{
+			{ID: testNLBServiceID, Name: testNLBServiceName, Port: 80},
+		}}
+		errAPI = errors.New("service unavailable")
+	)
+
+	type getResult struct {
+		nlb *v3.LoadBalancer
+		err error
+	}
+
+	tests := []struct {
+		name     string
+		addErr   error
+		gets     []getResult
+		notAdded bool
+	}{
+		{
+			name:     "created",
+			gets:     []getResult{{nlb: nlbPresent}},
+			notAdded: false,
+		},
+		{
+			name:     "rejected",
+			addErr:   errAPI,
+			gets:     []getResult{{nlb: nlbAbsent}},
+			notAdded: true,
+		},
+		{
+			name:     "failed but created",
+			addErr:   errAPI,
+			gets:     []getResult{{nlb: nlbPresent}},
+			notAdded: false,
+		},
+		{
+			name:     "missing once created",
+			gets:     []getResult{{nlb: nlbAbsent}},
+			notAdded: true,
+		},
+		{
+			name:     "read back failed, missing",
+			gets:     []getResult{{err: errAPI}, {nlb: nlbAbsent}},
+			notAdded: true,
+		},
+		{
+			name:     "read back failed, unknown",
+			gets:     []getResult{{err: errAPI}, {err: errAPI}},
+			notAdded: false,
+		},
+	}
+
+	for _, tt := range tests {
+		ts.Run(tt.name, func() {
+			client := new(exoscaleClientMock)
+			ts.p.client = client
+
+			var op *v3.Operation
+			if tt.addErr == nil {
+				op = &v3.Operation{}
+			}
+			client.
+				On("AddServiceToLoadBalancer", ts.p.ctx, testNLBID, mock.Anything).
+				Return(op, tt.addErr)
+			for _, get := range tt.gets {
+				client.
+					On("GetLoadBalancer", ts.p.ctx, testNLBID).
+					Return(get.nlb, get.err).
+					Once()
+			}
+
+			err := ts.p.loadBalancer.(*loadBalancer).addLoadBalancerService(ts.p.ctx, nlb, nlbService)
+			if tt.addErr == nil && tt.gets[0].nlb == nlbPresent {
+				ts.Require().NoError(err)
+			} else {
+				ts.Require().Error(err)
+				ts.Require().Equal(tt.notAdded, errors.Is(err, errLoadBalancerServiceNotAdded), err.Error())
+			}
+			client.AssertExpectations(ts.T())
+		})
+	}
+}
+
+func (ts *exoscaleCCMTestSuite) Test_loadBalancer_updateLoadBalancer_conflict() {
+	var (
+		k8sServiceUID                 = ts.randomID()
+		k8sServicePortPort     uint16 = 80
+		k8sServicePortNodePort uint16 = 32672
+
+		currentNLB = &v3.LoadBalancer{
+			ID:   testNLBID,
+			IP:   testNLBIPaddressP,
+			Name: testNLBName,
+			Services: []v3.LoadBalancerService{{
+				ID:       testNLBServiceID,
+				Name:     fmt.Sprintf("%s-%d", ts.randomID(), k8sServicePortPort),
+				Port:     int64(k8sServicePortPort),
+				Protocol: testNLBServiceProtocol,
+			}},
+		}
+
+		service = &v1.Service{
+			ObjectMeta: metav1.ObjectMeta{
+				UID: types.UID(k8sServiceUID),
+				Annotations: map[string]string{
+					annotationLoadBalancerID:                    currentNLB.ID.String(),
+					annotationLoadBalancerName:                  currentNLB.Name,
+					annotationLoadBalancerServiceInstancePoolID: testNLBServiceInstancePoolID.String(),
+				},
+			},
+			Spec: v1.ServiceSpec{
+				Ports: []v1.ServicePort{{
+					Protocol: v1.ProtocolTCP,
+					Port:     int32(k8sServicePortPort),
+					NodePort: int32(k8sServicePortNodePort),
+				}},
+			},
+		}
+	)
+
+	ts.p.client.(*exoscaleClientMock).
+		On("GetLoadBalancer", ts.p.ctx, testNLBID).
+		Return(currentNLB, nil)
+
+	err := ts.p.loadBalancer.(*loadBalancer).updateLoadBalancer(ts.p.ctx, "", service)
+	ts.Require().ErrorIs(err, errLoadBalancerServiceConflict)
+	ts.p.client.(*exoscaleClientMock).AssertNotCalled(ts.T(), "UpdateLoadBalancerService",
+		ts.p.ctx, mock.Anything, mock.Anything, mock.Anything)
+	ts.p.client.(*exoscaleClientMock).AssertNotCalled(ts.T(), "DeleteLoadBalancerService",
+		ts.p.ctx, mock.Anything, mock.Anything)
+}
+
+func (ts *exoscaleCCMTestSuite) Test_loadBalancer_updateLoadBalancer_shared() {
+	var (
+		k8sServiceUID                 = ts.randomID()
+		k8sServicePortPort     uint16 = 80
+		k8sServicePortNodePort uint16 = 32672
+		nlbServiceStaleID             = v3.UUID(ts.randomID())
+		nlbServiceOtherID             = v3.UUID(ts.randomID())
+		nlbServiceCreated             = false
+		nlbServiceDeleted             = false
+
+		currentNLB = &v3.LoadBalancer{
+			ID:     testNLBID,
+			IP:     testNLBIPaddressP,
+			Name:   testNLBName,
+			Labels: v3.Labels{nlbLabelServiceUID: ts.randomID()},
+			Services: []v3.LoadBalancerService{
+				{
+					ID:       nlbServiceOtherID,
+					Name:     fmt.Sprintf("%s-%d", ts.randomID(), 443),
+					Port:     443,
+					Protocol: testNLBServiceProtocol,
+				},
+				{
+					ID:       nlbServiceStaleID,
+					Name:     fmt.Sprintf("%s-%d", k8sServiceUID, 8080),
+					Port:     8080,
+					Protocol: testNLBServiceProtocol,
+				},
+			},
+		}
+
+		service = &v1.Service{
+			ObjectMeta: metav1.ObjectMeta{
+				UID: types.UID(k8sServiceUID),
+				Annotations: map[string]string{
+					annotationLoadBalancerID:                    currentNLB.ID.String(),
+					annotationLoadBalancerServiceInstancePoolID: testNLBServiceInstancePoolID.String(),
+				},
+			},
+			Spec: v1.ServiceSpec{
+				Ports: []v1.ServicePort{{
+					Protocol: v1.ProtocolTCP,
+					Port:     int32(k8sServicePortPort),
+					NodePort: int32(k8sServicePortNodePort),
+				}},
+			},
+		}
+	)
+
+	ts.p.client.(*exoscaleClientMock).
+		On("GetLoadBalancer", ts.p.ctx, testNLBID).
+		Return(currentNLB, nil).
+		Once()
+
+	ts.p.client.(*exoscaleClientMock).
+		On("DeleteLoadBalancerService", ts.p.ctx, testNLBID, nlbServiceStaleID).
+		Run(func(_ mock.Arguments) { nlbServiceDeleted = true }).
+		Return(&v3.Operation{}, nil)
+
+	ts.p.client.(*exoscaleClientMock).
+		On("AddServiceToLoadBalancer", ts.p.ctx, testNLBID, mock.Anything).
+		Run(func(_ mock.Arguments) { nlbServiceCreated = true }).
+		Return(&v3.Operation{}, nil)
+
+	ts.p.client.(*exoscaleClientMock).
+		On("GetLoadBalancer", ts.p.ctx, testNLBID).
+		Return(&v3.LoadBalancer{
+			ID:   testNLBID,
+			Name: testNLBName,
+			Services: []v3.LoadBalancerService{{
+				ID:   testNLBServiceID,
+				Name: fmt.Sprintf("%s-%d", k8sServiceUID, k8sServicePortPort),
+			}},
+		}, nil)
+
+	ts.Require().NoError(ts.p.loadBalancer.(*loadBalancer).updateLoadBalancer(ts.p.ctx, "", service))
+	ts.Require().True(nlbServiceDeleted)
+	ts.Require().True(nlbServiceCreated)
+	// The NLB instance belongs to another Service, its name must be left untouched.
+	ts.p.client.(*exoscaleClientMock).AssertNotCalled(ts.T(), "UpdateLoadBalancer", ts.p.ctx, mock.Anything, mock.Anything)
+	ts.p.client.(*exoscaleClientMock).AssertNotCalled(ts.T(), "DeleteLoadBalancerService",
+		ts.p.ctx, testNLBID, nlbServiceOtherID)
+}
+
+func Test_ownsLoadBalancerService(t *testing.T) {
+	var (
+		serviceUID = new(exoscaleCCMTestSuite).randomID()
+		otherUID   = new(exoscaleCCMTestSuite).randomID()
+		service    = &v1.Service{ObjectMeta: metav1.ObjectMeta{UID: types.UID(serviceUID)}}
+		ownNLB     = &v3.LoadBalancer{Labels: v3.Labels{nlbLabelServiceUID: serviceUID}}
+		otherNLB   = &v3.LoadBalancer{Labels: v3.Labels{nlbLabelServiceUID: otherUID}}
+		lbSpec     = &v3.LoadBalancer{Services: []v3.LoadBalancerService{{Name: "custom"}}}
+	)
+
+	tests := []struct {
+		name       string
+		nlb        *v3.LoadBalancer
+		nlbService v3.LoadBalancerService
+		lbSpec     *v3.LoadBalancer
+		want       bool
+	}{
+		{
+			name:       "Service UID prefix",
+			nlb:        otherNLB,
+			nlbService: v3.LoadBalancerService{Name: serviceUID + "-80"},
+			want:       true,
+		},
+		{
+			name:       "other Service UID prefix",
+			nlb:        ownNLB,
+			nlbService: v3.LoadBalancerService{Name: otherUID + "-80"},
+			want:       false,
+		},
+		{
+			name:       "name from Service annotations",
+			nlb:        otherNLB,
+			nlbService: v3.LoadBalancerService{Name: "custom"},
+			lbSpec:     lbSpec,
+			want:       true,
+		},
+		{
+			name:       "unknown name on own NLB",
+			nlb:        ownNLB,
+			nlbService: v3.LoadBalancerService{Name: "legacy"},
+			want:       true,
+		},
+		{
+			name:       "unknown name on other NLB",
+			nlb:        otherNLB,
+			nlbService: v3.LoadBalancerService{Name: "legacy"},
+			lbSpec:     lbSpec,
+			want:       false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, ownsLoadBalancerService(service, tt.nlb, tt.nlbService, tt.lbSpec))
+		})
+	}
+}
+
 func Test_buildLoadBalancerFromAnnotations(t *testing.T) {
 	var (
 		serviceUID                      = "901a4773-b836-409d-9364-b855b7b38c22"
@@ -1075,7 +2044,7 @@ func Test_buildLoadBalancerFromAnnotations(t *testing.T) {
 		},
 	}
 
-	actual, err := buildLoadBalancerFromAnnotations(service)
+	actual, err := buildLoadBalancerFromAnnotations(service, &loadBalancerConfig{}, "")
 	require.NoError(t, err)
 	require.Equal(t, expected, actual)
 
@@ -1084,7 +2053,7 @@ func Test_buildLoadBalancerFromAnnotations(t *testing.T) {
 	expected.Services = expected.Services[:1]
 	expected.Services[0].Name = testNLBServiceName
 	expected.Services[0].Description = testNLBServiceDescription
-	actual, err = buildLoadBalancerFromAnnotations(service)
+	actual, err = buildLoadBalancerFromAnnotations(service, &loadBalancerConfig{}, "")
 	require.NoError(t, err)
 	require.Equal(t, expected, actual)
 
@@ -1095,11 +2064,71 @@ func Test_buildLoadBalancerFromAnnotations(t *testing.T) {
 	service.Annotations[annotationLoadBalancerServiceHealthCheckPort] = fmt.Sprint(serviceHealthCheckPort)
 	expected.Services[0].Protocol = testNLBServiceProtocolUDP
 	expected.Services[0].Healthcheck.Port = int64(serviceHealthCheckPort)
-	actual, err = buildLoadBalancerFromAnnotations(service)
+	actual, err = buildLoadBalancerFromAnnotations(service, &loadBalancerConfig{}, "")
 	require.NoError(t, err)
 	require.Equal(t, expected, actual)
 }
 
+func Test_buildLoadBalancerFromAnnotations_defaults(t *testing.T) {
+	config := &loadBalancerConfig{
+		Strategy: string(v3.LoadBalancerServiceStrategySourceHash),
+		HealthCheck: loadBalancerHealthCheckConfig{
+			Mode:     string(v3.LoadBalancerServiceHealthcheckModeHTTP),
+			Interval: 20 * time.Second,
+			Timeout:  3 * time.Second,
+			Retries:  3,
+		},
+		Labels:              map[string]string{"team": "web"},
+		NameTemplate:        "{{.ClusterName}}-{{.Namespace}}-{{.Name}}",
+		DescriptionTemplate: "Service {{.Namespace}}/{{.Name}} of cluster {{.ClusterName}}",
+	}
+
+	service := &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:   "shop",
+			Name:        "frontend",
+			UID:         types.UID(new(exoscaleCCMTestSuite).randomID()),
+			Annotations: map[string]string{},
+		},
+		Spec: v1.ServiceSpec{
+			Ports: []v1.ServicePort{{Protocol: v1.ProtocolTCP, Port: 80, NodePort: 32058}},
+		},
+	}
+
+	actual, err := buildLoadBalancerFromAnnotations(service, config, "prod")
+	require.NoError(t, err)
+	require.Equal(t, "prod-shop-frontend", actual.Name)
+	require.Equal(t, "Service shop/frontend of cluster prod", actual.Description)
+	require.Equal(t, v3.Labels{"team": "web"}, actual.Labels)
+	require.Equal(t, v3.LoadBalancerServiceStrategySourceHash, actual.Services[0].Strategy)
+	require.Equal(t, &v3.LoadBalancerServiceHealthcheck{
+		Mode:     v3.LoadBalancerServiceHealthcheckModeHTTP,
+		Port:     32058,
+		Interval: 20,
+		Timeout:  3,
+		Retries:  3,
+	}, actual.Services[0].Healthcheck)
+
+	// The Service annotations take precedence over the defaults.
+	service.Annotations = map[string]string{
+		annotationLoadBalancerName:                       testNLBName,
+		annotationLoadBalancerDescription:                testNLBDescription,
+		annotationLoadBalancerServiceStrategy:            string(v3.LoadBalancerServiceStrategyRoundRobin),
+		annotationLoadBalancerServiceHealthCheckMode:     string(v3.LoadBalancerServiceHealthcheckModeTCP),
+		annotationLoadBalancerServiceHealthCheckInterval: "30s",
+		annotationLoadBalancerServiceHealthCheckRetries:  "1",
+	}
+	actual, err = buildLoadBalancerFromAnnotations(service, config, "prod")
+	require.NoError(t, err)
+	require.Equal(t, testNLBName, actual.Name)
+	require.Equal(t, testNLBDescription, actual.Description)
+	require.Equal(t, v3.LoadBalancerServiceStrategyRoundRobin, actual.Services[0].Strategy)
+	require.Equal(t, v3.LoadBalancerServiceHealthcheckModeTCP, actual.Services[0].Healthcheck.Mode)
+	require.Equal(t, int64(30), actual.Services[0].Healthcheck.Interval)
+	require.Equal(t, int64(3), actual.Services[0].Healthcheck.Timeout)
+	require.Equal(t, int64(1), actual.Services[0].Healthcheck.Retries)
+}
+
 func Test_isLoadBalancerUpdated(t *testing.T) {
 	tests := []struct {
 		name      string
@@ -1125,6 +2154,18 @@ func Test_isLoadBalancerUpdated(t *testing.T) {
 			&v3.LoadBalancer{Name: testNLBName, Description: testNLBDescription},
 			require.True,
 		},
+		{
+			"other labels",
+			&v3.LoadBalancer{Name: testNLBName, Labels: v3.Labels{"team": "web", nlbLabelServiceUID: "x"}},
+			&v3.LoadBalancer{Name: testNLBName, Labels: v3.Labels{"team": "web"}},
+			require.False,
+		},
+		{
+			"labels updated",
+			&v3.LoadBalancer{Name: testNLBName, Labels: v3.Labels{"team": "web"}},
+			&v3.LoadBalancer{Name: testNLBName, Labels: v3.Labels{"team": "api"}},
+			require.True,
+		},
 	}
 
 	for _, tt := range tests {
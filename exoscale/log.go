@@ -12,6 +12,10 @@ func errorf(format string, args ...interface{}) {
 	klog.Errorf("exoscale-ccm: "+format, args...)
 }
 
+func warnf(format string, args ...interface{}) {
+	klog.Warningf("exoscale-ccm: "+format, args...)
+}
+
 func infof(format string, args ...interface{}) {
 	klog.Infof("exoscale-ccm: "+format, args...)
 }
@@ -0,0 +1,48 @@
+package exoscale
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+
+	"github.com/exoscale/egoscale/v3/metadata"
+)
+
+// getMetadata retrieves the value of the Exoscale metadata specified from the
+// metadata server, using the HTTP client specified (or the default one if
+// nil) so that the HTTP configuration (e.g. TLS, but not the proxy) is
+// honored.
+func getMetadata(ctx context.Context, client *http.Client, endpoint metadata.Endpoint) (string, error) {
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	u, err := url.JoinPath(metadata.MetaDataURL, string(endpoint))
+	if err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return "", err
+	}
+
+	res, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("unexpected metadata server response: %s", res.Status)
+	}
+
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return "", err
+	}
+
+	return string(body), nil
+}
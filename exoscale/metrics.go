@@ -0,0 +1,179 @@
+package exoscale
+
+import (
+	"sync"
+
+	"k8s.io/component-base/metrics"
+	"k8s.io/component-base/metrics/legacyregistry"
+)
+
+const metricsSubsystem = "exoscale_ccm"
+
+var (
+	metricAPICredentialsSource = metrics.NewGaugeVec(
+		&metrics.GaugeOpts{
+			Subsystem:      metricsSubsystem,
+			Name:           "api_credentials_source",
+			Help:           "Source of the Exoscale API credentials currently in use (1 for the active source, 0 otherwise).",
+			StabilityLevel: metrics.ALPHA,
+		},
+		[]string{"source"},
+	)
+
+	metricAPICredentialsHealthy = metrics.NewGauge(
+		&metrics.GaugeOpts{
+			Subsystem:      metricsSubsystem,
+			Name:           "api_credentials_healthy",
+			Help:           "Whether the latest Exoscale API credentials refresh succeeded (1) or not (0).",
+			StabilityLevel: metrics.ALPHA,
+		},
+	)
+
+	metricAPIRateLimiterWait = metrics.NewHistogramVec(
+		&metrics.HistogramOpts{
+			Subsystem:      metricsSubsystem,
+			Name:           "api_rate_limiter_wait_seconds",
+			Help:           "Time Exoscale API requests were delayed by the client-side rate limiter, per caller.",
+			Buckets:        []float64{0.01, 0.1, 0.5, 1, 2.5, 5, 10, 30},
+			StabilityLevel: metrics.ALPHA,
+		},
+		[]string{"caller"},
+	)
+
+	metricAPICircuitBreakerState = metrics.NewGauge(
+		&metrics.GaugeOpts{
+			Subsystem:      metricsSubsystem,
+			Name:           "api_circuit_breaker_state",
+			Help:           "State of the Exoscale API circuit breaker (0: closed, 1: half-open, 2: open).",
+			StabilityLevel: metrics.ALPHA,
+		},
+	)
+
+	metricAPIRequestsRejected = metrics.NewCounterVec(
+		&metrics.CounterOpts{
+			Subsystem:      metricsSubsystem,
+			Name:           "api_requests_rejected_total",
+			Help:           "Number of non-essential Exoscale API requests rejected by the open circuit breaker, per caller.",
+			StabilityLevel: metrics.ALPHA,
+		},
+		[]string{"caller"},
+	)
+
+	metricCloudConfigReloads = metrics.NewCounterVec(
+		&metrics.CounterOpts{
+			Subsystem:      metricsSubsystem,
+			Name:           "cloud_config_reloads_total",
+			Help:           "Number of cloud-config file reloads, per result (success or failure).",
+			StabilityLevel: metrics.ALPHA,
+		},
+		[]string{"result"},
+	)
+
+	metricSKSAgentNodeCSRsApproved = metrics.NewCounter(
+		&metrics.CounterOpts{
+			Subsystem:      metricsSubsystem,
+			Name:           "sks_agent_node_csrs_approved_total",
+			Help:           "Number of Node CSRs approved by the SKS agent.",
+			StabilityLevel: metrics.ALPHA,
+		},
+	)
+
+	metricSKSAgentNodeCSRsDenied = metrics.NewCounterVec(
+		&metrics.CounterOpts{
+			Subsystem:      metricsSubsystem,
+			Name:           "sks_agent_node_csrs_denied_total",
+			Help:           "Number of Node CSRs denied by the SKS agent, per reason.",
+			StabilityLevel: metrics.ALPHA,
+		},
+		[]string{"reason"},
+	)
+
+	metricSKSAgentNodeCSRsPending = metrics.NewGauge(
+		&metrics.GaugeOpts{
+			Subsystem:      metricsSubsystem,
+			Name:           "sks_agent_node_csrs_pending",
+			Help:           "Number of pending Node CSRs which failed validation by the SKS agent.",
+			StabilityLevel: metrics.ALPHA,
+		},
+	)
+
+	metricSKSAgentLeader = metrics.NewGauge(
+		&metrics.GaugeOpts{
+			Subsystem:      metricsSubsystem,
+			Name:           "sks_agent_leader",
+			Help:           "Whether this CCM replica is running the SKS agent runners as the elected leader (1) or not (0).",
+			StabilityLevel: metrics.ALPHA,
+		},
+	)
+
+	metricSKSAgentRunnerUp = metrics.NewGaugeVec(
+		&metrics.GaugeOpts{
+			Subsystem:      metricsSubsystem,
+			Name:           "sks_agent_runner_up",
+			Help:           "Whether the SKS agent runner is running on this CCM replica (1) or not (0), per runner.",
+			StabilityLevel: metrics.ALPHA,
+		},
+		[]string{"runner"},
+	)
+
+	metricSKSAgentRunnerRestarts = metrics.NewCounterVec(
+		&metrics.CounterOpts{
+			Subsystem:      metricsSubsystem,
+			Name:           "sks_agent_runner_restarts_total",
+			Help:           "Number of restarts of the SKS agent runners after they exited unexpectedly or panicked, per runner.",
+			StabilityLevel: metrics.ALPHA,
+		},
+		[]string{"runner"},
+	)
+
+	registerMetricsOnce sync.Once
+)
+
+// registerMetrics registers the Exoscale CCM metrics with the global
+// registry exposed by the cloud-controller-manager.
+func registerMetrics() {
+	registerMetricsOnce.Do(func() {
+		legacyregistry.MustRegister(
+			metricAPICredentialsSource,
+			metricAPICredentialsHealthy,
+			metricAPIRateLimiterWait,
+			metricAPICircuitBreakerState,
+			metricAPIRequestsRejected,
+			metricCloudConfigReloads,
+			metricSKSAgentNodeCSRsApproved,
+			metricSKSAgentNodeCSRsDenied,
+			metricSKSAgentNodeCSRsPending,
+			metricSKSAgentLeader,
+			metricSKSAgentRunnerUp,
+			metricSKSAgentRunnerRestarts,
+		)
+	})
+}
+
+// setAPICredentialsSource reports the source of the Exoscale API credentials
+// currently in use.
+func setAPICredentialsSource(source string) {
+	for _, s := range []string{
+		credentialsSourceConfig,
+		credentialsSourceFile,
+		credentialsSourceSecret,
+		credentialsSourceCLIConfig,
+	} {
+		v := 0.0
+		if s == source {
+			v = 1
+		}
+		metricAPICredentialsSource.WithLabelValues(s).Set(v)
+	}
+}
+
+// setAPICredentialsHealth reports whether the latest Exoscale API credentials
+// refresh succeeded.
+func setAPICredentialsHealth(healthy bool) {
+	if healthy {
+		metricAPICredentialsHealthy.Set(1)
+		return
+	}
+
+	metricAPICredentialsHealthy.Set(0)
+}
@@ -9,10 +9,10 @@ import (
 func (c *refreshableExoscaleClient) ListSKSClusters(
 	ctx context.Context,
 ) (*v3.ListSKSClustersResponse, error) {
-	c.RLock()
-	defer c.RUnlock()
+	ctx, cancel := c.withTimeout(ctx, "ListSKSClusters")
+	defer cancel()
 
-	return c.exo.ListSKSClusters(
+	return c.client().ListSKSClusters(
 		ctx,
 	)
 }
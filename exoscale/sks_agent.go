@@ -2,33 +2,491 @@ package exoscale
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"os"
+	"runtime/debug"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/uuid"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/tools/leaderelection"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+)
+
+// sksAgentRunnerShutdownTimeout is the delay the runners are given to shut
+// down once their context is cancelled.
+const sksAgentRunnerShutdownTimeout = 30 * time.Second
+
+// The backoff of the restarts of the runners exiting unexpectedly, reset once
+// a runner has been running for the maximum backoff.
+var (
+	sksAgentRunnerRestartMinBackoff = time.Second
+	sksAgentRunnerRestartMaxBackoff = 5 * time.Minute
 )
 
-const sksAgentNodeCSRValidation = "node-csr-validation"
+// sksAgentRunnerDependenciesPollInterval is the interval at which the health
+// of the dependencies of a runner is checked until they are all healthy.
+var sksAgentRunnerDependenciesPollInterval = time.Second
 
 // sksAgentRunner represents an SKS agent runner interface.
 type sksAgentRunner interface {
 	// run represents the runner execution loop, which will be running in a
-	// goroutine during cloud provider startup. The runner loop is expected
-	// to watch the provided context for cancellation, and shut down if
-	// signaled by ctx.Done().
+	// goroutine while the CCM replica is the SKS agent leader. The runner
+	// loop is expected to watch the provided context for cancellation, and
+	// shut down if signaled by ctx.Done(): returning (or panicking) before
+	// that is considered a failure, upon which the runner is restarted.
 	run(context.Context)
 }
 
-func (p *cloudProvider) runSKSAgent(runners []string) error {
-	for _, r := range runners {
-		debugf("sks-agent: starting %s runner", r)
+// sksAgentRunnerHealthReporter is implemented by the runners able to report
+// whether they are functional while running.
+type sksAgentRunnerHealthReporter interface {
+	// healthy returns an error if the runner is running but not functional.
+	healthy() error
+}
+
+// sksAgentRunnerConfig represents the options of an SKS agent runner, in the
+// sksAgent section of the cloud-config.
+type sksAgentRunnerConfig interface {
+	// validate checks the consistency of the runner options.
+	validate() error
+}
+
+// sksAgentRunnerDefinition describes an SKS agent runner, registered using
+// registerSKSAgentRunner().
+type sksAgentRunnerDefinition struct {
+	// name identifies the runner in the cloud-config, logs, metrics and
+	// health checks.
+	name string
+
+	// config returns the options of the runner from the SKS agent
+	// configuration, nil if the runner has none.
+	config func(*sksAgentConfig) sksAgentRunnerConfig
+
+	// dependencies lists the runners which must be enabled along with this
+	// one: it is only started once they are running and healthy.
+	dependencies []string
+
+	// new returns a new instance of the runner, for every (re)start.
+	new func(*cloudProvider) sksAgentRunner
+}
+
+// sksAgentRunners is the registry of the supported SKS agent runners.
+var sksAgentRunners = make(map[string]sksAgentRunnerDefinition)
+
+// registerSKSAgentRunner registers an SKS agent runner, it is meant to be
+// called from the init() function of the runner's file.
+func registerSKSAgentRunner(def sksAgentRunnerDefinition) {
+	if _, ok := sksAgentRunners[def.name]; ok {
+		panic(fmt.Sprintf("sks-agent: runner %q registered twice", def.name))
+	}
+
+	sksAgentRunners[def.name] = def
+}
+
+// sksAgentRunnerNames returns the names of the supported SKS agent runners.
+func sksAgentRunnerNames() []string {
+	names := make([]string, 0, len(sksAgentRunners))
+	for name := range sksAgentRunners {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// sksAgentRunnersStartOrder returns the definitions of the runners specified,
+// ordered so that every runner comes after its dependencies, or an error if a
+// runner is unsupported, or a dependency is missing or circular.
+func sksAgentRunnersStartOrder(names []string) ([]sksAgentRunnerDefinition, error) {
+	var (
+		ordered  []sksAgentRunnerDefinition
+		visiting = make(map[string]bool)
+		visited  = make(map[string]bool)
+		visit    func(name string, path []string) error
+	)
+
+	visit = func(name string, path []string) error {
+		if visited[name] {
+			return nil
+		}
+		if visiting[name] {
+			return fmt.Errorf("circular runner dependency %q", append(path, name))
+		}
+
+		def, ok := sksAgentRunners[name]
+		if !ok {
+			return fmt.Errorf("unsupported runner %q", name)
+		}
+
+		visiting[name] = true
+		for _, dep := range def.dependencies {
+			if !slices.Contains(names, dep) {
+				return fmt.Errorf("runner %q requires runner %q to be enabled", name, dep)
+			}
+			if err := visit(dep, append(path, name)); err != nil {
+				return err
+			}
+		}
+		visited[name] = true
+		ordered = append(ordered, def)
+
+		return nil
+	}
+
+	for _, name := range names {
+		if err := visit(name, nil); err != nil {
+			return nil, err
+		}
+	}
+
+	return ordered, nil
+}
+
+// sksAgentRunnerStatus represents the status of a runner on this replica.
+type sksAgentRunnerStatus struct {
+	// runner is the instance currently running, nil if the runner is
+	// waiting to be restarted.
+	runner sksAgentRunner
+
+	// err is the reason of the latest unexpected exit of the runner.
+	err error
+}
+
+// sksAgent runs the SKS agent runners enabled in the cloud-config, on the
+// CCM replica holding the SKS agent Lease only (unless leader election is
+// disabled), supervising them and tracking their health.
+type sksAgent struct {
+	p *cloudProvider
+
+	// runners are the definitions of the enabled runners, in start order.
+	runners []sksAgentRunnerDefinition
+
+	// leaderElection is nil if leader election is disabled, its callbacks
+	// are set for every leadership term by run().
+	leaderElection *leaderelection.LeaderElectionConfig
+
+	mu sync.RWMutex
+	// status tracks the runners started on this replica.
+	status map[string]*sksAgentRunnerStatus
+
+	// done is closed once the agent has shut down.
+	done chan struct{}
+}
+
+// newSKSAgent returns an SKS agent running the runners configured.
+func newSKSAgent(p *cloudProvider, cfg sksAgentConfig) (*sksAgent, error) {
+	runners, err := sksAgentRunnersStartOrder(cfg.Runners)
+	if err != nil {
+		return nil, err
+	}
+
+	agent := &sksAgent{
+		p:       p,
+		runners: runners,
+		status:  make(map[string]*sksAgentRunnerStatus),
+		done:    make(chan struct{}),
+	}
+
+	le := cfg.withDefaults().LeaderElection
+	if le.Disabled {
+		return agent, nil
+	}
+
+	hostname, err := os.Hostname()
+	if err != nil {
+		return nil, fmt.Errorf("unable to retrieve hostname: %w", err)
+	}
+
+	agent.leaderElection = &leaderelection.LeaderElectionConfig{
+		Lock: &resourcelock.LeaseLock{
+			LeaseMeta: metav1.ObjectMeta{Name: le.LeaseName, Namespace: le.LeaseNamespace},
+			Client:    p.kclient.CoordinationV1(),
+			LockConfig: resourcelock.ResourceLockConfig{
+				Identity: hostname + "_" + string(uuid.NewUUID()),
+			},
+		},
+		LeaseDuration: le.LeaseDuration,
+		RenewDeadline: le.RenewDeadline,
+		RetryPeriod:   le.RetryPeriod,
+		// The Lease is released by run() once the runners have shut down,
+		// not as soon as the context is cancelled.
+		ReleaseOnCancel: false,
+		Name:            "sks-agent",
+	}
+
+	if _, err := agent.newLeaderElector(func(context.Context) {}); err != nil {
+		return nil, fmt.Errorf("invalid leader election configuration: %w", err)
+	}
+
+	return agent, nil
+}
+
+// newLeaderElector returns a leader elector for a leadership term, calling
+// the function specified upon election.
+func (a *sksAgent) newLeaderElector(onStartedLeading func(context.Context)) (*leaderelection.LeaderElector, error) {
+	config := *a.leaderElection
+	config.Callbacks = leaderelection.LeaderCallbacks{
+		OnStartedLeading: onStartedLeading,
+		OnStoppedLeading: func() {},
+		OnNewLeader: func(identity string) {
+			infof("sks-agent: new leader elected: %s", identity)
+		},
+	}
+
+	return leaderelection.NewLeaderElector(config)
+}
+
+// run runs the SKS agent runners, while holding the SKS agent Lease if leader
+// election is enabled, until the context is cancelled and the runners have
+// shut down.
+func (a *sksAgent) run(ctx context.Context) {
+	defer close(a.done)
+
+	if a.leaderElection == nil {
+		a.lead(ctx)
+		return
+	}
+
+	for {
+		var (
+			mu         sync.Mutex
+			leading    bool // whether the runners of the term have been started
+			over       bool // whether the term is over
+			terminated = make(chan struct{})
+		)
+
+		elector, err := a.newLeaderElector(func(ctx context.Context) {
+			mu.Lock()
+			if over {
+				mu.Unlock()
+				return
+			}
+			leading = true
+			mu.Unlock()
+
+			defer close(terminated)
+			infof("sks-agent: started leading")
+			a.lead(ctx)
+		})
+		if err != nil {
+			// Unexpected, the configuration is validated by newSKSAgent().
+			errorf("sks-agent: unable to start leader election: %v", err)
+			return
+		}
+
+		// Run() returns once the context is cancelled, or the leadership has
+		// been acquired then lost. Its term context being cancelled, the
+		// runners are shutting down: the next term must not start before.
+		elector.Run(ctx)
+
+		mu.Lock()
+		over = true
+		mu.Unlock()
+		if leading {
+			<-terminated
+		}
+
+		if ctx.Err() != nil {
+			if leading {
+				a.releaseLease()
+			}
+			return
+		}
+		warnf("sks-agent: leadership lost")
+	}
+}
+
+// releaseLease releases the SKS agent Lease if it is still held by this
+// replica, so that another replica takes over without waiting for it to
+// expire. It must only be called once the runners have shut down.
+func (a *sksAgent) releaseLease() {
+	ctx, cancel := context.WithTimeout(context.Background(), a.leaderElection.RenewDeadline)
+	defer cancel()
+
+	lock := a.leaderElection.Lock
+	record, _, err := lock.Get(ctx)
+	if err != nil {
+		warnf("sks-agent: unable to release the Lease: %v", err)
+		return
+	}
+	if record.HolderIdentity != lock.Identity() {
+		return
+	}
+
+	now := metav1.NewTime(time.Now())
+	if err := lock.Update(ctx, resourcelock.LeaderElectionRecord{
+		LeaderTransitions:    record.LeaderTransitions,
+		LeaseDurationSeconds: 1,
+		AcquireTime:          now,
+		RenewTime:            now,
+	}); err != nil {
+		warnf("sks-agent: unable to release the Lease: %v", err)
+		return
+	}
+	infof("sks-agent: Lease released")
+}
+
+// lead runs the runners, each one once its dependencies are healthy, until
+// the context is cancelled, then waits for them to shut down.
+func (a *sksAgent) lead(ctx context.Context) {
+	ctx = withAPICaller(ctx, apiCallerSKSAgent)
+	metricSKSAgentLeader.Set(1)
+	defer metricSKSAgentLeader.Set(0)
+
+	stopped := make(map[string]chan struct{})
+	for _, def := range a.runners {
+		done := make(chan struct{})
+		stopped[def.name] = done
+		go func() {
+			defer close(done)
+			if !a.waitForDependencies(ctx, def) {
+				return
+			}
+
+			debugf("sks-agent: starting %s runner", def.name)
+			a.supervise(ctx, def)
+		}()
+	}
 
-		switch r {
-		case sksAgentNodeCSRValidation:
-			var runner sksAgentRunner = &sksAgentRunnerNodeCSRValidation{p: p}
-			go runner.run(p.ctx)
+	<-ctx.Done()
 
-		default:
-			return fmt.Errorf("unsupported runner %q", r)
+	timeout := time.After(sksAgentRunnerShutdownTimeout)
+	for _, def := range a.runners {
+		select {
+		case <-stopped[def.name]:
+			debugf("sks-agent: %s runner stopped", def.name)
+		case <-timeout:
+			warnf("sks-agent: %s runner didn't shut down within %s", def.name, sksAgentRunnerShutdownTimeout)
 		}
 	}
+}
+
+// waitForDependencies waits until the dependencies of the runner specified
+// are running and healthy, returning false if the context is cancelled
+// before. The dependencies exiting or becoming unhealthy afterwards don't
+// affect the runner.
+func (a *sksAgent) waitForDependencies(ctx context.Context, def sksAgentRunnerDefinition) bool {
+	if len(def.dependencies) == 0 {
+		return true
+	}
+
+	debugf("sks-agent: %s runner waiting for its dependencies %v", def.name, def.dependencies)
+	err := wait.PollUntilContextCancel(ctx, sksAgentRunnerDependenciesPollInterval, true,
+		func(context.Context) (bool, error) {
+			for _, dep := range def.dependencies {
+				if !a.runnerRunning(dep) || a.runnerHealth(dep) != nil {
+					return false, nil
+				}
+			}
+			return true, nil
+		})
+
+	return err == nil
+}
+
+// supervise runs the runner specified until the context is cancelled,
+// restarting it with backoff if it exits unexpectedly.
+func (a *sksAgent) supervise(ctx context.Context, def sksAgentRunnerDefinition) {
+	defer a.setStatus(def.name, nil, nil)
+
+	backoff := sksAgentRunnerRestartMinBackoff
+	for {
+		runner := def.new(a.p)
+		a.setStatus(def.name, runner, nil)
+
+		started := time.Now()
+		err := runSKSAgentRunner(ctx, runner)
+		if ctx.Err() != nil {
+			return
+		}
+
+		if time.Since(started) >= sksAgentRunnerRestartMaxBackoff {
+			backoff = sksAgentRunnerRestartMinBackoff
+		}
+		errorf("sks-agent: %s runner failed, restarting in %s: %v", def.name, backoff, err)
+		a.setStatus(def.name, nil, err)
+		metricSKSAgentRunnerRestarts.WithLabelValues(def.name).Inc()
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		backoff = min(2*backoff, sksAgentRunnerRestartMaxBackoff)
+	}
+}
+
+// runSKSAgentRunner runs the runner specified, returning the reason of its
+// exit, including the panic it recovered from if any.
+func runSKSAgentRunner(ctx context.Context, runner sksAgentRunner) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			debugf("sks-agent: runner panic stack trace:\n%s", debug.Stack())
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+
+	runner.run(ctx)
+
+	return errors.New("runner exited unexpectedly")
+}
+
+// setStatus records the runner instance currently running, or the reason of
+// its latest exit; both being nil meaning that the runner has been stopped.
+func (a *sksAgent) setStatus(name string, runner sksAgentRunner, err error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if runner == nil && err == nil {
+		delete(a.status, name)
+	} else {
+		a.status[name] = &sksAgentRunnerStatus{runner: runner, err: err}
+	}
+
+	if runner != nil {
+		metricSKSAgentRunnerUp.WithLabelValues(name).Set(1)
+	} else {
+		metricSKSAgentRunnerUp.WithLabelValues(name).Set(0)
+	}
+}
+
+// runnerRunning returns true if the runner specified is running on this
+// replica.
+func (a *sksAgent) runnerRunning(name string) bool {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	status, ok := a.status[name]
+
+	return ok && status.runner != nil
+}
+
+// runnerHealth returns an error if the runner specified is enabled and isn't
+// running (i.e. waiting to be restarted after exiting unexpectedly) or isn't
+// functional on this replica. Runners waiting for this replica to be elected
+// leader are considered healthy.
+func (a *sksAgent) runnerHealth(name string) error {
+	a.mu.RLock()
+	status, ok := a.status[name]
+	a.mu.RUnlock()
+
+	if !ok {
+		return nil
+	}
+
+	if status.runner == nil {
+		return fmt.Errorf("runner not running: %w", status.err)
+	}
+
+	if checker, ok := status.runner.(sksAgentRunnerHealthReporter); ok {
+		return checker.healthy()
+	}
 
 	return nil
 }
@@ -0,0 +1,228 @@
+package exoscale
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	v3 "github.com/exoscale/egoscale/v3"
+	"k8s.io/client-go/tools/leaderelection"
+)
+
+// Environment variables configuring the SKS agent, overriding the cloud-config.
+const (
+	// sksAgentRunnersEnvVar lists the runners to enable ("runner,...").
+	sksAgentRunnersEnvVar = "EXOSCALE_SKS_AGENT_RUNNERS"
+
+	// sksAgentNodeCSRValidationDenyEnvVar enables the denial of the Node CSRs
+	// failing validation.
+	sksAgentNodeCSRValidationDenyEnvVar = "EXOSCALE_SKS_AGENT_NODE_CSR_VALIDATION_DENY"
+
+	// sksAgentNodeCSRValidationClusterIDEnvVar restricts the Compute instances
+	// the CSRs may be issued for to the ones managed by the nodepools of the
+	// SKS cluster specified, instead of the one of the cluster Nodes.
+	sksAgentNodeCSRValidationClusterIDEnvVar = "EXOSCALE_SKS_AGENT_NODE_CSR_VALIDATION_CLUSTER_ID"
+
+	// sksAgentNodeCSRValidationInstanceLabelsEnvVar restricts the Compute
+	// instances the CSRs may be issued for to the ones carrying the labels
+	// specified ("key=value,..."), in addition to the ones of the SKS cluster.
+	sksAgentNodeCSRValidationInstanceLabelsEnvVar = "EXOSCALE_SKS_AGENT_NODE_CSR_VALIDATION_INSTANCE_LABELS"
+)
+
+// Defaults of the SKS agent leader election, matching the ones of the
+// cloud-controller-manager.
+const (
+	defaultSKSAgentLeaseName      = "exoscale-ccm-sks-agent"
+	defaultSKSAgentLeaseNamespace = "kube-system"
+	defaultSKSAgentLeaseDuration  = 15 * time.Second
+	defaultSKSAgentRenewDeadline  = 10 * time.Second
+	defaultSKSAgentRetryPeriod    = 2 * time.Second
+)
+
+// SKS agent configuration (<-> cloud-config file)
+type sksAgentConfig struct {
+	Runners []string `yaml:"runners"` // runners to enable, see sksAgentRunners
+
+	// Runners options, see sksAgentRunnerDefinition.config.
+	NodeCSRValidation sksAgentNodeCSRValidationConfig `yaml:"nodeCSRValidation"`
+
+	// Only the elected leader of the CCM replicas runs the runners.
+	LeaderElection sksAgentLeaderElectionConfig `yaml:"leaderElection"`
+}
+
+type sksAgentNodeCSRValidationConfig struct {
+	Deny bool `yaml:"deny"` // if true, deny the CSRs failing validation instead of leaving them pending
+
+	// Compute instances the CSRs may be issued for, see candidateInstances().
+	SKSClusterID   string            `yaml:"sksClusterID"`
+	InstanceLabels map[string]string `yaml:"instanceLabels"`
+}
+
+type sksAgentLeaderElectionConfig struct {
+	Disabled       bool          `yaml:"disabled"` // if true, every replica runs the runners
+	LeaseName      string        `yaml:"leaseName"`
+	LeaseNamespace string        `yaml:"leaseNamespace"`
+	LeaseDuration  time.Duration `yaml:"leaseDuration"`
+	RenewDeadline  time.Duration `yaml:"renewDeadline"`
+	RetryPeriod    time.Duration `yaml:"retryPeriod"`
+}
+
+// loadEnv overrides the configuration with the SKS agent environment
+// variables, if set.
+func (c *sksAgentConfig) loadEnv() error {
+	var errs []error
+
+	if value, exists := os.LookupEnv(sksAgentRunnersEnvVar); exists {
+		c.Runners = nil
+		for _, r := range strings.Split(value, ",") {
+			if r = strings.TrimSpace(r); r != "" {
+				c.Runners = append(c.Runners, r)
+			}
+		}
+	}
+
+	if value, exists := os.LookupEnv(sksAgentNodeCSRValidationDenyEnvVar); exists && value != "" {
+		deny, err := strconv.ParseBool(value)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: invalid value %q", sksAgentNodeCSRValidationDenyEnvVar, value))
+		}
+		c.NodeCSRValidation.Deny = deny
+	}
+
+	if value, exists := os.LookupEnv(sksAgentNodeCSRValidationClusterIDEnvVar); exists && value != "" {
+		c.NodeCSRValidation.SKSClusterID = value
+	}
+
+	if value, exists := os.LookupEnv(sksAgentNodeCSRValidationInstanceLabelsEnvVar); exists && value != "" {
+		c.NodeCSRValidation.InstanceLabels = make(map[string]string)
+		for _, label := range strings.Split(value, ",") {
+			k, v, ok := strings.Cut(label, "=")
+			if !ok || k == "" {
+				errs = append(errs, fmt.Errorf("%s: invalid label %q (expected key=value)",
+					sksAgentNodeCSRValidationInstanceLabelsEnvVar, label))
+				continue
+			}
+			c.NodeCSRValidation.InstanceLabels[k] = v
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// withDefaults returns a copy of the configuration, unset values being
+// replaced by their defaults.
+func (c sksAgentConfig) withDefaults() sksAgentConfig {
+	if c.LeaderElection.LeaseName == "" {
+		c.LeaderElection.LeaseName = defaultSKSAgentLeaseName
+	}
+	if c.LeaderElection.LeaseNamespace == "" {
+		c.LeaderElection.LeaseNamespace = defaultSKSAgentLeaseNamespace
+	}
+	if c.LeaderElection.LeaseDuration == 0 {
+		c.LeaderElection.LeaseDuration = defaultSKSAgentLeaseDuration
+	}
+	if c.LeaderElection.RenewDeadline == 0 {
+		c.LeaderElection.RenewDeadline = defaultSKSAgentRenewDeadline
+	}
+	if c.LeaderElection.RetryPeriod == 0 {
+		c.LeaderElection.RetryPeriod = defaultSKSAgentRetryPeriod
+	}
+
+	return c
+}
+
+// validate checks the consistency of the SKS agent configuration.
+func (c *sksAgentConfig) validate() error {
+	var errs []error
+
+	var unsupported bool
+	for i, r := range c.Runners {
+		_, supported := sksAgentRunners[r]
+		switch {
+		case !supported:
+			unsupported = true
+			errs = append(errs, fmt.Errorf("sksAgent.runners[%d]: unsupported runner %q (expected %s)",
+				i, r, strings.Join(sksAgentRunnerNames(), ", ")))
+		case slices.Index(c.Runners, r) != i:
+			errs = append(errs, fmt.Errorf("sksAgent.runners[%d]: duplicate runner %q", i, r))
+		}
+	}
+	if !unsupported {
+		if _, err := sksAgentRunnersStartOrder(c.Runners); err != nil {
+			errs = append(errs, fmt.Errorf("sksAgent.runners: %w", err))
+		}
+	}
+
+	for _, name := range sksAgentRunnerNames() {
+		if config := sksAgentRunners[name].config; config != nil {
+			errs = append(errs, config(c).validate())
+		}
+	}
+
+	errs = append(errs, c.LeaderElection.validate())
+
+	return errors.Join(errs...)
+}
+
+// validate checks the consistency of the Node CSR validation runner options.
+func (c *sksAgentNodeCSRValidationConfig) validate() error {
+	var errs []error
+
+	if c.SKSClusterID != "" {
+		if _, err := v3.ParseUUID(c.SKSClusterID); err != nil {
+			errs = append(errs, fmt.Errorf("sksAgent.nodeCSRValidation.sksClusterID: invalid SKS cluster ID %q: %w",
+				c.SKSClusterID, err))
+		}
+	}
+
+	keys := make([]string, 0, len(c.InstanceLabels))
+	for k := range c.InstanceLabels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if k == "" {
+			errs = append(errs, errors.New("sksAgent.nodeCSRValidation.instanceLabels: empty label key"))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// validate checks the consistency of the SKS agent leader election
+// configuration, as required by the leader elector.
+func (c *sksAgentLeaderElectionConfig) validate() error {
+	var errs []error
+
+	for _, d := range []struct {
+		field    string
+		duration time.Duration
+	}{
+		{"leaseDuration", c.LeaseDuration},
+		{"renewDeadline", c.RenewDeadline},
+		{"retryPeriod", c.RetryPeriod},
+	} {
+		if d.duration < 0 {
+			errs = append(errs, fmt.Errorf("sksAgent.leaderElection.%s: must not be negative", d.field))
+		}
+	}
+	if len(errs) > 0 {
+		return errors.Join(errs...)
+	}
+
+	le := sksAgentConfig{LeaderElection: *c}.withDefaults().LeaderElection
+	if le.LeaseDuration <= le.RenewDeadline {
+		errs = append(errs, errors.New("sksAgent.leaderElection.leaseDuration: must be greater than renewDeadline"))
+	}
+	if le.RenewDeadline <= time.Duration(leaderelection.JitterFactor*float64(le.RetryPeriod)) {
+		errs = append(errs, fmt.Errorf("sksAgent.leaderElection.renewDeadline: must be greater than %.1f * retryPeriod",
+			leaderelection.JitterFactor))
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,129 @@
+package exoscale
+
+import (
+	"strings"
+	"time"
+)
+
+func (ts *exoscaleCCMTestSuite) Test_readExoscaleConfig_sksAgent() {
+	ts.setTestCredentialsEnv()
+	sksClusterID := ts.randomID()
+
+	cfg, err := readExoscaleConfig(strings.NewReader(`---
+sksAgent:
+  runners:
+    - node-csr-validation
+  nodeCSRValidation:
+    deny: true
+    sksClusterID: "` + sksClusterID + `"
+    instanceLabels:
+      role: node
+  leaderElection:
+    leaseNamespace: exoscale
+    leaseDuration: 30s
+`))
+	ts.Require().NoError(err)
+	ts.Require().Equal(sksAgentConfig{
+		Runners: []string{sksAgentNodeCSRValidation},
+		NodeCSRValidation: sksAgentNodeCSRValidationConfig{
+			Deny:           true,
+			SKSClusterID:   sksClusterID,
+			InstanceLabels: map[string]string{"role": "node"},
+		},
+		LeaderElection: sksAgentLeaderElectionConfig{
+			LeaseNamespace: "exoscale",
+			LeaseDuration:  30 * time.Second,
+		},
+	}, cfg.SKSAgent)
+
+	le := cfg.SKSAgent.withDefaults().LeaderElection
+	ts.Require().Equal(defaultSKSAgentLeaseName, le.LeaseName)
+	ts.Require().Equal("exoscale", le.LeaseNamespace)
+	ts.Require().Equal(30*time.Second, le.LeaseDuration)
+	ts.Require().Equal(defaultSKSAgentRenewDeadline, le.RenewDeadline)
+}
+
+func (ts *exoscaleCCMTestSuite) Test_readExoscaleConfig_sksAgent_env() {
+	ts.setTestCredentialsEnv()
+	sksClusterID := ts.randomID()
+
+	ts.T().Setenv(sksAgentRunnersEnvVar, sksAgentNodeCSRValidation+",")
+	ts.T().Setenv(sksAgentNodeCSRValidationDenyEnvVar, "true")
+	ts.T().Setenv(sksAgentNodeCSRValidationClusterIDEnvVar, sksClusterID)
+	ts.T().Setenv(sksAgentNodeCSRValidationInstanceLabelsEnvVar, "role=node,env=prod")
+
+	// The environment takes precedence over the cloud-config.
+	cfg, err := readExoscaleConfig(strings.NewReader(`---
+sksAgent:
+  nodeCSRValidation:
+    instanceLabels:
+      team: ops
+`))
+	ts.Require().NoError(err)
+	ts.Require().Equal([]string{sksAgentNodeCSRValidation}, cfg.SKSAgent.Runners)
+	ts.Require().True(cfg.SKSAgent.NodeCSRValidation.Deny)
+	ts.Require().Equal(sksClusterID, cfg.SKSAgent.NodeCSRValidation.SKSClusterID)
+	ts.Require().Equal(map[string]string{"role": "node", "env": "prod"}, cfg.SKSAgent.NodeCSRValidation.InstanceLabels)
+
+	ts.T().Setenv(sksAgentNodeCSRValidationDenyEnvVar, "maybe")
+	ts.T().Setenv(sksAgentNodeCSRValidationInstanceLabelsEnvVar, "role")
+	_, err = readExoscaleConfig(strings.NewReader(testConfigYAML_empty))
+	ts.Require().ErrorContains(err, sksAgentNodeCSRValidationDenyEnvVar+`: invalid value "maybe"`)
+	ts.Require().ErrorContains(err, `invalid label "role"`)
+}
+
+func (ts *exoscaleCCMTestSuite) Test_sksAgentConfig_validate() {
+	ts.Require().NoError((&sksAgentConfig{}).validate())
+
+	_, err := readExoscaleConfig(strings.NewReader(`---
+sksAgent:
+  runners:
+    - node-csr-validation
+    - node-csr-validation
+    - node-csr-approval
+  nodeCSRValidation:
+    sksClusterID: "my-cluster"
+    instanceLabels:
+      "": node
+  leaderElection:
+    leaseDuration: 5s
+    retryPeriod: 10s
+`))
+	ts.Require().Error(err)
+	for _, expected := range []string{
+		`sksAgent.runners[1]: duplicate runner "node-csr-validation"`,
+		`sksAgent.runners[2]: unsupported runner "node-csr-approval"`,
+		`sksAgent.nodeCSRValidation.sksClusterID: invalid SKS cluster ID "my-cluster"`,
+		"sksAgent.nodeCSRValidation.instanceLabels: empty label key",
+		"sksAgent.leaderElection.leaseDuration: must be greater than renewDeadline",
+		"sksAgent.leaderElection.renewDeadline: must be greater than 1.2 * retryPeriod",
+	} {
+		ts.Require().ErrorContains(err, expected)
+	}
+
+	_, err = readExoscaleConfig(strings.NewReader("---\nsksAgent:\n  leaderElection:\n    retryPeriod: -1s\n"))
+	ts.Require().ErrorContains(err, "sksAgent.leaderElection.retryPeriod: must not be negative")
+}
+
+func (ts *exoscaleCCMTestSuite) Test_cloudProvider_reloadConfig_sksAgent() {
+	ts.T().Setenv("EXOSCALE_API_KEY", testAPIKey)
+	ts.T().Setenv("EXOSCALE_API_SECRET", testAPISecret)
+
+	ts.Require().NoError(ts.p.reloadConfig([]byte(`---
+sksAgent:
+  runners:
+    - node-csr-validation
+  nodeCSRValidation:
+    deny: true
+  leaderElection:
+    disabled: true
+`)))
+
+	// The runners options are reloaded...
+	ts.Require().True(ts.p.config().SKSAgent.NodeCSRValidation.Deny)
+
+	// ... but not their enablement nor the leader election, which require a
+	// restart.
+	ts.Require().Empty(ts.p.config().SKSAgent.Runners)
+	ts.Require().False(ts.p.config().SKSAgent.LeaderElection.Disabled)
+}
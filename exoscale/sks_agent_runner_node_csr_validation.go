@@ -3,22 +3,104 @@ package exoscale
 import (
 	"context"
 	"crypto/x509"
+	"encoding/json"
 	"encoding/pem"
+	"errors"
 	"fmt"
+	"net"
+	"slices"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	k8scertv1 "k8s.io/api/certificates/v1"
 	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	k8swatch "k8s.io/apimachinery/pkg/watch"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/informers"
+	certlisters "k8s.io/client-go/listers/certificates/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/workqueue"
 	cloudproviderapi "k8s.io/cloud-provider/api"
-	"k8s.io/utils/strings/slices"
+
+	v3 "github.com/exoscale/egoscale/v3"
 )
 
+const sksAgentNodeCSRValidation = "node-csr-validation"
+
+func init() {
+	registerSKSAgentRunner(sksAgentRunnerDefinition{
+		name: sksAgentNodeCSRValidation,
+		config: func(cfg *sksAgentConfig) sksAgentRunnerConfig {
+			return &cfg.NodeCSRValidation
+		},
+		new: func(p *cloudProvider) sksAgentRunner {
+			return newSKSAgentRunnerNodeCSRValidation(p)
+		},
+	})
+}
+
 const (
 	sksAgentNodeCSRValidationApprovalReason  = "ExoscaleCloudControllerApproved"
 	sksAgentNodeCSRValidationApprovalMessage = "This CSR was approved by the Exoscale Cloud Controller Manager"
+
+	// Reasons of the Denied conditions set on the invalid CSRs, and of the
+	// corresponding Kubernetes Events.
+	sksAgentNodeCSRValidationDenialReasonInvalidRequest        = "InvalidRequest"
+	sksAgentNodeCSRValidationDenialReasonInvalidDNSNames       = "InvalidDNSNames"
+	sksAgentNodeCSRValidationDenialReasonInvalidUsages         = "InvalidUsages"
+	sksAgentNodeCSRValidationDenialReasonInvalidRequester      = "InvalidRequester"
+	sksAgentNodeCSRValidationDenialReasonInvalidSubject        = "InvalidSubject"
+	sksAgentNodeCSRValidationDenialReasonInstanceMismatch      = "InstanceMismatch"
+	sksAgentNodeCSRValidationDenialReasonNoMatchingInstance    = "NoMatchingInstance"
+	sksAgentNodeCSRValidationDenialReasonAmbiguousInstanceName = "AmbiguousInstanceName"
+	sksAgentNodeCSRValidationDenialReasonIPAddressMismatch     = "IPAddressMismatch"
+
+	// sksAgentNodeCSRValidationInstanceIDAnnotation is set on the Nodes upon
+	// approval of their serving certificate CSR, recording the ID of the
+	// Compute instance it has been issued for.
+	sksAgentNodeCSRValidationInstanceIDAnnotation = "node.beta.kubernetes.io/exoscale-serving-certificate-instance-id"
+
+	eventReasonNodeCSRApproved = "NodeCSRApproved"
+	eventReasonNodeCSRDenied   = "NodeCSRDenied"
+
+	// sksAgentNodeCSRValidationResyncPeriod is the period at which all the
+	// CSRs are evaluated again, in addition to the retries of the ones which
+	// failed validation.
+	sksAgentNodeCSRValidationResyncPeriod = 10 * time.Minute
+
+	// sksAgentNodeCSRValidationPendingExpiry is the age after which pending
+	// CSRs are not evaluated anymore, matching the delay after which the
+	// kube-controller-manager garbage-collects them.
+	sksAgentNodeCSRValidationPendingExpiry = 24 * time.Hour
+)
+
+// The backoff of the evaluation retries of the CSRs which failed validation.
+var (
+	sksAgentNodeCSRValidationRetryMinBackoff = time.Second
+	sksAgentNodeCSRValidationRetryMaxBackoff = 5 * time.Minute
+)
+
+// sksAgentNodeCSRValidationDenialGracePeriod is the age after which CSRs
+// failing validation for a reason which might be temporary (e.g. the Compute
+// instance of the Node isn't listed yet) are denied, if denial is enabled.
+var sksAgentNodeCSRValidationDenialGracePeriod = 15 * time.Minute
+
+// sksAgentNodeCSRValidationRequiredUsages and
+// sksAgentNodeCSRValidationOptionalUsages describe the key usages of the
+// kubelet serving certificates: "key encipherment" is only requested by some
+// kubelet versions.
+var (
+	sksAgentNodeCSRValidationRequiredUsages = []k8scertv1.KeyUsage{
+		k8scertv1.UsageDigitalSignature,
+		k8scertv1.UsageServerAuth,
+	}
+	sksAgentNodeCSRValidationOptionalUsages = []k8scertv1.KeyUsage{
+		k8scertv1.UsageKeyEncipherment,
+	}
 )
 
 // sksAgentNodeCSRValidationRequiredGroups describes the list of Kubernetes
@@ -28,155 +110,736 @@ var sksAgentNodeCSRValidationRequiredGroups = []string{
 	"system:nodes",
 }
 
+// nodeCSRValidationError represents a CSR validation failure, which is either
+// permanent (the CSR will never be valid) or temporary (e.g. the Compute
+// instance of the Node isn't listed yet), in which case the CSR is evaluated
+// again later on. The reason is the one of the Denied condition set on the
+// CSR if denial is enabled.
+type nodeCSRValidationError struct {
+	reason    string
+	err       error
+	permanent bool
+}
+
+func (e *nodeCSRValidationError) Error() string {
+	return e.err.Error()
+}
+
+func (e *nodeCSRValidationError) Unwrap() error {
+	return e.err
+}
+
+func permanentNodeCSRValidationErrorf(reason, format string, args ...interface{}) error {
+	return &nodeCSRValidationError{reason: reason, err: fmt.Errorf(format, args...), permanent: true}
+}
+
+func temporaryNodeCSRValidationErrorf(reason, format string, args ...interface{}) error {
+	return &nodeCSRValidationError{reason: reason, err: fmt.Errorf(format, args...)}
+}
+
 // sksAgentRunnerNodeCSRValidation is a SKS agent runner performing automatic
 // cluster Node CSR validation.
 type sksAgentRunnerNodeCSRValidation struct {
 	p *cloudProvider
+
+	lister certlisters.CertificateSigningRequestLister
+	queue  workqueue.TypedRateLimitingInterface[string]
+
+	// synced reports whether the CSR informer cache has synced, which is
+	// required for the runner to be functional.
+	synced atomic.Bool
+
+	// pending tracks the CSRs which failed validation and are still pending,
+	// see setPending(). Entries are dropped once the CSR has been evaluated
+	// successfully, approved or denied (by anyone), or deleted.
+	pending   map[string]struct{}
+	pendingMu sync.Mutex
+
+	// sksClusterID caches the ID of the SKS cluster determined from the
+	// cluster Nodes, see nodesSKSClusterID(). It is only accessed by the
+	// worker.
+	sksClusterID v3.UUID
+}
+
+// newSKSAgentRunnerNodeCSRValidation returns a Node CSR validation runner.
+func newSKSAgentRunnerNodeCSRValidation(p *cloudProvider) *sksAgentRunnerNodeCSRValidation {
+	return &sksAgentRunnerNodeCSRValidation{p: p}
+}
+
+// config returns the Node CSR validation configuration currently in effect.
+func (r *sksAgentRunnerNodeCSRValidation) config() *sksAgentNodeCSRValidationConfig {
+	return &r.p.config().SKSAgent.NodeCSRValidation
 }
 
 func (r *sksAgentRunnerNodeCSRValidation) run(ctx context.Context) {
-	// We set a relatively low watcher timeout to be able to break out of the
-	// watch loop frequently in case we weren't able to validate a CSR for some
-	// reason (e.g. the Exoscale client didn't have valid API credentials at
-	// the time), this way we have a chance to do re-evaluate missed CSRs quickly
-	// after.
-	watchTimeoutSeconds := int64(120)
-
-	for {
-		watcher, err := r.p.kclient.
-			CertificatesV1().
-			CertificateSigningRequests().
-			Watch(ctx, metav1.ListOptions{
-				Watch:          true,
-				TimeoutSeconds: &watchTimeoutSeconds, // Default timeout: 20 minutes.
-			})
-		if err != nil {
-			errorf("sks-agent: failed to list CSR resources: %v", err)
-			time.Sleep(10 * time.Second) // Pause for a while before retrying, otherwise we'll spam error logs.
+	informerFactory := informers.NewSharedInformerFactory(r.p.kclient, sksAgentNodeCSRValidationResyncPeriod)
+	informer := informerFactory.Certificates().V1().CertificateSigningRequests()
+
+	r.lister = informer.Lister()
+	r.pending = make(map[string]struct{})
+	r.queue = workqueue.NewTypedRateLimitingQueueWithConfig(
+		workqueue.NewTypedItemExponentialFailureRateLimiter[string](
+			sksAgentNodeCSRValidationRetryMinBackoff,
+			sksAgentNodeCSRValidationRetryMaxBackoff,
+		),
+		workqueue.TypedRateLimitingQueueConfig[string]{Name: "sks-agent-" + sksAgentNodeCSRValidation},
+	)
+	defer r.queue.ShutDown()
+
+	_, err := informer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: r.enqueue,
+		UpdateFunc: func(_, obj interface{}) {
+			r.enqueue(obj)
+		},
+		DeleteFunc: r.forget,
+	})
+	if err != nil {
+		errorf("sks-agent: failed to watch CSR resources: %v", err)
+		return
+	}
+
+	informerFactory.Start(ctx.Done())
+	defer informerFactory.Shutdown()
+
+	if !cache.WaitForCacheSync(ctx.Done(), informer.Informer().HasSynced) {
+		infof("sks-agent: context cancelled, terminating")
+		return
+	}
+
+	r.synced.Store(true)
+	debugf("sks-agent: watching for pending CSRs")
+
+	var wg sync.WaitGroup
+	wg.Go(func() {
+		wait.UntilWithContext(ctx, r.worker, time.Second)
+	})
+
+	<-ctx.Done()
+	infof("sks-agent: context cancelled, terminating")
+
+	// Let the worker complete the evaluation of its current CSR.
+	r.queue.ShutDown()
+	wg.Wait()
+}
+
+// healthy returns an error if the CSR informer cache hasn't synced yet.
+func (r *sksAgentRunnerNodeCSRValidation) healthy() error {
+	if !r.synced.Load() {
+		return errors.New("CSR informer cache not synced")
+	}
+
+	return nil
+}
+
+// enqueue queues the CSR specified for evaluation if it is pending.
+func (r *sksAgentRunnerNodeCSRValidation) enqueue(obj interface{}) {
+	csr, ok := obj.(*k8scertv1.CertificateSigningRequest)
+	if !ok {
+		errorf("sks-agent: expected object of type *CertificateSigningRequest, got %T", obj)
+		return
+	}
+
+	// The CSR has already been approved or denied.
+	if len(csr.Status.Conditions) > 0 {
+		r.setPending(csr.Name, false)
+		return
+	}
+
+	r.queue.Add(csr.Name)
+}
+
+// forget stops tracking the deleted CSR specified as pending.
+func (r *sksAgentRunnerNodeCSRValidation) forget(obj interface{}) {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+
+	if csr, ok := obj.(*k8scertv1.CertificateSigningRequest); ok {
+		r.setPending(csr.Name, false)
+	}
+}
+
+// setPending records whether the CSR specified failed validation and is still
+// pending, and updates the pending CSRs metric accordingly.
+func (r *sksAgentRunnerNodeCSRValidation) setPending(name string, pending bool) {
+	r.pendingMu.Lock()
+	defer r.pendingMu.Unlock()
+
+	if pending {
+		r.pending[name] = struct{}{}
+	} else {
+		delete(r.pending, name)
+	}
+	metricSKSAgentNodeCSRsPending.Set(float64(len(r.pending)))
+}
+
+// worker evaluates the queued CSRs until the queue is shut down.
+func (r *sksAgentRunnerNodeCSRValidation) worker(ctx context.Context) {
+	for r.processNextCSR(ctx) {
+	}
+}
+
+func (r *sksAgentRunnerNodeCSRValidation) processNextCSR(ctx context.Context) bool {
+	name, shutdown := r.queue.Get()
+	if shutdown {
+		return false
+	}
+	defer r.queue.Done(name)
+
+	err := r.processCSR(ctx, name)
+	r.setPending(name, err != nil)
+
+	var validationErr *nodeCSRValidationError
+	switch {
+	case err == nil:
+		r.queue.Forget(name)
+
+	case errors.As(err, &validationErr):
+		errorf("sks-agent: CSR %s %v", name, err)
+		if validationErr.permanent {
+			r.queue.Forget(name)
+		} else {
+			r.queue.AddRateLimited(name)
+		}
+
+	default:
+		errorf("sks-agent: unable to validate CSR %s, retrying: %v", name, err)
+		r.queue.AddRateLimited(name)
+	}
+
+	return true
+}
+
+// processCSR evaluates the CSR specified, and approves it if it is valid. If
+// denial is enabled, invalid CSRs are denied, immediately if they can never be
+// valid or else once the denial grace period has elapsed.
+func (r *sksAgentRunnerNodeCSRValidation) processCSR(ctx context.Context, name string) error {
+	csr, err := r.lister.Get(name)
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil
+		}
+		return err
+	}
+
+	// The CSR has already been approved or denied.
+	if len(csr.Status.Conditions) > 0 {
+		return nil
+	}
+
+	// The CSR isn't a kubelet serving certificate request.
+	if csr.Spec.SignerName != k8scertv1.KubeletServingSignerName || !r.hasRequiredGroups(csr) {
+		return nil
+	}
+
+	if !csr.CreationTimestamp.IsZero() && time.Since(csr.CreationTimestamp.Time) > sksAgentNodeCSRValidationPendingExpiry {
+		debugf("sks-agent: CSR %s has expired, skipping", csr.Name)
+		return nil
+	}
+
+	debugf("sks-agent: checking pending CSR %s", csr.Name)
+
+	match, err := r.validateCSR(ctx, csr)
+	if err != nil {
+		var validationErr *nodeCSRValidationError
+		if r.config().Deny && errors.As(err, &validationErr) &&
+			(validationErr.permanent || (!csr.CreationTimestamp.IsZero() &&
+				time.Since(csr.CreationTimestamp.Time) > sksAgentNodeCSRValidationDenialGracePeriod)) {
+			return r.denyCSR(ctx, csr, validationErr)
+		}
+
+		return err
+	}
+
+	return r.approveCSR(ctx, csr, match)
+}
+
+// nodeCSRMatch represents the Node and Compute instance a valid CSR has been
+// issued for.
+type nodeCSRMatch struct {
+	nodeName   string
+	instanceID v3.UUID
+}
+
+// validateCSR returns an error if the CSR specified isn't a kubelet serving
+// certificate request consistent with its requester, or doesn't match a
+// Compute instance of the cluster.
+func (r *sksAgentRunnerNodeCSRValidation) validateCSR(
+	ctx context.Context,
+	csr *k8scertv1.CertificateSigningRequest,
+) (*nodeCSRMatch, error) {
+	parsedCSR, err := r.parseCSR(csr.Spec.Request)
+	if err != nil {
+		return nil, permanentNodeCSRValidationErrorf(
+			sksAgentNodeCSRValidationDenialReasonInvalidRequest,
+			"can't be parsed: %w", err,
+		)
+	}
+
+	if l := len(parsedCSR.DNSNames); l != 1 {
+		return nil, permanentNodeCSRValidationErrorf(
+			sksAgentNodeCSRValidationDenialReasonInvalidDNSNames,
+			"has %d certificate Subject Alternate Name DNS Name values, expected 1", l,
+		)
+	}
+
+	nodeName := parsedCSR.DNSNames[0]
+
+	if err := r.validateRequester(csr, parsedCSR, nodeName); err != nil {
+		return nil, err
+	}
+
+	if err := r.validateUsages(csr); err != nil {
+		return nil, err
+	}
+
+	// The Node might not be registered yet.
+	node, err := r.p.kclient.CoreV1().Nodes().Get(ctx, nodeName, metav1.GetOptions{})
+	if err != nil {
+		if !apierrors.IsNotFound(err) {
+			return nil, fmt.Errorf("failed to retrieve Node %s: %w", nodeName, err)
+		}
+		node = nil
+	}
+
+	// Scanning all the Compute instances is skipped while the API is
+	// failing: the CSR will be evaluated again later on.
+	instances, err := r.p.client.ListInstances(withNonEssentialAPICalls(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list Compute instances: %w", err)
+	}
+
+	candidates, err := r.candidateInstances(ctx, instances.Instances)
+	if err != nil {
+		return nil, err
+	}
+
+	// If the Node has already been initialized, the CSR must match the
+	// Compute instance of its provider ID.
+	var nodeInstanceID v3.UUID
+	if node != nil && strings.HasPrefix(node.Spec.ProviderID, providerPrefix) {
+		if id, err := v3.ParseUUID(strings.TrimPrefix(node.Spec.ProviderID, providerPrefix)); err == nil {
+			nodeInstanceID = id
+		}
+	}
+
+	var matches []v3.ListInstancesResponseInstances
+	for _, instance := range candidates {
+		if !strings.EqualFold(instance.Name, nodeName) {
+			continue
+		}
+		if nodeInstanceID != "" && instance.ID != nodeInstanceID {
 			continue
 		}
-		csrWatcher := k8swatch.Filter(watcher, func(in k8swatch.Event) (out k8swatch.Event, keep bool) {
-			if in.Type != k8swatch.Added {
-				return in, false
+		matches = append(matches, instance)
+	}
+
+	switch len(matches) {
+	case 0:
+		// The Compute instance might not be listed yet.
+		return nil, temporaryNodeCSRValidationErrorf(
+			sksAgentNodeCSRValidationDenialReasonNoMatchingInstance,
+			"doesn't match any Compute instance (DNS name %q)", nodeName,
+		)
+
+	case 1:
+
+	default:
+		ids := make([]string, len(matches))
+		for i, instance := range matches {
+			ids[i] = instance.ID.String()
+		}
+		return nil, permanentNodeCSRValidationErrorf(
+			sksAgentNodeCSRValidationDenialReasonAmbiguousInstanceName,
+			"matches several Compute instances (DNS name %q): %s", nodeName, strings.Join(ids, ", "),
+		)
+	}
+
+	instance := matches[0]
+
+	// The Node name must not be reused by another Compute instance as long
+	// as the Node exists.
+	if node != nil {
+		if id, ok := node.Annotations[sksAgentNodeCSRValidationInstanceIDAnnotation]; ok && id != instance.ID.String() {
+			return nil, permanentNodeCSRValidationErrorf(
+				sksAgentNodeCSRValidationDenialReasonInstanceMismatch,
+				"Node %s serving certificate has been issued for Compute instance %s, not %s",
+				nodeName, id, instance.ID,
+			)
+		}
+	}
+
+	nodeAddrs := r.instancePrimaryAddresses(instance)
+	if !containsAllIPs(nodeAddrs, parsedCSR.IPAddresses) {
+		// The CSR might include IP addresses of the Compute instance which
+		// aren't part of the instances listing.
+		addrs, err := r.instanceAdditionalAddresses(ctx, instance.ID)
+		if err != nil {
+			return nil, err
+		}
+		nodeAddrs = append(nodeAddrs, addrs...)
+
+		// Unmanaged Private Networks have no leases: fall back to the IP
+		// address provided to the kubelet.
+		if len(instance.PrivateNetworks) > 0 && node != nil {
+			if providedIP := net.ParseIP(node.ObjectMeta.Annotations[cloudproviderapi.AnnotationAlphaProvidedIPAddr]); providedIP != nil {
+				nodeAddrs = append(nodeAddrs, providedIP)
+			}
+		}
+	}
+
+	if !containsAllIPs(nodeAddrs, parsedCSR.IPAddresses) {
+		return nil, temporaryNodeCSRValidationErrorf(
+			sksAgentNodeCSRValidationDenialReasonIPAddressMismatch,
+			"Node IP addresses don't match corresponding Compute instance IP addresses %q, got %q",
+			nodeAddrs, parsedCSR.IPAddresses,
+		)
+	}
+
+	return &nodeCSRMatch{nodeName: nodeName, instanceID: instance.ID}, nil
+}
+
+// validateRequester returns an error if the requester of the CSR specified
+// isn't the Node it is issued for, or if its subject isn't the Node one.
+func (r *sksAgentRunnerNodeCSRValidation) validateRequester(
+	csr *k8scertv1.CertificateSigningRequest,
+	parsedCSR *x509.CertificateRequest,
+	nodeName string,
+) error {
+	expected := "system:node:" + nodeName
+
+	if csr.Spec.Username != expected {
+		return permanentNodeCSRValidationErrorf(
+			sksAgentNodeCSRValidationDenialReasonInvalidRequester,
+			"requester %q doesn't match the DNS name %q (expected %q)", csr.Spec.Username, nodeName, expected,
+		)
+	}
+
+	if parsedCSR.Subject.CommonName != expected ||
+		len(parsedCSR.Subject.Organization) != 1 || parsedCSR.Subject.Organization[0] != "system:nodes" {
+		return permanentNodeCSRValidationErrorf(
+			sksAgentNodeCSRValidationDenialReasonInvalidSubject,
+			"subject %q doesn't match the Node (expected CN=%s,O=system:nodes)", parsedCSR.Subject, expected,
+		)
+	}
+
+	return nil
+}
+
+// validateUsages returns an error if the key usages of the CSR specified
+// aren't the ones of kubelet serving certificates.
+func (r *sksAgentRunnerNodeCSRValidation) validateUsages(csr *k8scertv1.CertificateSigningRequest) error {
+	invalid := len(csr.Spec.Usages) != len(slices.Compact(slices.Sorted(slices.Values(csr.Spec.Usages))))
+
+	for _, usage := range sksAgentNodeCSRValidationRequiredUsages {
+		if !slices.Contains(csr.Spec.Usages, usage) {
+			invalid = true
+		}
+	}
+
+	for _, usage := range csr.Spec.Usages {
+		if !slices.Contains(sksAgentNodeCSRValidationRequiredUsages, usage) &&
+			!slices.Contains(sksAgentNodeCSRValidationOptionalUsages, usage) {
+			invalid = true
+		}
+	}
+
+	if invalid {
+		return permanentNodeCSRValidationErrorf(
+			sksAgentNodeCSRValidationDenialReasonInvalidUsages,
+			"key usages %q aren't the ones of kubelet serving certificates (%q, optionally %q)",
+			csr.Spec.Usages, sksAgentNodeCSRValidationRequiredUsages, sksAgentNodeCSRValidationOptionalUsages,
+		)
+	}
+
+	return nil
+}
+
+// instancePrimaryAddresses returns the public IPv4 and IPv6 addresses of the
+// Compute instance specified.
+func (r *sksAgentRunnerNodeCSRValidation) instancePrimaryAddresses(instance v3.ListInstancesResponseInstances) []net.IP {
+	var addrs []net.IP
+
+	if instance.PublicIP != nil {
+		addrs = append(addrs, instance.PublicIP)
+	}
+
+	if ip := net.ParseIP(instance.Ipv6Address); ip != nil {
+		addrs = append(addrs, ip)
+	}
+
+	return addrs
+}
+
+// instanceAdditionalAddresses returns the IP addresses of the Elastic IPs
+// attached to the Compute instance specified, and the ones leased to it on
+// managed Private Networks.
+func (r *sksAgentRunnerNodeCSRValidation) instanceAdditionalAddresses(ctx context.Context, id v3.UUID) ([]net.IP, error) {
+	ctx = withNonEssentialAPICalls(ctx)
+
+	instance, err := r.p.client.GetInstance(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve Compute instance %s: %w", id, err)
+	}
+
+	var addrs []net.IP
+
+	for _, eip := range instance.ElasticIPS {
+		if eip.IP == "" {
+			e, err := r.p.client.GetElasticIP(ctx, eip.ID)
+			if err != nil {
+				return nil, fmt.Errorf("failed to retrieve Elastic IP %s: %w", eip.ID, err)
+			}
+			eip = *e
+		}
+
+		if ip := net.ParseIP(eip.IP); ip != nil {
+			addrs = append(addrs, ip)
+		}
+	}
+
+	for _, privateNetwork := range instance.PrivateNetworks {
+		pn, err := r.p.client.GetPrivateNetwork(ctx, privateNetwork.ID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to retrieve Private Network %s: %w", privateNetwork.ID, err)
+		}
+
+		for _, lease := range pn.Leases {
+			if lease.InstanceID == id && lease.IP != nil {
+				addrs = append(addrs, lease.IP)
+			}
+		}
+	}
+
+	return addrs, nil
+}
+
+// containsAllIPs returns true if all the IP addresses are part of addrs.
+func containsAllIPs(addrs, ips []net.IP) bool {
+	for _, ip := range ips {
+		if !slices.ContainsFunc(addrs, ip.Equal) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// candidateInstances returns the Compute instances the CSRs may be issued
+// for: the ones managed by the nodepools of the SKS cluster and the ones
+// carrying the instance labels configured. If neither is configured, the SKS
+// cluster is determined from the cluster Nodes: the CSRs are never evaluated
+// against all the Compute instances of the zone.
+func (r *sksAgentRunnerNodeCSRValidation) candidateInstances(
+	ctx context.Context,
+	instances []v3.ListInstancesResponseInstances,
+) ([]v3.ListInstancesResponseInstances, error) {
+	cfg := r.config()
+
+	nodepools := make(map[v3.UUID]struct{})
+	if sksClusterID := v3.UUID(cfg.SKSClusterID); sksClusterID != "" || len(cfg.InstanceLabels) == 0 {
+		sksClusters, err := r.p.client.ListSKSClusters(withNonEssentialAPICalls(ctx))
+		if err != nil {
+			return nil, fmt.Errorf("failed to list SKS clusters: %w", err)
+		}
+
+		if sksClusterID == "" {
+			if sksClusterID, err = r.nodesSKSClusterID(ctx, instances, sksClusters); err != nil {
+				return nil, err
+			}
+		}
+
+		var found bool
+		for _, cluster := range sksClusters.SKSClusters {
+			if cluster.ID != sksClusterID {
+				continue
+			}
+			found = true
+			for _, nodepool := range cluster.Nodepools {
+				nodepools[nodepool.ID] = struct{}{}
 			}
-			return in, true
-		})
-
-		debugf("sks-agent: watching for pending CSRs")
-
-	watch:
-		for {
-			select {
-			case <-ctx.Done():
-				infof("sks-agent: context cancelled, terminating")
-				return
-
-			case event, ok := <-csrWatcher.ResultChan():
-				if !ok {
-					// Server timeout closed the watcher channel, loop again to re-create a new one.
-					debugf("sks-agent: API server closed watcher channel")
-					break watch
-				}
-
-				csr, ok := event.Object.DeepCopyObject().(*k8scertv1.CertificateSigningRequest)
-				if !ok {
-					errorf("sks-agent: expected event of type *CertificateSigningRequest, got %v",
-						event.Object.GetObjectKind())
-					continue
-				}
-
-				// The CSR has already been approved or denied.
-				if len(csr.Status.Conditions) > 0 {
-					continue
-				}
-
-				if !r.hasRequiredGroups(csr) {
-					continue
-				}
-
-				debugf("sks-agent: checking pending CSR %s", csr.Name)
-
-				parsedCSR, err := r.parseCSR(csr.Spec.Request)
-				if err != nil {
-					errorf("sks-agent: failed to parse CSR: %v", err)
-					continue
-				}
-
-				if l := len(parsedCSR.DNSNames); l != 1 {
-					errorf("sks-agent: expected 1 certificate Subject Alternate Name DNS Name value, got %d", l)
-					continue
-				}
-
-				instances, err := r.p.client.ListInstances(ctx)
-				if err != nil {
-					errorf("sks-agent: failed to list Compute instances: %v", err)
-					continue
-				}
-
-				csrOK := false
-				for _, instance := range instances.Instances {
-					if strings.EqualFold(instance.Name, parsedCSR.DNSNames[0]) {
-						var nodeAddrs []string
-
-						if instance.PublicIP != nil {
-							nodeAddrs = append(nodeAddrs, instance.PublicIP.String())
-						}
-
-						if instance.Ipv6Address != "" {
-							nodeAddrs = append(nodeAddrs, instance.Ipv6Address)
-						}
-
-						if len(instance.PrivateNetworks) > 0 {
-							node, _ := r.p.kclient.CoreV1().Nodes().Get(ctx, instance.Name, metav1.GetOptions{})
-							if node == nil {
-								node, _ = r.p.kclient.CoreV1().Nodes().Get(ctx, strings.ToLower(instance.Name), metav1.GetOptions{})
-							}
-							if node != nil {
-								if providedIP, ok := node.ObjectMeta.Annotations[cloudproviderapi.AnnotationAlphaProvidedIPAddr]; ok {
-									nodeAddrs = append(nodeAddrs, providedIP)
-								}
-							}
-						}
-
-						csrOK = true
-
-						for _, ip := range parsedCSR.IPAddresses {
-							if !slices.Contains(nodeAddrs, ip.String()) {
-								errorf("sks-agent: CSR %s Node IP addresses don't match corresponding "+
-									"Compute instance IP addresses %q, got %q", csr.Name, nodeAddrs, parsedCSR.IPAddresses)
-
-								csrOK = false
-								break
-							}
-						}
-					}
-				}
-				if !csrOK {
-					errorf("sks-agent: CSR %s doesn't match any Compute instance", csr.Name)
-					continue
-				}
-
-				csr.Status.Conditions = append(csr.Status.Conditions, k8scertv1.CertificateSigningRequestCondition{
-					Type:           k8scertv1.CertificateApproved,
-					Status:         corev1.ConditionTrue,
-					Reason:         sksAgentNodeCSRValidationApprovalReason,
-					Message:        sksAgentNodeCSRValidationApprovalMessage,
-					LastUpdateTime: metav1.Now(),
-				})
-
-				_, err = r.p.kclient.
-					CertificatesV1().
-					CertificateSigningRequests().
-					UpdateApproval(ctx, csr.Name, csr, metav1.UpdateOptions{})
-				if err != nil {
-					errorf("sks-agent: failed to approve CSR %s: %v", csr.Name, err)
-					continue
-				}
-
-				infof("sks-agent: CSR %s approved", csr.Name)
+		}
+		if !found {
+			return nil, fmt.Errorf("SKS cluster %s not found", sksClusterID)
+		}
+	}
+
+	var candidates []v3.ListInstancesResponseInstances
+	for _, instance := range instances {
+		if instance.Manager != nil && instance.Manager.Type == v3.ManagerTypeSKSNodepool {
+			if _, ok := nodepools[instance.Manager.ID]; ok {
+				candidates = append(candidates, instance)
+				continue
 			}
 		}
+
+		if matchesInstanceLabels(cfg.InstanceLabels, instance.Labels) {
+			candidates = append(candidates, instance)
+		}
+	}
+
+	return candidates, nil
+}
+
+// nodesSKSClusterID returns the ID of the SKS cluster whose nodepools manage
+// the Compute instances of the cluster Nodes (according to their provider
+// ID), failing if there is not exactly one.
+func (r *sksAgentRunnerNodeCSRValidation) nodesSKSClusterID(
+	ctx context.Context,
+	instances []v3.ListInstancesResponseInstances,
+	sksClusters *v3.ListSKSClustersResponse,
+) (v3.UUID, error) {
+	if r.sksClusterID != "" {
+		return r.sksClusterID, nil
+	}
+
+	nodes, err := r.p.kclient.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return "", fmt.Errorf("failed to list Nodes: %w", err)
+	}
+
+	nodepoolClusters := make(map[v3.UUID]v3.UUID)
+	for _, cluster := range sksClusters.SKSClusters {
+		for _, nodepool := range cluster.Nodepools {
+			nodepoolClusters[nodepool.ID] = cluster.ID
+		}
+	}
+
+	nodeInstances := make(map[v3.UUID]struct{})
+	for _, node := range nodes.Items {
+		if !strings.HasPrefix(node.Spec.ProviderID, providerPrefix) {
+			continue
+		}
+		if id, err := v3.ParseUUID(strings.TrimPrefix(node.Spec.ProviderID, providerPrefix)); err == nil {
+			nodeInstances[id] = struct{}{}
+		}
+	}
+
+	var clusters []string
+	for _, instance := range instances {
+		if _, ok := nodeInstances[instance.ID]; !ok {
+			continue
+		}
+		if instance.Manager == nil || instance.Manager.Type != v3.ManagerTypeSKSNodepool {
+			continue
+		}
+		if cluster, ok := nodepoolClusters[instance.Manager.ID]; ok && !slices.Contains(clusters, cluster.String()) {
+			clusters = append(clusters, cluster.String())
+		}
+	}
+
+	switch len(clusters) {
+	case 0:
+		return "", errors.New("unable to determine the SKS cluster from the cluster Nodes, " +
+			"sksClusterID or instanceLabels must be configured")
+	case 1:
+	default:
+		slices.Sort(clusters)
+		return "", fmt.Errorf("the cluster Nodes belong to several SKS clusters (%s), "+
+			"sksClusterID or instanceLabels must be configured", strings.Join(clusters, ", "))
+	}
+
+	r.sksClusterID = v3.UUID(clusters[0])
+	infof("sks-agent: Node CSRs restricted to the Compute instances of SKS cluster %s", r.sksClusterID)
+
+	return r.sksClusterID, nil
+}
+
+// matchesInstanceLabels returns true if instance labels are configured, and
+// all of them are set on the Compute instance.
+func matchesInstanceLabels(instanceLabels map[string]string, labels v3.Labels) bool {
+	if len(instanceLabels) == 0 {
+		return false
+	}
+
+	for k, v := range instanceLabels {
+		if labels[k] != v {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (r *sksAgentRunnerNodeCSRValidation) approveCSR(
+	ctx context.Context,
+	csr *k8scertv1.CertificateSigningRequest,
+	match *nodeCSRMatch,
+) error {
+	csr = csr.DeepCopy()
+	csr.Status.Conditions = append(csr.Status.Conditions, k8scertv1.CertificateSigningRequestCondition{
+		Type:           k8scertv1.CertificateApproved,
+		Status:         corev1.ConditionTrue,
+		Reason:         sksAgentNodeCSRValidationApprovalReason,
+		Message:        sksAgentNodeCSRValidationApprovalMessage,
+		LastUpdateTime: metav1.Now(),
+	})
+
+	_, err := r.p.kclient.
+		CertificatesV1().
+		CertificateSigningRequests().
+		UpdateApproval(ctx, csr.Name, csr, metav1.UpdateOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to approve CSR: %w", err)
 	}
+
+	infof("sks-agent: CSR %s approved", csr.Name)
+	r.p.normalEventf(csr, eventReasonNodeCSRApproved, "%s", sksAgentNodeCSRValidationApprovalMessage)
+	metricSKSAgentNodeCSRsApproved.Inc()
+
+	r.annotateNode(ctx, match)
+
+	return nil
+}
+
+// annotateNode records on the Node the ID of the Compute instance its serving
+// certificate has been issued for, if the Node is registered.
+func (r *sksAgentRunnerNodeCSRValidation) annotateNode(ctx context.Context, match *nodeCSRMatch) {
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"annotations": map[string]string{
+				sksAgentNodeCSRValidationInstanceIDAnnotation: match.instanceID.String(),
+			},
+		},
+	})
+	if err != nil {
+		errorf("sks-agent: failed to annotate Node %s: %v", match.nodeName, err)
+		return
+	}
+
+	_, err = r.p.kclient.CoreV1().Nodes().Patch(ctx, match.nodeName, types.MergePatchType, patch, metav1.PatchOptions{})
+	if err != nil && !apierrors.IsNotFound(err) {
+		warnf("sks-agent: failed to annotate Node %s: %v", match.nodeName, err)
+	}
+}
+
+func (r *sksAgentRunnerNodeCSRValidation) denyCSR(
+	ctx context.Context,
+	csr *k8scertv1.CertificateSigningRequest,
+	validationErr *nodeCSRValidationError,
+) error {
+	csr = csr.DeepCopy()
+	csr.Status.Conditions = append(csr.Status.Conditions, k8scertv1.CertificateSigningRequestCondition{
+		Type:           k8scertv1.CertificateDenied,
+		Status:         corev1.ConditionTrue,
+		Reason:         validationErr.reason,
+		Message:        validationErr.Error(),
+		LastUpdateTime: metav1.Now(),
+	})
+
+	_, err := r.p.kclient.
+		CertificatesV1().
+		CertificateSigningRequests().
+		UpdateApproval(ctx, csr.Name, csr, metav1.UpdateOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to deny CSR: %w", err)
+	}
+
+	infof("sks-agent: CSR %s denied (%s): %v", csr.Name, validationErr.reason, validationErr)
+	r.p.warningEventf(csr, eventReasonNodeCSRDenied, "%s: %v", validationErr.reason, validationErr)
+	metricSKSAgentNodeCSRsDenied.WithLabelValues(validationErr.reason).Inc()
+
+	return nil
 }
 
 func (r *sksAgentRunnerNodeCSRValidation) hasRequiredGroups(csr *k8scertv1.CertificateSigningRequest) bool {
@@ -205,3 +868,23 @@ func (r *sksAgentRunnerNodeCSRValidation) parseCSR(pemData []byte) (*x509.Certif
 
 	return x509.ParseCertificateRequest(block.Bytes)
 }
+
+func (c *refreshableExoscaleClient) GetElasticIP(ctx context.Context, id v3.UUID) (*v3.ElasticIP, error) {
+	ctx, cancel := c.withTimeout(ctx, "GetElasticIP")
+	defer cancel()
+
+	return c.client().GetElasticIP(
+		ctx,
+		id,
+	)
+}
+
+func (c *refreshableExoscaleClient) GetPrivateNetwork(ctx context.Context, id v3.UUID) (*v3.PrivateNetwork, error) {
+	ctx, cancel := c.withTimeout(ctx, "GetPrivateNetwork")
+	defer cancel()
+
+	return c.client().GetPrivateNetwork(
+		ctx,
+		id,
+	)
+}
@@ -19,12 +19,16 @@ import (
 	k8scertv1 "k8s.io/api/certificates/v1"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
 	types "k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/watch"
 	applyconfigurationscertificatesv1 "k8s.io/client-go/applyconfigurations/certificates/v1"
 	fakek8s "k8s.io/client-go/kubernetes/fake"
 	certificatesv1 "k8s.io/client-go/kubernetes/typed/certificates/v1"
 	fakecertificatesv1 "k8s.io/client-go/kubernetes/typed/certificates/v1/fake"
+	certlisters "k8s.io/client-go/listers/certificates/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
 )
 
 func (ts *exoscaleCCMTestSuite) generateK8sCSR(nodeName string, nodeIPAddresses []string) []byte {
@@ -45,7 +49,7 @@ func (ts *exoscaleCCMTestSuite) generateK8sCSR(nodeName string, nodeIPAddresses
 			SignatureAlgorithm: x509.SHA512WithRSA,
 			Subject: pkix.Name{
 				Organization: []string{"system:nodes"},
-				CommonName:   "system:nodes:" + k8sNodeName,
+				CommonName:   "system:node:" + k8sNodeName,
 			},
 			DNSNames:    []string{k8sNodeName},
 			IPAddresses: ipAddresses,
@@ -60,6 +64,17 @@ func (ts *exoscaleCCMTestSuite) generateK8sCSR(nodeName string, nodeIPAddresses
 	return csrBuf.Bytes()
 }
 
+// generateK8sCSRSpec returns the spec of a valid kubelet serving CSR.
+func (ts *exoscaleCCMTestSuite) generateK8sCSRSpec(nodeName string, nodeIPAddresses []string) k8scertv1.CertificateSigningRequestSpec {
+	return k8scertv1.CertificateSigningRequestSpec{
+		Request:    ts.generateK8sCSR(nodeName, nodeIPAddresses),
+		SignerName: k8scertv1.KubeletServingSignerName,
+		Username:   "system:node:" + strings.ToLower(nodeName),
+		Groups:     []string{"system:authenticated", "system:nodes"},
+		Usages:     []k8scertv1.KeyUsage{k8scertv1.UsageDigitalSignature, k8scertv1.UsageServerAuth},
+	}
+}
+
 type certificateSigningRequestMockWatcher struct {
 	eventChan <-chan watch.Event
 }
@@ -157,6 +172,28 @@ func (m *k8sClientMock) CertificatesV1() certificatesv1.CertificatesV1Interface
 	}
 }
 
+// withNodeCSRValidationSKSCluster restricts the Node CSR validation to the
+// instances of an SKS cluster, returning the manager of its instances.
+func (ts *exoscaleCCMTestSuite) withNodeCSRValidationSKSCluster() *v3.Manager {
+	var (
+		sksClusterID  = ts.randomID()
+		sksNodepoolID = v3.UUID(ts.randomID())
+	)
+
+	cfg := *ts.p.cfg
+	cfg.SKSAgent.NodeCSRValidation.SKSClusterID = sksClusterID
+	ts.p.cfg = &cfg
+
+	ts.p.client.(*exoscaleClientMock).
+		On("ListSKSClusters", mock.Anything).
+		Return(&v3.ListSKSClustersResponse{SKSClusters: []v3.SKSCluster{{
+			ID:        v3.UUID(sksClusterID),
+			Nodepools: []v3.SKSNodepool{{ID: sksNodepoolID}},
+		}}}, nil)
+
+	return &v3.Manager{ID: sksNodepoolID, Type: v3.ManagerTypeSKSNodepool}
+}
+
 func (ts *exoscaleCCMTestSuite) Test_sksAgentRunnerNodeCSRValidation_hasRequiredGroups() {
 	type args struct {
 		csr *k8scertv1.CertificateSigningRequest
@@ -245,11 +282,7 @@ func (ts *exoscaleCCMTestSuite) Test_sksAgentRunnerNodeCSRValidation_run() {
 				Kind:       "CertificateSigningRequest",
 			},
 			ObjectMeta: metav1.ObjectMeta{Name: csrName},
-			Spec: k8scertv1.CertificateSigningRequestSpec{
-				Request:    ts.generateK8sCSR(testInstanceName, []string{testInstancePublicIPv4, testInstancePublicIPv6}),
-				SignerName: "kubernetes.io/kubelet-serving",
-				Groups:     []string{"system:authenticated", "system:nodes"},
-			},
+			Spec:       ts.generateK8sCSRSpec(testInstanceName, []string{testInstancePublicIPv4, testInstancePublicIPv6}),
 		}
 	)
 
@@ -268,6 +301,7 @@ func (ts *exoscaleCCMTestSuite) Test_sksAgentRunnerNodeCSRValidation_run() {
 		},
 	}
 
+	manager := ts.withNodeCSRValidationSKSCluster()
 	ts.p.client.(*exoscaleClientMock).
 		On("ListInstances", mock.Anything, mock.Anything).
 		Return(&v3.ListInstancesResponse{
@@ -275,6 +309,7 @@ func (ts *exoscaleCCMTestSuite) Test_sksAgentRunnerNodeCSRValidation_run() {
 				Name:        testInstanceName,
 				PublicIP:    testInstancePublicIPv4P,
 				Ipv6Address: testInstancePublicIPv6P.String(),
+				Manager:     manager,
 			}},
 		},
 			nil,
@@ -283,15 +318,84 @@ func (ts *exoscaleCCMTestSuite) Test_sksAgentRunnerNodeCSRValidation_run() {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
+	// The pending CSR is picked up from the initial listing of the informer.
 	nodeCSRValidationRunner := &sksAgentRunnerNodeCSRValidation{p: ts.p}
 	go nodeCSRValidationRunner.run(ctx)
 
-	time.Sleep(1 * time.Second)
-	k8sEventChan <- watch.Event{
-		Type:   watch.Added,
-		Object: k8sCSR,
+	ts.Require().Eventually(
+		func() bool {
+			result.RLock()
+			defer result.RUnlock()
+			return result.approved
+		},
+		3*time.Second,
+		time.Second,
+		"CSR has not been approved before timeout",
+	)
+	ts.Require().Eventually(
+		func() bool { return len(ts.p.recorder.(*record.FakeRecorder).Events) == 1 },
+		time.Second,
+		10*time.Millisecond,
+	)
+	ts.Require().Contains(<-ts.p.recorder.(*record.FakeRecorder).Events, "Normal "+eventReasonNodeCSRApproved)
+}
+
+func (ts *exoscaleCCMTestSuite) Test_sksAgentRunnerNodeCSRValidation_run_retry() {
+	type csrValidationResult struct {
+		sync.RWMutex
+		approved bool
+	}
+
+	var (
+		csrName = "csr-" + strings.ToLower(ts.randomString(5))
+		result  = csrValidationResult{RWMutex: sync.RWMutex{}}
+
+		k8sCSR = &k8scertv1.CertificateSigningRequest{
+			ObjectMeta: metav1.ObjectMeta{Name: csrName},
+			Spec:       ts.generateK8sCSRSpec(testInstanceName, []string{testInstancePublicIPv4}),
+		}
+	)
+
+	defer func(minBackoff, maxBackoff time.Duration) {
+		sksAgentNodeCSRValidationRetryMinBackoff = minBackoff
+		sksAgentNodeCSRValidationRetryMaxBackoff = maxBackoff
+	}(sksAgentNodeCSRValidationRetryMinBackoff, sksAgentNodeCSRValidationRetryMaxBackoff)
+	sksAgentNodeCSRValidationRetryMinBackoff = 10 * time.Millisecond
+	sksAgentNodeCSRValidationRetryMaxBackoff = 100 * time.Millisecond
+
+	ts.p.kclient = &k8sClientMock{
+		eventChan: make(chan watch.Event),
+		Clientset: fakek8s.NewSimpleClientset(k8sCSR),
+		csrApprovalTestFunc: func(_ string, _ *k8scertv1.CertificateSigningRequest) {
+			result.Lock()
+			defer result.Unlock()
+			result.approved = true
+		},
 	}
 
+	// The Compute instance of the Node isn't listed at first: the CSR must be
+	// evaluated again until it is.
+	manager := ts.withNodeCSRValidationSKSCluster()
+	ts.p.client.(*exoscaleClientMock).
+		On("ListInstances", mock.Anything, mock.Anything).
+		Return(&v3.ListInstancesResponse{}, nil).
+		Twice()
+	ts.p.client.(*exoscaleClientMock).
+		On("ListInstances", mock.Anything, mock.Anything).
+		Return(&v3.ListInstancesResponse{
+			Instances: []v3.ListInstancesResponseInstances{{
+				Name:     testInstanceName,
+				PublicIP: testInstancePublicIPv4P,
+				Manager:  manager,
+			}},
+		}, nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	nodeCSRValidationRunner := &sksAgentRunnerNodeCSRValidation{p: ts.p}
+	go nodeCSRValidationRunner.run(ctx)
+
 	ts.Require().Eventually(
 		func() bool {
 			result.RLock()
@@ -299,7 +403,482 @@ func (ts *exoscaleCCMTestSuite) Test_sksAgentRunnerNodeCSRValidation_run() {
 			return result.approved
 		},
 		3*time.Second,
-		time.Second,
+		100*time.Millisecond,
 		"CSR has not been approved before timeout",
 	)
+	ts.p.client.(*exoscaleClientMock).AssertNumberOfCalls(ts.T(), "ListInstances", 3)
+}
+
+func (ts *exoscaleCCMTestSuite) Test_sksAgentRunnerNodeCSRValidation_run_deny() {
+	type csrValidationResult struct {
+		sync.RWMutex
+		conditions map[string]k8scertv1.CertificateSigningRequestCondition
+	}
+
+	var (
+		result = csrValidationResult{conditions: make(map[string]k8scertv1.CertificateSigningRequestCondition)}
+
+		newCSR = func(name string, spec k8scertv1.CertificateSigningRequestSpec, age time.Duration) *k8scertv1.CertificateSigningRequest {
+			return &k8scertv1.CertificateSigningRequest{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:              name,
+					CreationTimestamp: metav1.NewTime(time.Now().Add(-age)),
+				},
+				Spec: spec,
+			}
+		}
+		invalidSpec = ts.generateK8sCSRSpec(testInstanceName, nil)
+
+		// Invalid CSRs are denied immediately if they can never be valid, or
+		// else once the denial grace period has elapsed.
+		csrIPAddressMismatch = newCSR("csr-ip", ts.generateK8sCSRSpec(testInstanceName, []string{"192.0.2.1"}), time.Hour)
+		csrInvalidRequest    = newCSR("csr-invalid", invalidSpec, 0)
+		csrRecent            = newCSR("csr-recent", ts.generateK8sCSRSpec("unknown", nil), 0)
+	)
+
+	invalidSpec.Request = []byte("invalid")
+	csrInvalidRequest.Spec = invalidSpec
+
+	ts.p.kclient = &k8sClientMock{
+		eventChan: make(chan watch.Event),
+		Clientset: fakek8s.NewSimpleClientset(csrIPAddressMismatch, csrInvalidRequest, csrRecent),
+		csrApprovalTestFunc: func(name string, csr *k8scertv1.CertificateSigningRequest) {
+			result.Lock()
+			defer result.Unlock()
+			result.conditions[name] = csr.Status.Conditions[0]
+		},
+	}
+
+	ts.p.cfg = &cloudConfig{SKSAgent: sksAgentConfig{
+		NodeCSRValidation: sksAgentNodeCSRValidationConfig{Deny: true},
+	}}
+	manager := ts.withNodeCSRValidationSKSCluster()
+	ts.p.client.(*exoscaleClientMock).
+		On("ListInstances", mock.Anything, mock.Anything).
+		Return(&v3.ListInstancesResponse{
+			Instances: []v3.ListInstancesResponseInstances{{
+				Name:     testInstanceName,
+				PublicIP: testInstancePublicIPv4P,
+				Manager:  manager,
+			}},
+		}, nil)
+	ts.p.client.(*exoscaleClientMock).
+		On("GetInstance", mock.Anything, mock.Anything).
+		Return(&v3.Instance{}, nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	nodeCSRValidationRunner := &sksAgentRunnerNodeCSRValidation{p: ts.p}
+	go nodeCSRValidationRunner.run(ctx)
+
+	ts.Require().Eventually(
+		func() bool {
+			result.RLock()
+			defer result.RUnlock()
+			return len(result.conditions) == 2
+		},
+		3*time.Second,
+		100*time.Millisecond,
+		"CSRs have not been denied before timeout",
+	)
+
+	result.RLock()
+	defer result.RUnlock()
+	for name, reason := range map[string]string{
+		csrIPAddressMismatch.Name: sksAgentNodeCSRValidationDenialReasonIPAddressMismatch,
+		csrInvalidRequest.Name:    sksAgentNodeCSRValidationDenialReasonInvalidRequest,
+	} {
+		ts.Require().Equal(k8scertv1.CertificateDenied, result.conditions[name].Type, name)
+		ts.Require().Equal(reason, result.conditions[name].Reason, name)
+		ts.Require().NotEmpty(result.conditions[name].Message, name)
+	}
+	ts.Require().NotContains(result.conditions, csrRecent.Name)
+
+	events := ts.p.recorder.(*record.FakeRecorder).Events
+	ts.Require().Len(events, 2)
+	ts.Require().Contains(<-events, "Warning "+eventReasonNodeCSRDenied)
+}
+
+func (ts *exoscaleCCMTestSuite) Test_sksAgentRunnerNodeCSRValidation_pending() {
+	r := &sksAgentRunnerNodeCSRValidation{
+		p:       ts.p,
+		pending: map[string]struct{}{"approved": {}, "deleted": {}, "tombstone": {}, "other": {}},
+	}
+
+	newCSR := func(name string) *k8scertv1.CertificateSigningRequest {
+		return &k8scertv1.CertificateSigningRequest{ObjectMeta: metav1.ObjectMeta{Name: name}}
+	}
+
+	// Approved (or denied) by someone else.
+	approved := newCSR("approved")
+	approved.Status.Conditions = []k8scertv1.CertificateSigningRequestCondition{{
+		Type:   k8scertv1.CertificateApproved,
+		Status: corev1.ConditionTrue,
+	}}
+	r.enqueue(approved)
+
+	r.forget(newCSR("deleted"))
+	r.forget(cache.DeletedFinalStateUnknown{Key: "tombstone", Obj: newCSR("tombstone")})
+
+	ts.Require().Equal(map[string]struct{}{"other": {}}, r.pending)
+}
+
+func (ts *exoscaleCCMTestSuite) Test_sksAgentRunnerNodeCSRValidation_validateCSR() {
+	var (
+		sksClusterID      = ts.randomID()
+		sksNodepoolID     = v3.UUID(ts.randomID())
+		otherNodepoolID   = v3.UUID(ts.randomID())
+		clusterInstanceID = v3.UUID(ts.randomID())
+		peerInstanceID    = v3.UUID(ts.randomID())
+		labeledInstanceID = v3.UUID(ts.randomID())
+		otherInstanceID   = v3.UUID(ts.randomID())
+
+		csr = &k8scertv1.CertificateSigningRequest{
+			Spec: ts.generateK8sCSRSpec(testInstanceName, []string{testInstancePublicIPv4}),
+		}
+
+		newInstance = func(id v3.UUID, manager *v3.Manager, labels v3.Labels) v3.ListInstancesResponseInstances {
+			return v3.ListInstancesResponseInstances{
+				ID:       id,
+				Name:     testInstanceName,
+				PublicIP: testInstancePublicIPv4P,
+				Manager:  manager,
+				Labels:   labels,
+			}
+		}
+		clusterInstance = newInstance(clusterInstanceID, &v3.Manager{ID: sksNodepoolID, Type: v3.ManagerTypeSKSNodepool}, nil)
+		twinInstance    = newInstance(v3.UUID(ts.randomID()), &v3.Manager{ID: sksNodepoolID, Type: v3.ManagerTypeSKSNodepool}, nil)
+		peerInstance    = newInstance(peerInstanceID, &v3.Manager{ID: sksNodepoolID, Type: v3.ManagerTypeSKSNodepool}, nil)
+		labeledInstance = newInstance(labeledInstanceID, nil, v3.Labels{"role": "node"})
+		otherInstance   = newInstance(otherInstanceID, &v3.Manager{ID: otherNodepoolID, Type: v3.ManagerTypeSKSNodepool}, nil)
+	)
+
+	peerInstance.Name = "peer"
+
+	tests := []struct {
+		name           string
+		instances      []v3.ListInstancesResponseInstances
+		sksClusterID   string
+		instanceLabels map[string]string
+		providerID     string
+		peerNode       bool
+		wantErr        bool
+		wantReason     string
+	}{
+		{
+			name:         "SKS cluster instance",
+			instances:    []v3.ListInstancesResponseInstances{otherInstance, clusterInstance},
+			sksClusterID: sksClusterID,
+		},
+		{
+			name:         "other SKS cluster instance",
+			instances:    []v3.ListInstancesResponseInstances{otherInstance},
+			sksClusterID: sksClusterID,
+			wantReason:   sksAgentNodeCSRValidationDenialReasonNoMatchingInstance,
+		},
+		{
+			name:           "labeled instance",
+			instances:      []v3.ListInstancesResponseInstances{otherInstance, labeledInstance},
+			sksClusterID:   sksClusterID,
+			instanceLabels: map[string]string{"role": "node"},
+		},
+		{
+			name:      "SKS cluster determined from the Nodes",
+			instances: []v3.ListInstancesResponseInstances{otherInstance, clusterInstance, peerInstance},
+			peerNode:  true,
+		},
+		{
+			name:       "other SKS cluster instance excluded by default",
+			instances:  []v3.ListInstancesResponseInstances{otherInstance, peerInstance},
+			peerNode:   true,
+			wantReason: sksAgentNodeCSRValidationDenialReasonNoMatchingInstance,
+		},
+		{
+			name:      "undetermined SKS cluster",
+			instances: []v3.ListInstancesResponseInstances{otherInstance, clusterInstance},
+			wantErr:   true,
+		},
+		{
+			name:       "ambiguous instance name",
+			instances:  []v3.ListInstancesResponseInstances{twinInstance, clusterInstance, peerInstance},
+			peerNode:   true,
+			wantReason: sksAgentNodeCSRValidationDenialReasonAmbiguousInstanceName,
+		},
+		{
+			name:       "instance matched by Node provider ID",
+			instances:  []v3.ListInstancesResponseInstances{twinInstance, clusterInstance},
+			providerID: providerPrefix + clusterInstanceID.String(),
+		},
+		{
+			name:       "Node provider ID mismatch",
+			instances:  []v3.ListInstancesResponseInstances{otherInstance, peerInstance},
+			providerID: providerPrefix + clusterInstanceID.String(),
+			peerNode:   true,
+			wantReason: sksAgentNodeCSRValidationDenialReasonNoMatchingInstance,
+		},
+	}
+
+	for _, tt := range tests {
+		ts.Run(tt.name, func() {
+			ts.p.client = new(exoscaleClientMock)
+			ts.p.client.(*exoscaleClientMock).
+				On("ListInstances", mock.Anything, mock.Anything).
+				Return(&v3.ListInstancesResponse{Instances: tt.instances}, nil)
+			ts.p.client.(*exoscaleClientMock).
+				On("ListSKSClusters", mock.Anything).
+				Return(&v3.ListSKSClustersResponse{SKSClusters: []v3.SKSCluster{{
+					ID:        v3.UUID(sksClusterID),
+					Nodepools: []v3.SKSNodepool{{ID: sksNodepoolID}},
+				}}}, nil)
+
+			// Another Node of the cluster, whose instance is managed by a
+			// nodepool of the SKS cluster.
+			var nodes []runtime.Object
+			if tt.peerNode {
+				nodes = append(nodes, &corev1.Node{
+					ObjectMeta: metav1.ObjectMeta{Name: "peer"},
+					Spec:       corev1.NodeSpec{ProviderID: providerPrefix + peerInstanceID.String()},
+				})
+			}
+			if tt.providerID != "" {
+				nodes = append(nodes, &corev1.Node{
+					ObjectMeta: metav1.ObjectMeta{Name: strings.ToLower(testInstanceName)},
+					Spec:       corev1.NodeSpec{ProviderID: tt.providerID},
+				})
+			}
+			ts.p.kclient = fakek8s.NewSimpleClientset(nodes...)
+
+			ts.p.cfg = &cloudConfig{SKSAgent: sksAgentConfig{
+				NodeCSRValidation: sksAgentNodeCSRValidationConfig{
+					SKSClusterID:   tt.sksClusterID,
+					InstanceLabels: tt.instanceLabels,
+				},
+			}}
+			runner := &sksAgentRunnerNodeCSRValidation{p: ts.p}
+			_, err := runner.validateCSR(ts.p.ctx, csr)
+			var validationErr *nodeCSRValidationError
+			switch {
+			case tt.wantErr:
+				// The CSR is left pending, neither approved nor denied.
+				ts.Require().Error(err)
+				ts.Require().NotErrorAs(err, &validationErr)
+			case tt.wantReason == "":
+				ts.Require().NoError(err)
+			default:
+				ts.Require().ErrorAs(err, &validationErr)
+				ts.Require().Equal(tt.wantReason, validationErr.reason)
+			}
+		})
+	}
+}
+
+func (ts *exoscaleCCMTestSuite) Test_sksAgentRunnerNodeCSRValidation_validateCSR_addresses() {
+	var (
+		instanceID       = v3.UUID(ts.randomID())
+		privateNetworkID = v3.UUID(ts.randomID())
+		elasticIPID      = v3.UUID(ts.randomID())
+		elasticIP        = "192.0.2.10"
+		leasedIP         = "10.0.0.42"
+	)
+
+	manager := ts.withNodeCSRValidationSKSCluster()
+	ts.p.client.(*exoscaleClientMock).
+		On("ListInstances", mock.Anything, mock.Anything).
+		Return(&v3.ListInstancesResponse{Instances: []v3.ListInstancesResponseInstances{{
+			ID:              instanceID,
+			Name:            testInstanceName,
+			PublicIP:        testInstancePublicIPv4P,
+			Ipv6Address:     testInstancePublicIPv6,
+			PrivateNetworks: []v3.ListInstancesResponseInstancesPrivateNetworks{{ID: privateNetworkID}},
+			Manager:         manager,
+		}}}, nil)
+	ts.p.client.(*exoscaleClientMock).
+		On("GetInstance", mock.Anything, instanceID).
+		Return(&v3.Instance{
+			ID:              instanceID,
+			ElasticIPS:      []v3.ElasticIP{{ID: elasticIPID}},
+			PrivateNetworks: []v3.InstancePrivateNetworks{{ID: privateNetworkID}},
+		}, nil)
+	ts.p.client.(*exoscaleClientMock).
+		On("GetElasticIP", mock.Anything, elasticIPID).
+		Return(&v3.ElasticIP{ID: elasticIPID, IP: elasticIP}, nil)
+	ts.p.client.(*exoscaleClientMock).
+		On("GetPrivateNetwork", mock.Anything, privateNetworkID).
+		Return(&v3.PrivateNetwork{ID: privateNetworkID, Leases: []v3.PrivateNetworkLease{
+			{InstanceID: v3.UUID(ts.randomID()), IP: net.ParseIP("10.0.0.43")},
+			{InstanceID: instanceID, IP: net.ParseIP(leasedIP)},
+		}}, nil)
+
+	runner := &sksAgentRunnerNodeCSRValidation{p: ts.p}
+	validateCSR := func(ips ...string) error {
+		_, err := runner.validateCSR(ts.p.ctx, &k8scertv1.CertificateSigningRequest{
+			Spec: ts.generateK8sCSRSpec(testInstanceName, ips),
+		})
+		return err
+	}
+
+	// The public IP addresses don't require any additional API call.
+	ts.Require().NoError(validateCSR(testInstancePublicIPv4, testInstancePublicIPv6))
+	ts.p.client.(*exoscaleClientMock).AssertNotCalled(ts.T(), "GetInstance", mock.Anything, mock.Anything)
+
+	ts.Require().NoError(validateCSR(testInstancePublicIPv4, elasticIP, leasedIP))
+
+	var validationErr *nodeCSRValidationError
+	ts.Require().ErrorAs(validateCSR(leasedIP, "10.0.0.43"), &validationErr)
+	ts.Require().Equal(sksAgentNodeCSRValidationDenialReasonIPAddressMismatch, validationErr.reason)
+}
+
+func (ts *exoscaleCCMTestSuite) Test_sksAgentRunnerNodeCSRValidation_validateCSR_semantics() {
+	var (
+		instanceID = v3.UUID(ts.randomID())
+		nodeName   = strings.ToLower(testInstanceName)
+	)
+
+	privateKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	ts.Require().NoError(err)
+	otherSubjectCSR, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+		Subject:  pkix.Name{Organization: []string{"system:nodes"}, CommonName: "system:node:other"},
+		DNSNames: []string{nodeName},
+	}, privateKey)
+	ts.Require().NoError(err)
+
+	tests := []struct {
+		name           string
+		spec           func(*k8scertv1.CertificateSigningRequestSpec)
+		nodeInstanceID string
+		wantReason     string
+	}{
+		{
+			name: "valid",
+			spec: func(*k8scertv1.CertificateSigningRequestSpec) {},
+		},
+		{
+			name: "key encipherment usage",
+			spec: func(spec *k8scertv1.CertificateSigningRequestSpec) {
+				spec.Usages = append(spec.Usages, k8scertv1.UsageKeyEncipherment)
+			},
+		},
+		{
+			name: "unexpected usage",
+			spec: func(spec *k8scertv1.CertificateSigningRequestSpec) {
+				spec.Usages = append(spec.Usages, k8scertv1.UsageClientAuth)
+			},
+			wantReason: sksAgentNodeCSRValidationDenialReasonInvalidUsages,
+		},
+		{
+			name: "duplicate usage",
+			spec: func(spec *k8scertv1.CertificateSigningRequestSpec) {
+				spec.Usages = append(spec.Usages, k8scertv1.UsageServerAuth)
+			},
+			wantReason: sksAgentNodeCSRValidationDenialReasonInvalidUsages,
+		},
+		{
+			name: "missing usage",
+			spec: func(spec *k8scertv1.CertificateSigningRequestSpec) {
+				spec.Usages = []k8scertv1.KeyUsage{k8scertv1.UsageServerAuth}
+			},
+			wantReason: sksAgentNodeCSRValidationDenialReasonInvalidUsages,
+		},
+		{
+			name: "other requester",
+			spec: func(spec *k8scertv1.CertificateSigningRequestSpec) {
+				spec.Username = "system:node:other"
+			},
+			wantReason: sksAgentNodeCSRValidationDenialReasonInvalidRequester,
+		},
+		{
+			name: "other subject",
+			spec: func(spec *k8scertv1.CertificateSigningRequestSpec) {
+				csrBuf := bytes.NewBuffer(nil)
+				ts.Require().NoError(pem.Encode(csrBuf, &pem.Block{Type: "CERTIFICATE REQUEST", Bytes: otherSubjectCSR}))
+				spec.Request = csrBuf.Bytes()
+			},
+			wantReason: sksAgentNodeCSRValidationDenialReasonInvalidSubject,
+		},
+		{
+			name:           "Node certificate issued for the same instance",
+			spec:           func(*k8scertv1.CertificateSigningRequestSpec) {},
+			nodeInstanceID: instanceID.String(),
+		},
+		{
+			name:           "Node certificate issued for another instance",
+			spec:           func(*k8scertv1.CertificateSigningRequestSpec) {},
+			nodeInstanceID: ts.randomID(),
+			wantReason:     sksAgentNodeCSRValidationDenialReasonInstanceMismatch,
+		},
+	}
+
+	manager := ts.withNodeCSRValidationSKSCluster()
+	ts.p.client.(*exoscaleClientMock).
+		On("ListInstances", mock.Anything, mock.Anything).
+		Return(&v3.ListInstancesResponse{Instances: []v3.ListInstancesResponseInstances{{
+			ID:       instanceID,
+			Name:     testInstanceName,
+			PublicIP: testInstancePublicIPv4P,
+			Manager:  manager,
+		}}}, nil)
+
+	for _, tt := range tests {
+		ts.Run(tt.name, func() {
+			ts.p.kclient = fakek8s.NewSimpleClientset()
+			if tt.nodeInstanceID != "" {
+				ts.p.kclient = fakek8s.NewSimpleClientset(&corev1.Node{ObjectMeta: metav1.ObjectMeta{
+					Name:        nodeName,
+					Annotations: map[string]string{sksAgentNodeCSRValidationInstanceIDAnnotation: tt.nodeInstanceID},
+				}})
+			}
+
+			csr := &k8scertv1.CertificateSigningRequest{Spec: ts.generateK8sCSRSpec(testInstanceName, nil)}
+			tt.spec(&csr.Spec)
+
+			runner := &sksAgentRunnerNodeCSRValidation{p: ts.p}
+			match, err := runner.validateCSR(ts.p.ctx, csr)
+			if tt.wantReason == "" {
+				ts.Require().NoError(err)
+				ts.Require().Equal(&nodeCSRMatch{nodeName: nodeName, instanceID: instanceID}, match)
+				return
+			}
+
+			var validationErr *nodeCSRValidationError
+			ts.Require().ErrorAs(err, &validationErr)
+			ts.Require().Equal(tt.wantReason, validationErr.reason)
+			ts.Require().True(validationErr.permanent)
+		})
+	}
+}
+
+func (ts *exoscaleCCMTestSuite) Test_sksAgentRunnerNodeCSRValidation_processCSR_signerName() {
+	csr := &k8scertv1.CertificateSigningRequest{
+		ObjectMeta: metav1.ObjectMeta{Name: "csr-client"},
+		Spec:       ts.generateK8sCSRSpec(testInstanceName, nil),
+	}
+	csr.Spec.SignerName = k8scertv1.KubeAPIServerClientKubeletSignerName
+
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	ts.Require().NoError(indexer.Add(csr))
+
+	// CSRs of other signers are left untouched.
+	runner := &sksAgentRunnerNodeCSRValidation{p: ts.p, lister: certlisters.NewCertificateSigningRequestLister(indexer)}
+	ts.Require().NoError(runner.processCSR(ts.p.ctx, csr.Name))
+	ts.p.client.(*exoscaleClientMock).AssertNotCalled(ts.T(), "ListInstances", mock.Anything, mock.Anything)
+}
+
+func (ts *exoscaleCCMTestSuite) Test_sksAgentRunnerNodeCSRValidation_annotateNode() {
+	var (
+		instanceID = v3.UUID(ts.randomID())
+		nodeName   = strings.ToLower(testInstanceName)
+	)
+
+	ts.p.kclient = fakek8s.NewSimpleClientset(&corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: nodeName}})
+
+	runner := &sksAgentRunnerNodeCSRValidation{p: ts.p}
+	runner.annotateNode(ts.p.ctx, &nodeCSRMatch{nodeName: nodeName, instanceID: instanceID})
+
+	node, err := ts.p.kclient.CoreV1().Nodes().Get(ts.p.ctx, nodeName, metav1.GetOptions{})
+	ts.Require().NoError(err)
+	ts.Require().Equal(instanceID.String(), node.Annotations[sksAgentNodeCSRValidationInstanceIDAnnotation])
+
+	// Unregistered Nodes are skipped.
+	runner.annotateNode(ts.p.ctx, &nodeCSRMatch{nodeName: "unregistered", instanceID: instanceID})
 }
@@ -0,0 +1,272 @@
+package exoscale
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// sksAgentRunnerMock is an SKS agent runner counting the instances running,
+// which panics or exits unexpectedly on its first runs if required.
+type sksAgentRunnerMock struct {
+	running atomic.Int32
+	runs    atomic.Int32
+
+	panics int32 // number of runs panicking
+	exits  int32 // number of runs exiting unexpectedly (after the panicking ones)
+
+	health atomic.Pointer[error]
+}
+
+func (r *sksAgentRunnerMock) run(ctx context.Context) {
+	r.running.Add(1)
+	defer r.running.Add(-1)
+
+	run := r.runs.Add(1)
+	switch {
+	case run <= r.panics:
+		panic("runner mock panic")
+	case run <= r.panics+r.exits:
+		return
+	}
+	<-ctx.Done()
+}
+
+func (r *sksAgentRunnerMock) healthy() error {
+	if err := r.health.Load(); err != nil {
+		return *err
+	}
+
+	return nil
+}
+
+// definition returns a definition of the runner, every instance of it being
+// the mock itself.
+func (r *sksAgentRunnerMock) definition(name string, dependencies ...string) sksAgentRunnerDefinition {
+	return sksAgentRunnerDefinition{
+		name:         name,
+		dependencies: dependencies,
+		new:          func(*cloudProvider) sksAgentRunner { return r },
+	}
+}
+
+// registerTestSKSAgentRunner registers an SKS agent runner for the duration of
+// the test.
+func (ts *exoscaleCCMTestSuite) registerTestSKSAgentRunner(def sksAgentRunnerDefinition) {
+	registerSKSAgentRunner(def)
+	ts.T().Cleanup(func() { delete(sksAgentRunners, def.name) })
+}
+
+func (ts *exoscaleCCMTestSuite) Test_sksAgentRunnersStartOrder() {
+	runner := new(sksAgentRunnerMock)
+	ts.registerTestSKSAgentRunner(runner.definition("test-a", "test-b", "test-c"))
+	ts.registerTestSKSAgentRunner(runner.definition("test-b", "test-c"))
+	ts.registerTestSKSAgentRunner(runner.definition("test-c"))
+	ts.registerTestSKSAgentRunner(runner.definition("test-cycle-a", "test-cycle-b"))
+	ts.registerTestSKSAgentRunner(runner.definition("test-cycle-b", "test-cycle-a"))
+
+	defs, err := sksAgentRunnersStartOrder([]string{"test-a", sksAgentNodeCSRValidation, "test-c", "test-b"})
+	ts.Require().NoError(err)
+	var names []string
+	for _, def := range defs {
+		names = append(names, def.name)
+	}
+	ts.Require().Equal([]string{"test-c", "test-b", "test-a", sksAgentNodeCSRValidation}, names)
+
+	_, err = sksAgentRunnersStartOrder([]string{"test-a", "test-b"})
+	ts.Require().EqualError(err, `runner "test-b" requires runner "test-c" to be enabled`)
+
+	_, err = sksAgentRunnersStartOrder([]string{"test-cycle-a", "test-cycle-b"})
+	ts.Require().ErrorContains(err, "circular runner dependency")
+
+	_, err = sksAgentRunnersStartOrder([]string{"test-d"})
+	ts.Require().EqualError(err, `unsupported runner "test-d"`)
+
+	// The dependencies are checked by the configuration validation.
+	ts.Require().ErrorContains(
+		(&sksAgentConfig{Runners: []string{"test-b"}}).validate(),
+		`sksAgent.runners: runner "test-b" requires runner "test-c" to be enabled`,
+	)
+	ts.Require().Panics(func() { registerSKSAgentRunner(runner.definition("test-c")) })
+}
+
+func (ts *exoscaleCCMTestSuite) Test_sksAgent_run_leaderElection() {
+	cfg := sksAgentConfig{LeaderElection: sksAgentLeaderElectionConfig{
+		LeaseDuration: 2 * time.Second,
+		RenewDeadline: time.Second,
+		RetryPeriod:   100 * time.Millisecond,
+	}}
+	runner := new(sksAgentRunnerMock)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Two CCM replicas compete for the SKS agent Lease.
+	var agents []*sksAgent
+	for range 2 {
+		agent, err := newSKSAgent(ts.p, cfg)
+		ts.Require().NoError(err)
+		agent.runners = []sksAgentRunnerDefinition{runner.definition("test")}
+		agents = append(agents, agent)
+		go agent.run(ctx)
+	}
+
+	ts.Require().Eventually(
+		func() bool { return runner.running.Load() == 1 },
+		3*time.Second,
+		50*time.Millisecond,
+		"runner has not been started before timeout",
+	)
+	ts.Require().Never(
+		func() bool { return runner.running.Load() > 1 },
+		500*time.Millisecond,
+		50*time.Millisecond,
+		"runner is running on several replicas",
+	)
+
+	lease, err := ts.p.kclient.CoordinationV1().Leases(defaultSKSAgentLeaseNamespace).
+		Get(ctx, defaultSKSAgentLeaseName, metav1.GetOptions{})
+	ts.Require().NoError(err)
+	ts.Require().NotEmpty(lease.Spec.HolderIdentity)
+
+	// The runners are shut down along with the agents, the Lease being
+	// released afterwards.
+	cancel()
+	for _, agent := range agents {
+		select {
+		case <-agent.done:
+		case <-time.After(3 * time.Second):
+			ts.FailNow("agent has not shut down before timeout")
+		}
+	}
+	ts.Require().Zero(runner.running.Load())
+
+	lease, err = ts.p.kclient.CoordinationV1().Leases(defaultSKSAgentLeaseNamespace).
+		Get(context.Background(), defaultSKSAgentLeaseName, metav1.GetOptions{})
+	ts.Require().NoError(err)
+	ts.Require().Empty(lease.Spec.HolderIdentity)
+}
+
+func (ts *exoscaleCCMTestSuite) Test_sksAgent_lead_dependencies() {
+	defer func(interval time.Duration) {
+		sksAgentRunnerDependenciesPollInterval = interval
+	}(sksAgentRunnerDependenciesPollInterval)
+	sksAgentRunnerDependenciesPollInterval = 10 * time.Millisecond
+
+	var (
+		dependency = new(sksAgentRunnerMock)
+		dependent  = new(sksAgentRunnerMock)
+	)
+	unhealthy := errors.New("not functional")
+	dependency.health.Store(&unhealthy)
+
+	agent, err := newSKSAgent(ts.p, sksAgentConfig{LeaderElection: sksAgentLeaderElectionConfig{Disabled: true}})
+	ts.Require().NoError(err)
+	agent.runners = []sksAgentRunnerDefinition{
+		dependency.definition("test-dependency"),
+		dependent.definition("test-dependent", "test-dependency"),
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go agent.run(ctx)
+
+	// The dependent runner is only started once its dependency is healthy.
+	ts.Require().Eventually(
+		func() bool { return dependency.running.Load() == 1 },
+		time.Second,
+		10*time.Millisecond,
+		"dependency has not been started before timeout",
+	)
+	ts.Require().Never(
+		func() bool { return dependent.running.Load() > 0 },
+		200*time.Millisecond,
+		10*time.Millisecond,
+		"dependent runner started before its dependency is healthy",
+	)
+
+	dependency.health.Store(nil)
+	ts.Require().Eventually(
+		func() bool { return dependent.running.Load() == 1 },
+		time.Second,
+		10*time.Millisecond,
+		"dependent runner has not been started before timeout",
+	)
+
+	cancel()
+	<-agent.done
+	ts.Require().Zero(dependency.running.Load())
+	ts.Require().Zero(dependent.running.Load())
+}
+
+func (ts *exoscaleCCMTestSuite) Test_sksAgent_supervise() {
+	defer func(minBackoff, maxBackoff time.Duration) {
+		sksAgentRunnerRestartMinBackoff = minBackoff
+		sksAgentRunnerRestartMaxBackoff = maxBackoff
+	}(sksAgentRunnerRestartMinBackoff, sksAgentRunnerRestartMaxBackoff)
+	sksAgentRunnerRestartMinBackoff = 200 * time.Millisecond
+	sksAgentRunnerRestartMaxBackoff = 200 * time.Millisecond
+
+	runner := &sksAgentRunnerMock{panics: 1, exits: 1}
+	agent, err := newSKSAgent(ts.p, sksAgentConfig{LeaderElection: sksAgentLeaderElectionConfig{Disabled: true}})
+	ts.Require().NoError(err)
+	agent.runners = []sksAgentRunnerDefinition{runner.definition("test")}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go agent.run(ctx)
+
+	// The runner panicking, then exiting unexpectedly, is restarted after
+	// backoff, being reported unhealthy meanwhile.
+	ts.Require().Eventually(
+		func() bool { return agent.runnerHealth("test") != nil },
+		time.Second,
+		10*time.Millisecond,
+		"runner failure has not been reported before timeout",
+	)
+	ts.Require().ErrorContains(agent.runnerHealth("test"), "runner not running: panic: runner mock panic")
+
+	ts.Require().Eventually(
+		func() bool { return runner.runs.Load() == 3 && agent.runnerHealth("test") == nil },
+		3*time.Second,
+		10*time.Millisecond,
+		"runner has not been restarted before timeout",
+	)
+	ts.Require().Equal(int32(1), runner.running.Load())
+
+	// A running runner reports whether it is functional.
+	runnerErr := errors.New("not functional")
+	runner.health.Store(&runnerErr)
+	ts.Require().ErrorIs(agent.runnerHealth("test"), runnerErr)
+
+	cancel()
+	<-agent.done
+	ts.Require().Zero(runner.running.Load())
+	ts.Require().NoError(agent.runnerHealth("test"))
+}
+
+func (ts *exoscaleCCMTestSuite) Test_sksAgentRunnerHealthChecker_Check() {
+	agent, err := newSKSAgent(ts.p, sksAgentConfig{
+		Runners:        []string{sksAgentNodeCSRValidation},
+		LeaderElection: sksAgentLeaderElectionConfig{Disabled: true},
+	})
+	ts.Require().NoError(err)
+
+	checker := &sksAgentRunnerHealthChecker{agent: agent, runner: sksAgentNodeCSRValidation}
+	ts.Require().Equal(SKSAgentRunnerHealthCheckNamePrefix+sksAgentNodeCSRValidation, checker.Name())
+
+	// Runner not started (e.g. waiting for leadership)
+	ts.Require().NoError(checker.Check(&http.Request{}))
+
+	// Runner started, but its informer cache not synced yet
+	agent.setStatus(sksAgentNodeCSRValidation, newSKSAgentRunnerNodeCSRValidation(ts.p), nil)
+	ts.Require().ErrorContains(checker.Check(&http.Request{}), "CSR informer cache not synced")
+
+	// Runner waiting to be restarted
+	agent.setStatus(sksAgentNodeCSRValidation, nil, errors.New("runner exited unexpectedly"))
+	ts.Require().ErrorContains(checker.Check(&http.Request{}), "runner exited unexpectedly")
+}
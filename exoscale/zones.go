@@ -27,7 +27,7 @@ func newZones(provider *cloudProvider) cloudprovider.Zones {
 func (z zones) GetZone(ctx context.Context) (cloudprovider.Zone, error) {
 	zone, err := metadata.FromCdRom(metadata.AvailabilityZone)
 	if err != nil {
-		zone, err = metadata.Get(ctx, metadata.AvailabilityZone)
+		zone, err = getMetadata(ctx, z.p.metadataClient, metadata.AvailabilityZone)
 		if err != nil {
 			return cloudprovider.Zone{}, fmt.Errorf("get metadata: %w", err)
 		}
@@ -40,8 +40,10 @@ func (z zones) GetZone(ctx context.Context) (cloudprovider.Zone, error) {
 // providerID. This method is particularly used in the context of external cloud providers where node initialization
 // must be done outside the kubelets.
 func (z *zones) GetZoneByProviderID(_ context.Context, providerID string) (cloudprovider.Zone, error) {
+	cfg := z.p.config()
+
 	// first look for a statically-configured override
-	override := z.p.cfg.Instances.getInstanceOverrideByProviderID(providerID)
+	override := cfg.Instances.getInstanceOverrideByProviderID(providerID)
 	if override != nil {
 		if override.External {
 			if override.Region != "" {
@@ -53,7 +55,7 @@ func (z *zones) GetZoneByProviderID(_ context.Context, providerID string) (cloud
 	}
 
 	// Use Exoscale API ?
-	if z.p.cfg.Instances.ExternalOnly {
+	if cfg.Instances.ExternalOnly {
 		return cloudprovider.Zone{}, fmt.Errorf("no instance override found (Exoscale API disabled)")
 	}
 
@@ -65,8 +67,10 @@ func (z *zones) GetZoneByProviderID(_ context.Context, providerID string) (cloud
 // name. This method is particularly used in the context of external cloud providers where node initialization must
 // be done outside the kubelets.
 func (z *zones) GetZoneByNodeName(ctx context.Context, nodeName types.NodeName) (cloudprovider.Zone, error) {
+	cfg := z.p.config()
+
 	// first look for a statically-configured override
-	override := z.p.cfg.Instances.getInstanceOverride(nodeName)
+	override := cfg.Instances.getInstanceOverride(nodeName)
 	if override != nil {
 		if override.External {
 			if override.Region != "" {
@@ -78,7 +82,7 @@ func (z *zones) GetZoneByNodeName(ctx context.Context, nodeName types.NodeName)
 	}
 
 	// Use Exoscale API ?
-	if z.p.cfg.Instances.ExternalOnly {
+	if cfg.Instances.ExternalOnly {
 		return cloudprovider.Zone{}, fmt.Errorf("no instance override found (Exoscale API disabled)")
 	}
 